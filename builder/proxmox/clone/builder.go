@@ -12,6 +12,7 @@ import (
 
 	"context"
 	"fmt"
+	"log"
 )
 
 // The unique id for the builder
@@ -33,9 +34,19 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
 	state := new(multistep.BasicStateBag)
 	state.Put("clone-config", &b.config)
+	sourcePath := "/vms"
+	if b.config.CloneVMID != 0 {
+		sourcePath = fmt.Sprintf("/vms/%d", b.config.CloneVMID)
+	}
+	state.Put("permission_requirements", []proxmox.PermissionRequirement{
+		{Path: sourcePath, Privilege: "VM.Audit"},
+		{Path: sourcePath, Privilege: "VM.Clone"},
+		{Path: b.config.VMACLPath(), Privilege: "VM.Config.Cloudinit"},
+	})
 
 	preSteps := []multistep.Step{
-		&StepSshKeyPair{
+		&stepPrepareClone{},
+		&proxmox.StepSshKeyPair{
 			Debug:        b.config.PackerDebug,
 			DebugKeyPath: fmt.Sprintf("%s.pem", b.config.PackerBuildName),
 		},
@@ -48,8 +59,9 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 
 type cloneVMCreator struct{}
 
-func (*cloneVMCreator) Create(vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu, state multistep.StateBag) error {
-	client := state.Get("proxmoxClient").(*proxmoxapi.Client)
+var _ proxmox.ProxmoxVMConfigurer = &cloneVMCreator{}
+
+func (*cloneVMCreator) Configure(config *proxmoxapi.ConfigQemu, state multistep.StateBag) error {
 	c := state.Get("clone-config").(*Config)
 	comm := state.Get("config").(*proxmox.Config).Comm
 
@@ -57,6 +69,11 @@ func (*cloneVMCreator) Create(vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQ
 	if c.FullClone.False() {
 		fullClone = 0
 	}
+	// stepPrepareClone falls back to a full clone if a linked one isn't
+	// possible, it doesn't run in dry runs
+	if full, ok := state.GetOk("full_clone"); ok && full.(bool) {
+		fullClone = 1
+	}
 	config.FullClone = &fullClone
 
 	// cloud-init options
@@ -71,36 +88,48 @@ func (*cloneVMCreator) Create(vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQ
 		}
 	}
 	config.Ipconfig = IpconfigMap
+	return nil
+}
 
-	var sourceVmr *proxmoxapi.VmRef
-	if c.CloneVM != "" {
-		sourceVmrs, err := client.GetVmRefsByName(c.CloneVM)
-		if err != nil {
-			return err
-		}
+func (cc *cloneVMCreator) Create(vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu, state multistep.StateBag) error {
+	client := state.Get("proxmoxClient").(*proxmoxapi.Client)
+	c := state.Get("clone-config").(*Config)
 
-		// prefer source Vm located on same node
-		sourceVmr = sourceVmrs[0]
-		for _, candVmr := range sourceVmrs {
-			if candVmr.Node() == vmRef.Node() {
-				sourceVmr = candVmr
-			}
-		}
-	} else if c.CloneVMID != 0 {
-		sourceVmr = proxmoxapi.NewVmRef(c.CloneVMID)
-		err := client.CheckVmRef(sourceVmr)
-		if err != nil {
-			return err
-		}
+	err := cc.Configure(&config, state)
+	if err != nil {
+		return err
 	}
 
-	err := config.CloneVm(sourceVmr, vmRef, client)
+	sourceVmr := state.Get("clone_source_vmref").(*proxmoxapi.VmRef)
+	err = config.CloneVm(sourceVmr, vmRef, client)
 	if err != nil {
 		return err
 	}
+	// The clone is deleted by stepStartVM's cleanup if updating or resizing
+	// it fails
+	state.Put("vmRef", vmRef)
 	err = config.UpdateConfig(vmRef, client)
 	if err != nil {
 		return err
 	}
+	return resizeDisks(client, vmRef, c.ResizeDisks)
+}
+
+type diskResizer interface {
+	ResizeQemuDiskRaw(vmr *proxmoxapi.VmRef, disk string, size string) (exitStatus interface{}, err error)
+}
+
+var _ diskResizer = &proxmoxapi.Client{}
+
+// resizeDisks grows disks of the freshly cloned VM in place, so the content
+// of the source template is kept. Proxmox refuses to shrink disks.
+func resizeDisks(client diskResizer, vmRef *proxmoxapi.VmRef, disks []resizeDiskConfig) error {
+	for _, d := range disks {
+		log.Printf("resizing disk %s of VM %d to %s", d.Disk, vmRef.VmId(), d.Size)
+		_, err := client.ResizeQemuDiskRaw(vmRef, d.Disk, d.Size)
+		if err != nil {
+			return fmt.Errorf("error resizing disk %s: %s", d.Disk, err)
+		}
+	}
 	return nil
 }
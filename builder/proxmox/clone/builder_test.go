@@ -0,0 +1,260 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxclone
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/fakepve"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	registryimage "github.com/hashicorp/packer-plugin-sdk/packer/registry/image"
+	"github.com/stretchr/testify/require"
+)
+
+type diskResizerMock struct {
+	resizeQemuDiskRaw func(disk string, size string) (interface{}, error)
+}
+
+func (m diskResizerMock) ResizeQemuDiskRaw(vmr *proxmoxapi.VmRef, disk string, size string) (interface{}, error) {
+	return m.resizeQemuDiskRaw(disk, size)
+}
+
+var _ diskResizer = diskResizerMock{}
+
+func TestResizeDisksCallsAPI(t *testing.T) {
+	cs := []struct {
+		name          string
+		resizeDisks   []resizeDiskConfig
+		resizeErr     error
+		expectedCalls map[string]string
+		expectFailure bool
+	}{
+		{
+			name:          "no disks, no calls",
+			expectedCalls: map[string]string{},
+		},
+		{
+			name: "every configured disk is resized",
+			resizeDisks: []resizeDiskConfig{
+				{Disk: "scsi0", Size: "40G"},
+				{Disk: "scsi1", Size: "+5G"},
+			},
+			expectedCalls: map[string]string{
+				"scsi0": "40G",
+				"scsi1": "+5G",
+			},
+		},
+		{
+			name: "resize error is returned",
+			resizeDisks: []resizeDiskConfig{
+				{Disk: "scsi0", Size: "5G"},
+			},
+			resizeErr:     fmt.Errorf("shrinking disks is not supported"),
+			expectedCalls: map[string]string{"scsi0": "5G"},
+			expectFailure: true,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			calls := map[string]string{}
+			client := diskResizerMock{
+				resizeQemuDiskRaw: func(disk string, size string) (interface{}, error) {
+					calls[disk] = size
+					return nil, c.resizeErr
+				},
+			}
+
+			err := resizeDisks(client, proxmoxapi.NewVmRef(100), c.resizeDisks)
+			if err != nil && !c.expectFailure {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if err == nil && c.expectFailure {
+				t.Error("expected an error, got none")
+			}
+			if fmt.Sprint(calls) != fmt.Sprint(c.expectedCalls) {
+				t.Errorf("expected resize calls %v, got %v", c.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func fakeClusterConfig(srv *fakepve.Server) map[string]interface{} {
+	return map[string]interface{}{
+		"proxmox_url":   srv.APIURL(),
+		"username":      "root@pam!packer",
+		"token":         "xxxx-xxxx-xxxx-xxxx",
+		"node":          "pve",
+		"clone_vm":      "debian-12-template",
+		"full_clone":    false,
+		"communicator":  "none",
+		"template_name": "debian-12-custom",
+	}
+}
+
+func TestBuilderRun(t *testing.T) {
+	cluster, err := fakepve.Fixture("single-node")
+	require.NoError(t, err)
+	srv := fakepve.NewServer(cluster)
+	defer srv.Close()
+
+	cfg := fakeClusterConfig(srv)
+	cfg["resize_disks"] = []map[string]interface{}{
+		{"disk": "scsi0", "size": "20G"},
+	}
+
+	var b Builder
+	_, _, err = b.Prepare(cfg)
+	require.NoError(t, err)
+
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	artifact, err := b.Run(context.Background(), ui, &packersdk.MockHook{})
+	require.NoError(t, err)
+	require.Equal(t, "100", artifact.Id())
+
+	vm, ok := srv.VM(100)
+	require.True(t, ok)
+	require.True(t, vm.Template)
+	require.Equal(t, "debian-12-custom", vm.Name)
+	require.Equal(t, "local-lvm:base-9000-disk-0,size=20G", vm.Config["scsi0"])
+
+	source, ok := srv.VM(9000)
+	require.True(t, ok)
+	require.True(t, source.Template, "source template should be unchanged")
+
+	require.Equal(t, map[string]string{
+		"vm_id":      "9000",
+		"name":       "debian-12-template",
+		"node":       "pve",
+		"clone_type": "linked",
+	}, artifact.State("clone_source"))
+
+	img := artifact.State(registryimage.ArtifactStateURI).(*registryimage.Image)
+	require.Equal(t, "100", img.ImageID)
+	require.Equal(t, "9000", img.SourceImageID)
+	require.Equal(t, "debian-12-template", img.Labels["source_image"])
+
+	generatedData := artifact.State("generated_data").(map[string]interface{})
+	require.Equal(t, "debian-12-template", generatedData["CloneSource"])
+	require.Equal(t, "9000", generatedData["CloneSourceVMID"])
+	require.Equal(t, "debian-12-custom", generatedData["TemplateName"])
+}
+
+func TestBuilderRunDeletesCloneOnResizeFailure(t *testing.T) {
+	cluster, err := fakepve.Fixture("single-node")
+	require.NoError(t, err)
+	srv := fakepve.NewServer(cluster)
+	defer srv.Close()
+
+	cfg := fakeClusterConfig(srv)
+	// the disk of the template is 10G, Proxmox can't shrink it
+	cfg["resize_disks"] = []map[string]interface{}{
+		{"disk": "scsi0", "size": "5G"},
+	}
+
+	var b Builder
+	_, _, err = b.Prepare(cfg)
+	require.NoError(t, err)
+
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	_, err = b.Run(context.Background(), ui, &packersdk.MockHook{})
+	require.ErrorContains(t, err, "shrinking disks is not supported")
+
+	_, ok := srv.VM(100)
+	require.False(t, ok, "clone of the failed build should be deleted")
+	require.Len(t, srv.Cluster().VMs, 1)
+}
+
+func TestBuilderRunLinkedCloneFallback(t *testing.T) {
+	cluster, err := fakepve.Fixture("single-node")
+	require.NoError(t, err)
+	// plain LVM can't make linked clones
+	cluster.Nodes[0].Storage[1].Type = "lvm"
+	srv := fakepve.NewServer(cluster)
+	defer srv.Close()
+
+	var b Builder
+	_, _, err = b.Prepare(fakeClusterConfig(srv))
+	require.NoError(t, err)
+
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	artifact, err := b.Run(context.Background(), ui, &packersdk.MockHook{})
+	require.NoError(t, err)
+
+	lineage := artifact.State("clone_source").(map[string]string)
+	require.Equal(t, "full", lineage["clone_type"])
+	require.Equal(t, "the storage of the template doesn't support linked clones", lineage["fallback_reason"])
+}
+
+func TestBuilderRunLinkedCloneFail(t *testing.T) {
+	cluster, err := fakepve.Fixture("single-node")
+	require.NoError(t, err)
+	cluster.Nodes[0].Storage[1].Type = "lvm"
+	srv := fakepve.NewServer(cluster)
+	defer srv.Close()
+
+	cfg := fakeClusterConfig(srv)
+	cfg["linked_clone_fallback"] = "fail"
+
+	var b Builder
+	_, _, err = b.Prepare(cfg)
+	require.NoError(t, err)
+
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	_, err = b.Run(context.Background(), ui, &packersdk.MockHook{})
+	require.ErrorContains(t, err, "A linked clone of VM 9000 is not possible")
+	require.Len(t, srv.Cluster().VMs, 1, "no VM should be created")
+}
+
+func TestBuilderRunLineage(t *testing.T) {
+	cluster, err := fakepve.Fixture("single-node")
+	require.NoError(t, err)
+	for idx := range cluster.VMs {
+		if cluster.VMs[idx].ID == 9000 {
+			cluster.VMs[idx].Config["description"] = "Debian 12 base\n\n```packer-lineage\n" +
+				`[{"template_id":"9000","template_name":"debian-12-template","iso_url":"https://example.com/debian-12.iso","iso_checksum":"sha256:abcd"}]` +
+				"\n```"
+		}
+	}
+	srv := fakepve.NewServer(cluster)
+	defer srv.Close()
+
+	cfg := fakeClusterConfig(srv)
+	cfg["record_lineage"] = true
+	cfg["template_description"] = "Debian 12 with nginx"
+
+	var b Builder
+	generatedData, _, err := b.Prepare(cfg)
+	require.NoError(t, err)
+	require.Contains(t, generatedData, "Lineage")
+
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	artifact, err := b.Run(context.Background(), ui, &packersdk.MockHook{})
+	require.NoError(t, err)
+
+	lineage := artifact.State("lineage").([]interface{})
+	require.Len(t, lineage, 2)
+	require.Equal(t, "sha256:abcd", lineage[0].(map[string]string)["iso_checksum"])
+	entry := lineage[1].(map[string]string)
+	require.Equal(t, "100", entry["template_id"])
+	require.Equal(t, "debian-12-custom", entry["template_name"])
+	require.Equal(t, "9000", entry["clone_source_vm_id"])
+	require.Equal(t, "linked", entry["clone_type"])
+
+	generated := artifact.State("generated_data").(map[string]interface{})
+	require.Contains(t, generated["Lineage"], `"iso_checksum":"sha256:abcd"`)
+
+	vm, ok := srv.VM(100)
+	require.True(t, ok)
+	recorded, description, err := proxmox.ParseLineage(vm.Config["description"].(string))
+	require.NoError(t, err)
+	require.Equal(t, "Debian 12 with nginx", description)
+	require.Len(t, recorded, 2)
+	require.Equal(t, entry, recorded[1])
+}
@@ -1,7 +1,7 @@
 // Copyright (c) HashiCorp, Inc.
 // SPDX-License-Identifier: MPL-2.0
 
-//go:generate packer-sdc mapstructure-to-hcl2 -type Config,cloudInitIpconfig
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,cloudInitIpconfig,resizeDiskConfig
 
 package proxmoxclone
 
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net"
 	"net/netip"
+	"regexp"
 	"strings"
 
 	proxmoxcommon "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
@@ -20,13 +21,16 @@ import (
 type Config struct {
 	proxmoxcommon.Config `mapstructure:",squash"`
 
-	CloneVM   string         `mapstructure:"clone_vm" required:"true"`
-	CloneVMID int            `mapstructure:"clone_vm_id" required:"true"`
-	FullClone config.Trilean `mapstructure:"full_clone" required:"false"`
+	CloneVM             string         `mapstructure:"clone_vm" required:"true"`
+	CloneVMID           int            `mapstructure:"clone_vm_id" required:"true"`
+	FullClone           config.Trilean `mapstructure:"full_clone" required:"false"`
+	LinkedCloneFallback string         `mapstructure:"linked_clone_fallback" required:"false"`
 
 	Nameserver   string              `mapstructure:"nameserver" required:"false"`
 	Searchdomain string              `mapstructure:"searchdomain" required:"false"`
 	Ipconfigs    []cloudInitIpconfig `mapstructure:"ipconfig" required:"false"`
+
+	ResizeDisks []resizeDiskConfig `mapstructure:"resize_disks" required:"false"`
 }
 
 type cloudInitIpconfig struct {
@@ -36,9 +40,14 @@ type cloudInitIpconfig struct {
 	Gateway6 string `mapstructure:"gateway6" required:"false"`
 }
 
+type resizeDiskConfig struct {
+	Disk string `mapstructure:"disk" required:"true"`
+	Size string `mapstructure:"size" required:"true"`
+}
+
 func (c *Config) Prepare(raws ...interface{}) ([]string, []string, error) {
 	var errs *packersdk.MultiError
-	_, warnings, merrs := c.Config.Prepare(c, raws...)
+	generatedData, warnings, merrs := c.Config.Prepare(c, raws...)
 	if merrs != nil {
 		errs = packersdk.MultiErrorAppend(errs, merrs)
 	}
@@ -56,6 +65,14 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, []string, error) {
 		errs = packersdk.MultiErrorAppend(errs, errors.New("clone_vm_id must be in range 100-999999999"))
 	}
 
+	switch c.LinkedCloneFallback {
+	case "":
+		c.LinkedCloneFallback = "full"
+	case "full", "fail":
+	default:
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("linked_clone_fallback must be \"full\" or \"fail\", got %q", c.LinkedCloneFallback))
+	}
+
 	// Check validity of given IP addresses
 	if c.Nameserver != "" {
 		for _, nameserver := range strings.Split(c.Nameserver, " ") {
@@ -91,6 +108,24 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, []string, error) {
 			}
 		}
 	}
+	// Disk identifiers as used by Proxmox, e.g. scsi0 or virtio1. Sizes are
+	// either absolute (40G) or relative to the current size (+30G).
+	validDiskRe := regexp.MustCompile(`^(ide|sata|scsi|virtio)\d+$`)
+	validSizeRe := regexp.MustCompile(`^\+?\d+(\.\d+)?[KMGT]?$`)
+	resizedDisks := map[string]bool{}
+	for idx, d := range c.ResizeDisks {
+		if !validDiskRe.MatchString(d.Disk) {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("resize_disks[%d].disk must be a disk identifier like scsi0 or virtio0, got %q", idx, d.Disk))
+		}
+		if resizedDisks[d.Disk] {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("resize_disks[%d].disk %s is resized more than once", idx, d.Disk))
+		}
+		resizedDisks[d.Disk] = true
+		if !validSizeRe.MatchString(d.Size) {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("resize_disks[%d].size must be a size like 40G or +30G, got %q", idx, d.Size))
+		}
+	}
+
 	if len(c.NICs) < len(c.Ipconfigs) {
 		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("%d ipconfig blocks given, but only %d network interfaces defined", len(c.Ipconfigs), len(c.NICs)))
 	}
@@ -98,7 +133,7 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, []string, error) {
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, warnings, errs
 	}
-	return nil, warnings, nil
+	return append(generatedData, "CloneSource", "CloneSourceVMID"), warnings, nil
 }
 
 // Convert Ipconfig attributes into a Proxmox-API compatible string
@@ -25,6 +25,12 @@ type FlatConfig struct {
 	HTTPPortMax               *int                               `mapstructure:"http_port_max" cty:"http_port_max" hcl:"http_port_max"`
 	HTTPAddress               *string                            `mapstructure:"http_bind_address" cty:"http_bind_address" hcl:"http_bind_address"`
 	HTTPInterface             *string                            `mapstructure:"http_interface" undocumented:"true" cty:"http_interface" hcl:"http_interface"`
+	HTTPTemplates             map[string]string                  `mapstructure:"http_templates" cty:"http_templates" hcl:"http_templates"`
+	HTTPBindCIDR              *string                            `mapstructure:"http_bind_cidr" cty:"http_bind_cidr" hcl:"http_bind_cidr"`
+	HTTPTunnel                *bool                              `mapstructure:"http_tunnel" cty:"http_tunnel" hcl:"http_tunnel"`
+	HTTPTunnelBridge          *string                            `mapstructure:"http_tunnel_bridge" cty:"http_tunnel_bridge" hcl:"http_tunnel_bridge"`
+	NodeSSH                   *proxmox.FlatnodeSSHConfig         `mapstructure:"node_ssh" cty:"node_ssh" hcl:"node_ssh"`
+	SSHBastionNode            *bool                              `mapstructure:"ssh_bastion_node" cty:"ssh_bastion_node" hcl:"ssh_bastion_node"`
 	BootGroupInterval         *string                            `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
 	BootWait                  *string                            `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
 	BootCommand               []string                           `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
@@ -99,10 +105,12 @@ type FlatConfig struct {
 	BIOS                      *string                            `mapstructure:"bios" cty:"bios" hcl:"bios"`
 	EFIConfig                 *proxmox.FlatefiConfig             `mapstructure:"efi_config" cty:"efi_config" hcl:"efi_config"`
 	EFIDisk                   *string                            `mapstructure:"efidisk" cty:"efidisk" hcl:"efidisk"`
+	TPMConfig                 *proxmox.FlattpmConfig             `mapstructure:"tpm_config" cty:"tpm_config" hcl:"tpm_config"`
 	Machine                   *string                            `mapstructure:"machine" cty:"machine" hcl:"machine"`
 	Rng0                      *proxmox.Flatrng0Config            `mapstructure:"rng0" cty:"rng0" hcl:"rng0"`
 	VGA                       *proxmox.FlatvgaConfig             `mapstructure:"vga" cty:"vga" hcl:"vga"`
 	NICs                      []proxmox.FlatNICConfig            `mapstructure:"network_adapters" cty:"network_adapters" hcl:"network_adapters"`
+	MACAddressHook            []string                           `mapstructure:"mac_address_hook" cty:"mac_address_hook" hcl:"mac_address_hook"`
 	Disks                     []proxmox.FlatdiskConfig           `mapstructure:"disks" cty:"disks" hcl:"disks"`
 	PCIDevices                []proxmox.FlatpciDeviceConfig      `mapstructure:"pci_devices" cty:"pci_devices" hcl:"pci_devices"`
 	Serials                   []string                           `mapstructure:"serials" cty:"serials" hcl:"serials"`
@@ -110,18 +118,32 @@ type FlatConfig struct {
 	SCSIController            *string                            `mapstructure:"scsi_controller" cty:"scsi_controller" hcl:"scsi_controller"`
 	Onboot                    *bool                              `mapstructure:"onboot" cty:"onboot" hcl:"onboot"`
 	DisableKVM                *bool                              `mapstructure:"disable_kvm" cty:"disable_kvm" hcl:"disable_kvm"`
+	HardwareProfile           *string                            `mapstructure:"hardware_profile" cty:"hardware_profile" hcl:"hardware_profile"`
 	TemplateName              *string                            `mapstructure:"template_name" cty:"template_name" hcl:"template_name"`
 	TemplateDescription       *string                            `mapstructure:"template_description" cty:"template_description" hcl:"template_description"`
+	RecordLineage             *bool                              `mapstructure:"record_lineage" cty:"record_lineage" hcl:"record_lineage"`
+	ExtraConfig               map[string]string                  `mapstructure:"extra_config" cty:"extra_config" hcl:"extra_config"`
+	TemplateExtraConfig       map[string]string                  `mapstructure:"template_extra_config" cty:"template_extra_config" hcl:"template_extra_config"`
+	Hookscript                *proxmox.FlathookscriptConfig      `mapstructure:"hookscript" cty:"hookscript" hcl:"hookscript"`
+	Checkpoints               *bool                              `mapstructure:"checkpoints" cty:"checkpoints" hcl:"checkpoints"`
+	ResumeFromSnapshot        *string                            `mapstructure:"resume_from_snapshot" cty:"resume_from_snapshot" hcl:"resume_from_snapshot"`
+	KeepVMOnFailure           *bool                              `mapstructure:"keep_vm_on_failure" cty:"keep_vm_on_failure" hcl:"keep_vm_on_failure"`
 	CloudInit                 *bool                              `mapstructure:"cloud_init" cty:"cloud_init" hcl:"cloud_init"`
 	CloudInitStoragePool      *string                            `mapstructure:"cloud_init_storage_pool" cty:"cloud_init_storage_pool" hcl:"cloud_init_storage_pool"`
 	AdditionalISOFiles        []proxmox.FlatadditionalISOsConfig `mapstructure:"additional_iso_files" cty:"additional_iso_files" hcl:"additional_iso_files"`
 	VMInterface               *string                            `mapstructure:"vm_interface" cty:"vm_interface" hcl:"vm_interface"`
+	BuildNetwork              *proxmox.FlatbuildNetworkConfig    `mapstructure:"build_network" cty:"build_network" hcl:"build_network"`
+	BootFiles                 *proxmox.FlatbootFilesConfig       `mapstructure:"boot_files" cty:"boot_files" hcl:"boot_files"`
+	WindowsUnattend           *proxmox.FlatwindowsUnattendConfig `mapstructure:"windows_unattend" cty:"windows_unattend" hcl:"windows_unattend"`
+	DryRun                    *bool                              `mapstructure:"dry_run" cty:"dry_run" hcl:"dry_run"`
 	CloneVM                   *string                            `mapstructure:"clone_vm" required:"true" cty:"clone_vm" hcl:"clone_vm"`
 	CloneVMID                 *int                               `mapstructure:"clone_vm_id" required:"true" cty:"clone_vm_id" hcl:"clone_vm_id"`
 	FullClone                 *bool                              `mapstructure:"full_clone" required:"false" cty:"full_clone" hcl:"full_clone"`
+	LinkedCloneFallback       *string                            `mapstructure:"linked_clone_fallback" required:"false" cty:"linked_clone_fallback" hcl:"linked_clone_fallback"`
 	Nameserver                *string                            `mapstructure:"nameserver" required:"false" cty:"nameserver" hcl:"nameserver"`
 	Searchdomain              *string                            `mapstructure:"searchdomain" required:"false" cty:"searchdomain" hcl:"searchdomain"`
 	Ipconfigs                 []FlatcloudInitIpconfig            `mapstructure:"ipconfig" required:"false" cty:"ipconfig" hcl:"ipconfig"`
+	ResizeDisks               []FlatresizeDiskConfig             `mapstructure:"resize_disks" required:"false" cty:"resize_disks" hcl:"resize_disks"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -150,6 +172,12 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"http_port_max":                &hcldec.AttrSpec{Name: "http_port_max", Type: cty.Number, Required: false},
 		"http_bind_address":            &hcldec.AttrSpec{Name: "http_bind_address", Type: cty.String, Required: false},
 		"http_interface":               &hcldec.AttrSpec{Name: "http_interface", Type: cty.String, Required: false},
+		"http_templates":               &hcldec.AttrSpec{Name: "http_templates", Type: cty.Map(cty.String), Required: false},
+		"http_bind_cidr":               &hcldec.AttrSpec{Name: "http_bind_cidr", Type: cty.String, Required: false},
+		"http_tunnel":                  &hcldec.AttrSpec{Name: "http_tunnel", Type: cty.Bool, Required: false},
+		"http_tunnel_bridge":           &hcldec.AttrSpec{Name: "http_tunnel_bridge", Type: cty.String, Required: false},
+		"node_ssh":                     &hcldec.BlockSpec{TypeName: "node_ssh", Nested: hcldec.ObjectSpec((*proxmox.FlatnodeSSHConfig)(nil).HCL2Spec())},
+		"ssh_bastion_node":             &hcldec.AttrSpec{Name: "ssh_bastion_node", Type: cty.Bool, Required: false},
 		"boot_keygroup_interval":       &hcldec.AttrSpec{Name: "boot_keygroup_interval", Type: cty.String, Required: false},
 		"boot_wait":                    &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
 		"boot_command":                 &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
@@ -224,10 +252,12 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"bios":                         &hcldec.AttrSpec{Name: "bios", Type: cty.String, Required: false},
 		"efi_config":                   &hcldec.BlockSpec{TypeName: "efi_config", Nested: hcldec.ObjectSpec((*proxmox.FlatefiConfig)(nil).HCL2Spec())},
 		"efidisk":                      &hcldec.AttrSpec{Name: "efidisk", Type: cty.String, Required: false},
+		"tpm_config":                   &hcldec.BlockSpec{TypeName: "tpm_config", Nested: hcldec.ObjectSpec((*proxmox.FlattpmConfig)(nil).HCL2Spec())},
 		"machine":                      &hcldec.AttrSpec{Name: "machine", Type: cty.String, Required: false},
 		"rng0":                         &hcldec.BlockSpec{TypeName: "rng0", Nested: hcldec.ObjectSpec((*proxmox.Flatrng0Config)(nil).HCL2Spec())},
 		"vga":                          &hcldec.BlockSpec{TypeName: "vga", Nested: hcldec.ObjectSpec((*proxmox.FlatvgaConfig)(nil).HCL2Spec())},
 		"network_adapters":             &hcldec.BlockListSpec{TypeName: "network_adapters", Nested: hcldec.ObjectSpec((*proxmox.FlatNICConfig)(nil).HCL2Spec())},
+		"mac_address_hook":             &hcldec.AttrSpec{Name: "mac_address_hook", Type: cty.List(cty.String), Required: false},
 		"disks":                        &hcldec.BlockListSpec{TypeName: "disks", Nested: hcldec.ObjectSpec((*proxmox.FlatdiskConfig)(nil).HCL2Spec())},
 		"pci_devices":                  &hcldec.BlockListSpec{TypeName: "pci_devices", Nested: hcldec.ObjectSpec((*proxmox.FlatpciDeviceConfig)(nil).HCL2Spec())},
 		"serials":                      &hcldec.AttrSpec{Name: "serials", Type: cty.List(cty.String), Required: false},
@@ -235,18 +265,32 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"scsi_controller":              &hcldec.AttrSpec{Name: "scsi_controller", Type: cty.String, Required: false},
 		"onboot":                       &hcldec.AttrSpec{Name: "onboot", Type: cty.Bool, Required: false},
 		"disable_kvm":                  &hcldec.AttrSpec{Name: "disable_kvm", Type: cty.Bool, Required: false},
+		"hardware_profile":             &hcldec.AttrSpec{Name: "hardware_profile", Type: cty.String, Required: false},
 		"template_name":                &hcldec.AttrSpec{Name: "template_name", Type: cty.String, Required: false},
 		"template_description":         &hcldec.AttrSpec{Name: "template_description", Type: cty.String, Required: false},
+		"record_lineage":               &hcldec.AttrSpec{Name: "record_lineage", Type: cty.Bool, Required: false},
+		"extra_config":                 &hcldec.AttrSpec{Name: "extra_config", Type: cty.Map(cty.String), Required: false},
+		"template_extra_config":        &hcldec.AttrSpec{Name: "template_extra_config", Type: cty.Map(cty.String), Required: false},
+		"hookscript":                   &hcldec.BlockSpec{TypeName: "hookscript", Nested: hcldec.ObjectSpec((*proxmox.FlathookscriptConfig)(nil).HCL2Spec())},
+		"checkpoints":                  &hcldec.AttrSpec{Name: "checkpoints", Type: cty.Bool, Required: false},
+		"resume_from_snapshot":         &hcldec.AttrSpec{Name: "resume_from_snapshot", Type: cty.String, Required: false},
+		"keep_vm_on_failure":           &hcldec.AttrSpec{Name: "keep_vm_on_failure", Type: cty.Bool, Required: false},
 		"cloud_init":                   &hcldec.AttrSpec{Name: "cloud_init", Type: cty.Bool, Required: false},
 		"cloud_init_storage_pool":      &hcldec.AttrSpec{Name: "cloud_init_storage_pool", Type: cty.String, Required: false},
 		"additional_iso_files":         &hcldec.BlockListSpec{TypeName: "additional_iso_files", Nested: hcldec.ObjectSpec((*proxmox.FlatadditionalISOsConfig)(nil).HCL2Spec())},
 		"vm_interface":                 &hcldec.AttrSpec{Name: "vm_interface", Type: cty.String, Required: false},
+		"build_network":                &hcldec.BlockSpec{TypeName: "build_network", Nested: hcldec.ObjectSpec((*proxmox.FlatbuildNetworkConfig)(nil).HCL2Spec())},
+		"boot_files":                   &hcldec.BlockSpec{TypeName: "boot_files", Nested: hcldec.ObjectSpec((*proxmox.FlatbootFilesConfig)(nil).HCL2Spec())},
+		"windows_unattend":             &hcldec.BlockSpec{TypeName: "windows_unattend", Nested: hcldec.ObjectSpec((*proxmox.FlatwindowsUnattendConfig)(nil).HCL2Spec())},
+		"dry_run":                      &hcldec.AttrSpec{Name: "dry_run", Type: cty.Bool, Required: false},
 		"clone_vm":                     &hcldec.AttrSpec{Name: "clone_vm", Type: cty.String, Required: false},
 		"clone_vm_id":                  &hcldec.AttrSpec{Name: "clone_vm_id", Type: cty.Number, Required: false},
 		"full_clone":                   &hcldec.AttrSpec{Name: "full_clone", Type: cty.Bool, Required: false},
+		"linked_clone_fallback":        &hcldec.AttrSpec{Name: "linked_clone_fallback", Type: cty.String, Required: false},
 		"nameserver":                   &hcldec.AttrSpec{Name: "nameserver", Type: cty.String, Required: false},
 		"searchdomain":                 &hcldec.AttrSpec{Name: "searchdomain", Type: cty.String, Required: false},
 		"ipconfig":                     &hcldec.BlockListSpec{TypeName: "ipconfig", Nested: hcldec.ObjectSpec((*FlatcloudInitIpconfig)(nil).HCL2Spec())},
+		"resize_disks":                 &hcldec.BlockListSpec{TypeName: "resize_disks", Nested: hcldec.ObjectSpec((*FlatresizeDiskConfig)(nil).HCL2Spec())},
 	}
 	return s
 }
@@ -279,3 +323,28 @@ func (*FlatcloudInitIpconfig) HCL2Spec() map[string]hcldec.Spec {
 	}
 	return s
 }
+
+// FlatresizeDiskConfig is an auto-generated flat version of resizeDiskConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatresizeDiskConfig struct {
+	Disk *string `mapstructure:"disk" required:"true" cty:"disk" hcl:"disk"`
+	Size *string `mapstructure:"size" required:"true" cty:"size" hcl:"size"`
+}
+
+// FlatMapstructure returns a new FlatresizeDiskConfig.
+// FlatresizeDiskConfig is an auto-generated flat version of resizeDiskConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*resizeDiskConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatresizeDiskConfig)
+}
+
+// HCL2Spec returns the hcl spec of a resizeDiskConfig.
+// This spec is used by HCL to read the fields of resizeDiskConfig.
+// The decoded values from this spec will then be applied to a FlatresizeDiskConfig.
+func (*FlatresizeDiskConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"disk": &hcldec.AttrSpec{Name: "disk", Type: cty.String, Required: false},
+		"size": &hcldec.AttrSpec{Name: "size", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -272,3 +272,107 @@ func TestIpconfig(t *testing.T) {
 		})
 	}
 }
+
+func TestResizeDisks(t *testing.T) {
+	resizeTest := []struct {
+		name          string
+		resizeDisks   []resizeDiskConfig
+		expectFailure bool
+	}{
+		{
+			name:          "no resize_disks, no error",
+			expectFailure: false,
+		},
+		{
+			name:          "absolute and relative sizes, no error",
+			expectFailure: false,
+			resizeDisks: []resizeDiskConfig{
+				{Disk: "scsi0", Size: "40G"},
+				{Disk: "virtio1", Size: "+10G"},
+			},
+		},
+		{
+			name:          "invalid disk identifier, fail",
+			expectFailure: true,
+			resizeDisks: []resizeDiskConfig{
+				{Disk: "disk0", Size: "40G"},
+			},
+		},
+		{
+			name:          "invalid size, fail",
+			expectFailure: true,
+			resizeDisks: []resizeDiskConfig{
+				{Disk: "scsi0", Size: "40 gigabytes"},
+			},
+		},
+		{
+			name:          "same disk resized twice, fail",
+			expectFailure: true,
+			resizeDisks: []resizeDiskConfig{
+				{Disk: "scsi0", Size: "40G"},
+				{Disk: "scsi0", Size: "+10G"},
+			},
+		},
+	}
+
+	for _, tt := range resizeTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			cfg["resize_disks"] = tt.resizeDisks
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Errorf("expected failure, but prepare succeeded")
+			}
+		})
+	}
+}
+
+func TestLinkedCloneFallback(t *testing.T) {
+	fallbackTest := []struct {
+		name          string
+		fallback      string
+		expectFailure bool
+		expected      string
+	}{
+		{
+			name:     "no fallback, default full",
+			expected: "full",
+		},
+		{
+			name:     "fail, no error",
+			fallback: "fail",
+			expected: "fail",
+		},
+		{
+			name:          "unknown fallback, fail",
+			fallback:      "linked",
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range fallbackTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			if tt.fallback != "" {
+				cfg["linked_clone_fallback"] = tt.fallback
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Errorf("expected failure, but prepare succeeded")
+			}
+			if err == nil && c.LinkedCloneFallback != tt.expected {
+				t.Errorf("expected linked_clone_fallback %q, got %q", tt.expected, c.LinkedCloneFallback)
+			}
+		})
+	}
+}
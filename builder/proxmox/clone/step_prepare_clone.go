@@ -0,0 +1,200 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxclone
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+type cloneSourceReader interface {
+	CheckVmRef(vmr *proxmoxapi.VmRef) error
+	GetVmRefsByName(vmName string) ([]*proxmoxapi.VmRef, error)
+	GetVmConfig(vmr *proxmoxapi.VmRef) (map[string]interface{}, error)
+	GetItemList(url string) (map[string]interface{}, error)
+}
+
+var _ cloneSourceReader = &proxmoxapi.Client{}
+
+// stepPrepareClone looks up the VM to clone before any resources are
+// created. If a linked clone was requested, it checks if one is possible and
+// falls back to a full clone or fails, depending on linked_clone_fallback.
+//
+// It sets the "clone_source_vmref" and "full_clone" state keys used by the
+// cloneVMCreator, and the "clone_source" key with the lineage of the build,
+// which is also added to the state of the artifact.
+type stepPrepareClone struct{}
+
+func (s *stepPrepareClone) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(cloneSourceReader)
+	c := state.Get("clone-config").(*Config)
+
+	source, err := findCloneSource(client, c)
+	if err != nil {
+		err := fmt.Errorf("Error looking up the VM to clone: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	sourceConfig, err := client.GetVmConfig(source)
+	if err != nil {
+		err := fmt.Errorf("Error reading the config of the VM to clone: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	sourceName, _ := sourceConfig["name"].(string)
+
+	lineage := map[string]string{
+		"vm_id": strconv.Itoa(source.VmId()),
+		"name":  sourceName,
+		"node":  source.Node(),
+	}
+
+	fullClone := !c.FullClone.False()
+	if !fullClone {
+		reason, err := linkedCloneBlocker(client, c, source, sourceConfig)
+		if err != nil {
+			err := fmt.Errorf("Error checking if a linked clone is possible: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if reason != "" {
+			if c.LinkedCloneFallback == "fail" {
+				err := fmt.Errorf("A linked clone of VM %d is not possible: %s", source.VmId(), reason)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+			ui.Say(fmt.Sprintf("A linked clone of VM %d is not possible (%s), making a full clone", source.VmId(), reason))
+			fullClone = true
+			lineage["fallback_reason"] = reason
+		}
+	}
+	lineage["clone_type"] = "linked"
+	if fullClone {
+		lineage["clone_type"] = "full"
+	}
+
+	// The lineage of the template continues the one of the source, if it
+	// was built with record_lineage
+	description, _ := sourceConfig["description"].(string)
+	base, _, err := proxmox.ParseLineage(description)
+	if err != nil {
+		ui.Say(fmt.Sprintf("Ignoring the lineage of VM %d: %s", source.VmId(), err))
+	}
+	state.Put("lineage_base", base)
+	state.Put("lineage_origin", map[string]string{
+		"clone_source_vm_id": lineage["vm_id"],
+		"clone_source_name":  lineage["name"],
+		"clone_type":         lineage["clone_type"],
+	})
+
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("CloneSource", lineage["name"])
+	generatedData.Put("CloneSourceVMID", lineage["vm_id"])
+
+	state.Put("source_image", lineage["name"])
+	state.Put("source_image_id", lineage["vm_id"])
+
+	state.Put("clone_source_vmref", source)
+	state.Put("full_clone", fullClone)
+	state.Put("clone_source", lineage)
+	proxmox.AddArtifactState(state, "clone_source", lineage)
+	return multistep.ActionContinue
+}
+
+func (s *stepPrepareClone) Cleanup(state multistep.StateBag) {}
+
+// findCloneSource returns the VM set with clone_vm or clone_vm_id. If there
+// are multiple VMs named clone_vm, the one on the build node is preferred.
+func findCloneSource(client cloneSourceReader, c *Config) (*proxmoxapi.VmRef, error) {
+	if c.CloneVM != "" {
+		sourceVmrs, err := client.GetVmRefsByName(c.CloneVM)
+		if err != nil {
+			return nil, err
+		}
+
+		// prefer source Vm located on same node
+		sourceVmr := sourceVmrs[0]
+		for _, candVmr := range sourceVmrs {
+			if candVmr.Node() == c.Node {
+				sourceVmr = candVmr
+			}
+		}
+		return sourceVmr, nil
+	}
+
+	sourceVmr := proxmoxapi.NewVmRef(c.CloneVMID)
+	err := client.CheckVmRef(sourceVmr)
+	if err != nil {
+		return nil, err
+	}
+	return sourceVmr, nil
+}
+
+// Drives that are cloned with the VM
+var cloneDiskRe = regexp.MustCompile(`^((ide|sata|scsi|virtio)\d+|efidisk0|tpmstate0)$`)
+
+// linkedCloneBlocker returns why a linked clone of source isn't possible, or
+// an empty string if it is.
+func linkedCloneBlocker(client cloneSourceReader, c *Config, source *proxmoxapi.VmRef, sourceConfig map[string]interface{}) (string, error) {
+	if sourceConfig["template"] == nil {
+		return fmt.Sprintf("VM %d is not a template", source.VmId()), nil
+	}
+
+	// Linked clones stay on the storage of the template, while the storage
+	// of the first disk is where the disks of a full clone go
+	if len(c.Disks) > 0 && c.Disks[0].StoragePool != "" {
+		seen := map[string]bool{}
+		var storages []string
+		for key, value := range sourceConfig {
+			drive, ok := value.(string)
+			if !ok || !cloneDiskRe.MatchString(key) || strings.Contains(drive, "media=cdrom") {
+				continue
+			}
+			storage, _, _ := strings.Cut(drive, ":")
+			if storage != c.Disks[0].StoragePool && !seen[storage] {
+				seen[storage] = true
+				storages = append(storages, storage)
+			}
+		}
+		if len(storages) > 0 {
+			sort.Strings(storages)
+			return fmt.Sprintf("disks of the template are on %s, not on %s", strings.Join(storages, ", "), c.Disks[0].StoragePool), nil
+		}
+	}
+
+	list, err := client.GetItemList(fmt.Sprintf("/nodes/%s/qemu/%d/feature?feature=clone", source.Node(), source.VmId()))
+	if err != nil {
+		return "", err
+	}
+	data, ok := list["data"].(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("unexpected response checking the clone feature: %v", list)
+	}
+	// The API returns booleans as 0 or 1
+	if hasFeature, _ := data["hasFeature"].(float64); hasFeature != 1 {
+		return "the storage of the template doesn't support linked clones", nil
+	}
+	nodes, _ := data["nodes"].([]interface{})
+	for _, node := range nodes {
+		if node == c.Node {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("the storage of the template isn't available on node %s", c.Node), nil
+}
@@ -0,0 +1,158 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxclone
+
+import (
+	"context"
+	"testing"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+)
+
+type cloneSourceReaderMock struct {
+	config  map[string]interface{}
+	feature map[string]interface{}
+}
+
+func (m *cloneSourceReaderMock) CheckVmRef(vmr *proxmoxapi.VmRef) error {
+	vmr.SetNode("pve")
+	return nil
+}
+
+func (m *cloneSourceReaderMock) GetVmRefsByName(vmName string) ([]*proxmoxapi.VmRef, error) {
+	vmr := proxmoxapi.NewVmRef(9000)
+	vmr.SetNode("pve")
+	return []*proxmoxapi.VmRef{vmr}, nil
+}
+
+func (m *cloneSourceReaderMock) GetVmConfig(vmr *proxmoxapi.VmRef) (map[string]interface{}, error) {
+	return m.config, nil
+}
+
+func (m *cloneSourceReaderMock) GetItemList(url string) (map[string]interface{}, error) {
+	return map[string]interface{}{"data": m.feature}, nil
+}
+
+var _ cloneSourceReader = &cloneSourceReaderMock{}
+
+func TestPrepareClone(t *testing.T) {
+	template := map[string]interface{}{
+		"name":     "debian-12-template",
+		"template": float64(1),
+		"scsi0":    "local-lvm:base-9000-disk-0,size=8G",
+		"ide2":     "local:iso/debian.iso,media=cdrom",
+	}
+	linkable := map[string]interface{}{"hasFeature": float64(1), "nodes": []interface{}{"pve"}}
+
+	cs := []struct {
+		name           string
+		fullClone      bool
+		fallback       string
+		storagePool    string
+		sourceConfig   map[string]interface{}
+		feature        map[string]interface{}
+		expectedAction multistep.StepAction
+		expectLineage  map[string]string
+	}{
+		{
+			name:           "full clone requested",
+			fullClone:      true,
+			sourceConfig:   template,
+			expectedAction: multistep.ActionContinue,
+			expectLineage: map[string]string{
+				"vm_id": "9000", "name": "debian-12-template", "node": "pve", "clone_type": "full",
+			},
+		},
+		{
+			name:           "linked clone possible",
+			sourceConfig:   template,
+			feature:        linkable,
+			expectedAction: multistep.ActionContinue,
+			expectLineage: map[string]string{
+				"vm_id": "9000", "name": "debian-12-template", "node": "pve", "clone_type": "linked",
+			},
+		},
+		{
+			name:      "source that is not a template falls back to a full clone",
+			fullClone: false,
+			sourceConfig: map[string]interface{}{
+				"name":  "debian-12",
+				"scsi0": "local-lvm:vm-9000-disk-0,size=8G",
+			},
+			expectedAction: multistep.ActionContinue,
+			expectLineage: map[string]string{
+				"vm_id": "9000", "name": "debian-12", "node": "pve", "clone_type": "full",
+				"fallback_reason": "VM 9000 is not a template",
+			},
+		},
+		{
+			name:           "disks on another storage fall back to a full clone",
+			storagePool:    "ceph",
+			sourceConfig:   template,
+			feature:        linkable,
+			expectedAction: multistep.ActionContinue,
+			expectLineage: map[string]string{
+				"vm_id": "9000", "name": "debian-12-template", "node": "pve", "clone_type": "full",
+				"fallback_reason": "disks of the template are on local-lvm, not on ceph",
+			},
+		},
+		{
+			name:           "storage not on the build node falls back to a full clone",
+			sourceConfig:   template,
+			feature:        map[string]interface{}{"hasFeature": float64(1), "nodes": []interface{}{"pve2"}},
+			expectedAction: multistep.ActionContinue,
+			expectLineage: map[string]string{
+				"vm_id": "9000", "name": "debian-12-template", "node": "pve", "clone_type": "full",
+				"fallback_reason": "the storage of the template isn't available on node pve",
+			},
+		},
+		{
+			name:           "unsupported storage with fallback fail should halt",
+			fallback:       "fail",
+			sourceConfig:   template,
+			feature:        map[string]interface{}{"hasFeature": float64(0), "nodes": []interface{}{}},
+			expectedAction: multistep.ActionHalt,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			m := &cloneSourceReaderMock{config: c.sourceConfig, feature: c.feature}
+
+			raw := mandatoryConfig(t)
+			raw["node"] = "pve"
+			raw["full_clone"] = c.fullClone
+			raw["linked_clone_fallback"] = c.fallback
+			if c.storagePool != "" {
+				raw["disks"] = []map[string]interface{}{
+					{"type": "scsi", "disk_size": "8G", "storage_pool": c.storagePool},
+				}
+			}
+			var cfg Config
+			_, _, err := cfg.Prepare(&cfg, raw)
+			if err != nil {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("clone-config", &cfg)
+			state.Put("proxmoxClient", m)
+
+			step := &stepPrepareClone{}
+			action := step.Run(context.TODO(), state)
+			assert.Equal(t, c.expectedAction, action)
+			if action != multistep.ActionContinue {
+				_, ok := state.GetOk("clone_source_vmref")
+				assert.False(t, ok, "no clone source should be set")
+				return
+			}
+			assert.Equal(t, c.expectLineage, state.Get("clone_source"))
+			assert.Equal(t, c.expectLineage["clone_type"] == "full", state.Get("full_clone"))
+		})
+	}
+}
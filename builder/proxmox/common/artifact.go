@@ -9,7 +9,9 @@ import (
 	"strconv"
 
 	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	registryimage "github.com/hashicorp/packer-plugin-sdk/packer/registry/image"
 )
 
 type Artifact struct {
@@ -42,11 +44,48 @@ func (a *Artifact) String() string {
 }
 
 func (a *Artifact) State(name string) interface{} {
+	if name == registryimage.ArtifactStateURI {
+		return a.stateHCPPackerRegistryMetadata()
+	}
 	return a.StateData[name]
 }
 
+// stateHCPPackerRegistryMetadata describes the template for the HCP Packer
+// registry. The image ID is the VM ID of the template, as used by clone_vm_id,
+// and the region is the cluster, or the node if it isn't in one.
+func (a *Artifact) stateHCPPackerRegistryMetadata() interface{} {
+	metadata, _ := a.StateData["metadata"].(map[string]string)
+	region := metadata["node"]
+	if metadata["cluster_name"] != "" {
+		region = metadata["cluster_name"]
+	}
+
+	// FromArtifact only fails for a nil artifact
+	img, _ := registryimage.FromArtifact(a,
+		registryimage.WithProvider("proxmox"),
+		registryimage.WithRegion(region),
+		registryimage.WithSourceID(metadata["source_image_id"]),
+	)
+	for key, value := range metadata {
+		img.Labels[key] = value
+	}
+	return img
+}
+
 func (a *Artifact) Destroy() error {
 	log.Printf("Destroying template: %d", a.templateID)
 	_, err := a.proxmoxClient.DeleteVm(proxmox.NewVmRef(a.templateID))
 	return err
 }
+
+// AddArtifactState sets key in the state of the artifact of the build, for
+// post-processors to read with Artifact.State. Values have to be of types
+// the plugin RPC can send, like map[string]string.
+func AddArtifactState(state multistep.StateBag, key string, value interface{}) {
+	artifactState, ok := state.Get("artifact_state").(map[string]interface{})
+	if !ok {
+		artifactState = map[string]interface{}{}
+		state.Put("artifact_state", artifactState)
+	}
+	artifactState[key] = value
+}
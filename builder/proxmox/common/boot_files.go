@@ -0,0 +1,149 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type bootFilesConfig struct {
+	ISOStoragePool string `mapstructure:"iso_storage_pool"`
+	Device         string `mapstructure:"device"`
+	Label          string `mapstructure:"label"`
+}
+
+// prepareBootFiles validates the boot_files block and adds an ISO with the
+// files of http_directory, http_content and http_templates to the
+// additional ISO files, so it's created, uploaded and attached like any other
+// additional ISO, and no HTTP server is started. The content is rendered by
+// stepRenderBootFiles before the ISO is created, so the templates have
+// access to the same values as when served by the HTTP server.
+func (c *Config) prepareBootFiles() ([]string, []error) {
+	bf := &c.BootFiles
+	if !c.bootFilesEnabled() {
+		return nil, nil
+	}
+
+	var errs []error
+	if bf.Device == "" {
+		log.Printf("boot_files device not set, using default 'sata3'")
+		bf.Device = "sata3"
+	}
+	if bf.Label == "" {
+		log.Printf("boot_files label not set, using default 'OEMDRV'")
+		bf.Label = "OEMDRV"
+	}
+	if bf.ISOStoragePool == "" {
+		errs = append(errs, errors.New("iso_storage_pool must be set for boot_files"))
+	}
+	if c.HTTPDir == "" && len(c.HTTPContent) == 0 && len(c.HTTPTemplates) == 0 {
+		errs = append(errs, errors.New("boot_files requires http_directory, http_content or http_templates"))
+	}
+	// The ISO is created before the VM, so the ID Proxmox assigns isn't
+	// known yet when the templates are rendered
+	if c.VMID == 0 && c.HTTPTemplatesUse(".VMID") {
+		errs = append(errs, errors.New("vm_id must be set when http_content or http_templates use .VMID with boot_files"))
+	}
+	// There is no HTTP server the boot command could point the installer at
+	for _, command := range c.BootCommand {
+		if strings.Contains(command, ".HTTPIP") || strings.Contains(command, ".HTTPPort") {
+			errs = append(errs, errors.New("boot_command can't use .HTTPIP or .HTTPPort with boot_files, the files are on the ISO labeled .BootFilesLabel"))
+			break
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var warnings []string
+	if len(c.HTTPContent) > 0 || len(c.HTTPTemplates) > 0 {
+		warnings = append(warnings, fmt.Sprintf("boot_files: http_content and http_templates are rendered into an ISO that is written to a local temporary file and uploaded to %s until the build ends, secrets in them are stored there as well", bf.ISOStoragePool))
+	}
+
+	iso := additionalISOsConfig{
+		Device:         bf.Device,
+		ISOStoragePool: bf.ISOStoragePool,
+		Unmount:        true,
+		CDConfig: commonsteps.CDConfig{
+			CDContent: map[string]string{},
+			CDLabel:   bf.Label,
+		},
+	}
+	if c.HTTPDir != "" {
+		// The trailing separator puts the content of the directory in the
+		// root of the ISO, like the HTTP server serves it
+		iso.CDFiles = []string{filepath.Clean(c.HTTPDir) + string(filepath.Separator)}
+	}
+	for path := range c.HTTPContent {
+		iso.CDContent[strings.TrimPrefix(path, "/")] = ""
+	}
+	for path := range c.HTTPTemplates {
+		iso.CDContent[strings.TrimPrefix(path, "/")] = ""
+	}
+	c.bootFilesISO = len(c.AdditionalISOFiles)
+	c.AdditionalISOFiles = append(c.AdditionalISOFiles, iso)
+	return warnings, nil
+}
+
+func (c *Config) bootFilesEnabled() bool {
+	return c.BootFiles != (bootFilesConfig{})
+}
+
+// HTTPTemplatesUse reports whether http_content or one of the files of
+// http_templates contains field. Unreadable files are reported by
+// prepareHTTPTemplates.
+func (c *Config) HTTPTemplatesUse(field string) bool {
+	for _, template := range c.HTTPContent {
+		if strings.Contains(template, field) {
+			return true
+		}
+	}
+	for _, file := range c.HTTPTemplates {
+		template, err := os.ReadFile(file)
+		if err == nil && strings.Contains(string(template), field) {
+			return true
+		}
+	}
+	return false
+}
+
+// stepRenderBootFiles renders http_content and http_templates into the
+// content of the boot_files ISO. The VM doesn't exist yet, so the VM ID is
+// the one of vm_id, which prepareBootFiles requires if the templates use it,
+// and there is no HTTP server.
+type stepRenderBootFiles struct{}
+
+func (s *stepRenderBootFiles) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+
+	if !c.bootFilesEnabled() {
+		return multistep.ActionContinue
+	}
+
+	content, err := renderHTTPContent(c, c.httpTemplateData(c.VMID, "", 0))
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	// The map is shared with the step creating the ISO
+	isoContent := c.AdditionalISOFiles[c.bootFilesISO].CDContent
+	for path, rendered := range content {
+		isoContent[strings.TrimPrefix(path, "/")] = rendered
+	}
+	return multistep.ActionContinue
+}
+
+func (s *stepRenderBootFiles) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,223 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestBootFiles(t *testing.T) {
+	bootFilesTest := []struct {
+		name            string
+		config          map[string]interface{}
+		expectFailure   bool
+		expectWarning   bool
+		expectISO       bool
+		expectedDevice  string
+		expectedLabel   string
+		expectedContent []string
+	}{
+		{
+			name:   "no boot files, no error",
+			config: map[string]interface{}{"http_content": map[string]string{"/ks.cfg": "text"}},
+		},
+		{
+			name: "boot files with defaults, no error",
+			config: map[string]interface{}{
+				"boot_files":   map[string]interface{}{"iso_storage_pool": "local"},
+				"http_content": map[string]string{"/ks.cfg": "text"},
+			},
+			expectWarning:   true,
+			expectISO:       true,
+			expectedDevice:  "sata3",
+			expectedLabel:   "OEMDRV",
+			expectedContent: []string{"ks.cfg"},
+		},
+		{
+			name: "boot files with device and label, no error",
+			config: map[string]interface{}{
+				"boot_files":   map[string]interface{}{"iso_storage_pool": "local", "device": "ide3", "label": "cidata"},
+				"http_content": map[string]string{"/user-data": "#cloud-config", "/meta-data": ""},
+			},
+			expectWarning:   true,
+			expectISO:       true,
+			expectedDevice:  "ide3",
+			expectedLabel:   "cidata",
+			expectedContent: []string{"user-data", "meta-data"},
+		},
+		{
+			name: "boot files without storage pool, fail",
+			config: map[string]interface{}{
+				"boot_files":   map[string]interface{}{"label": "OEMDRV"},
+				"http_content": map[string]string{"/ks.cfg": "text"},
+			},
+			expectFailure: true,
+		},
+		{
+			name: "boot files without files, fail",
+			config: map[string]interface{}{
+				"boot_files": map[string]interface{}{"iso_storage_pool": "local"},
+			},
+			expectFailure: true,
+		},
+		{
+			name: "boot files using the VM ID with vm_id, no error",
+			config: map[string]interface{}{
+				"boot_files":   map[string]interface{}{"iso_storage_pool": "local"},
+				"http_content": map[string]string{"/ks.cfg": "network --hostname=vm-{{ .VMID }}"},
+				"vm_id":        100,
+			},
+			expectWarning:   true,
+			expectISO:       true,
+			expectedDevice:  "sata3",
+			expectedLabel:   "OEMDRV",
+			expectedContent: []string{"ks.cfg"},
+		},
+		{
+			name: "boot files from http_directory only, no warning",
+			config: map[string]interface{}{
+				"boot_files":     map[string]interface{}{"iso_storage_pool": "local"},
+				"http_directory": t.TempDir(),
+			},
+			expectISO:      true,
+			expectedDevice: "sata3",
+			expectedLabel:  "OEMDRV",
+		},
+		{
+			name: "boot command using the label of the boot files, no error",
+			config: map[string]interface{}{
+				"boot_files":   map[string]interface{}{"iso_storage_pool": "local"},
+				"http_content": map[string]string{"/ks.cfg": "text"},
+				"boot_command": []string{"<tab> inst.ks=hd:LABEL={{ .BootFilesLabel }}:/ks.cfg<enter>"},
+			},
+			expectWarning:   true,
+			expectISO:       true,
+			expectedDevice:  "sata3",
+			expectedLabel:   "OEMDRV",
+			expectedContent: []string{"ks.cfg"},
+		},
+		{
+			name: "boot command using the HTTP server with boot files, fail",
+			config: map[string]interface{}{
+				"boot_files":   map[string]interface{}{"iso_storage_pool": "local"},
+				"http_content": map[string]string{"/ks.cfg": "text"},
+				"boot_command": []string{"<tab> inst.ks=http://{{ .HTTPIP }}:{{ .HTTPPort }}/ks.cfg<enter>"},
+			},
+			expectFailure: true,
+		},
+		{
+			name: "boot files using the VM ID without vm_id, fail",
+			config: map[string]interface{}{
+				"boot_files":   map[string]interface{}{"iso_storage_pool": "local"},
+				"http_content": map[string]string{"/ks.cfg": "network --hostname=vm-{{ .VMID }}"},
+			},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range bootFilesTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for key, value := range tt.config {
+				cfg[key] = value
+			}
+
+			var c Config
+			_, warnings, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+			if err != nil {
+				return
+			}
+
+			hasWarning := false
+			for _, w := range warnings {
+				if strings.Contains(w, "boot_files") {
+					hasWarning = true
+				}
+			}
+			if hasWarning != tt.expectWarning {
+				t.Errorf("expected boot_files warning: %t, got warnings %v", tt.expectWarning, warnings)
+			}
+
+			if !tt.expectISO {
+				if len(c.AdditionalISOFiles) != 0 {
+					t.Errorf("expected no additional ISO, got %d", len(c.AdditionalISOFiles))
+				}
+				return
+			}
+			if len(c.AdditionalISOFiles) != 1 {
+				t.Fatalf("expected 1 additional ISO, got %d", len(c.AdditionalISOFiles))
+			}
+			iso := c.AdditionalISOFiles[c.bootFilesISO]
+			if iso.Device != tt.expectedDevice {
+				t.Errorf("expected device %q, got %q", tt.expectedDevice, iso.Device)
+			}
+			if iso.CDLabel != tt.expectedLabel {
+				t.Errorf("expected label %q, got %q", tt.expectedLabel, iso.CDLabel)
+			}
+			if !iso.Unmount {
+				t.Error("expected the ISO to be unmounted")
+			}
+			for _, path := range tt.expectedContent {
+				if _, ok := iso.CDContent[path]; !ok {
+					t.Errorf("expected %s in the ISO, got %v", path, iso.CDContent)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderBootFiles(t *testing.T) {
+	templateFile := filepath.Join(t.TempDir(), "ks.cfg.pkrtpl")
+	if err := os.WriteFile(templateFile, []byte("network --hostname={{ .VMName }}-{{ .VMID }} --ip={{ .IP }}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := mandatoryConfig(t)
+	cfg["vm_name"] = "rocky"
+	cfg["vm_id"] = 110
+	cfg["build_network"] = map[string]interface{}{"ip": "192.0.2.50/24"}
+	cfg["boot_files"] = map[string]interface{}{"iso_storage_pool": "local"}
+	cfg["http_content"] = map[string]string{"/post.sh": "echo {{ .Node }}"}
+	cfg["http_templates"] = map[string]string{"/ks.cfg": templateFile}
+
+	var c Config
+	_, _, err := c.Prepare(&c, cfg)
+	if err != nil {
+		t.Fatalf("unexpected failure to prepare config: %s", err)
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("config", &c)
+
+	step := &stepRenderBootFiles{}
+	action := step.Run(context.TODO(), state)
+	if action != multistep.ActionContinue {
+		t.Fatalf("expected action continue, got %v: %v", action, state.Get("error"))
+	}
+
+	expected := map[string]string{
+		"post.sh": "echo my-proxmox",
+		"ks.cfg":  "network --hostname=rocky-110 --ip=192.0.2.50",
+	}
+	content := c.AdditionalISOFiles[c.bootFilesISO].CDContent
+	for path, rendered := range expected {
+		if content[path] != rendered {
+			t.Errorf("expected %s to be %q, got %q", path, rendered, content[path])
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+type buildNetworkConfig struct {
+	IP      string   `mapstructure:"ip"`
+	Netmask string   `mapstructure:"netmask"`
+	Gateway string   `mapstructure:"gateway"`
+	DNS     []string `mapstructure:"dns"`
+
+	prefixLength int
+}
+
+// buildNetworkTemplateData holds the build_network settings for the
+// boot_command and http_content templates. All fields are empty if
+// build_network is not set.
+type buildNetworkTemplateData struct {
+	IP           string
+	Netmask      string
+	PrefixLength int
+	Gateway      string
+	DNS          string
+	DNSServers   []string
+}
+
+// prepareBuildNetwork validates the build_network block and sets ip to the
+// bare address and netmask to the mask of the network, whichever way they
+// were given. The communicator host defaults to the build IP, so the
+// communicator doesn't need the guest agent to find the VM. It must run
+// after the communicator config is prepared.
+func (c *Config) prepareBuildNetwork() []error {
+	bn := &c.BuildNetwork
+	if bn.IP == "" {
+		if bn.Netmask != "" || bn.Gateway != "" || len(bn.DNS) > 0 {
+			return []error{errors.New("build_network.ip must be specified")}
+		}
+		return nil
+	}
+
+	var errs []error
+	ip, network, err := net.ParseCIDR(bn.IP)
+	if err != nil {
+		ip = net.ParseIP(bn.IP)
+		if ip == nil {
+			return []error{fmt.Errorf("build_network.ip %q is not an IP address or CIDR", bn.IP)}
+		}
+		if bn.Netmask == "" {
+			return []error{errors.New("build_network.netmask must be specified if build_network.ip has no prefix length")}
+		}
+		mask := net.ParseIP(bn.Netmask)
+		if mask == nil || ip.To4() == nil || mask.To4() == nil {
+			return []error{fmt.Errorf("build_network.netmask %q is not an IPv4 netmask", bn.Netmask)}
+		}
+		network = &net.IPNet{IP: ip.Mask(net.IPMask(mask.To4())), Mask: net.IPMask(mask.To4())}
+		if ones, bits := network.Mask.Size(); ones == 0 && bits == 0 {
+			return []error{fmt.Errorf("build_network.netmask %q is not an IPv4 netmask", bn.Netmask)}
+		}
+	} else if bn.Netmask != "" {
+		if mask := net.ParseIP(bn.Netmask); mask == nil || !mask.Equal(net.IP(network.Mask)) {
+			errs = append(errs, fmt.Errorf("build_network.netmask %q doesn't match the prefix length of build_network.ip %q", bn.Netmask, bn.IP))
+		}
+	}
+	bn.IP = ip.String()
+	bn.prefixLength, _ = network.Mask.Size()
+	if ip.To4() != nil {
+		bn.Netmask = net.IP(network.Mask).String()
+	}
+
+	if bn.Gateway != "" {
+		gateway := net.ParseIP(bn.Gateway)
+		if gateway == nil {
+			errs = append(errs, fmt.Errorf("build_network.gateway %q is not an IP address", bn.Gateway))
+		} else if !network.Contains(gateway) {
+			errs = append(errs, fmt.Errorf("build_network.gateway %s is not in the network %s", bn.Gateway, network))
+		}
+	}
+	for idx, server := range bn.DNS {
+		if net.ParseIP(server) == nil {
+			errs = append(errs, fmt.Errorf("build_network.dns[%d] %q is not an IP address", idx, server))
+		}
+	}
+
+	switch c.Comm.Type {
+	case "ssh":
+		if c.Comm.SSHHost == "" {
+			c.Comm.SSHHost = bn.IP
+		}
+	case "winrm":
+		if c.Comm.WinRMHost == "" {
+			c.Comm.WinRMHost = bn.IP
+		}
+	}
+	return errs
+}
+
+// buildNetworkData returns the template data of the build_network block.
+func (c *Config) buildNetworkData() buildNetworkTemplateData {
+	bn := c.BuildNetwork
+	return buildNetworkTemplateData{
+		IP:           bn.IP,
+		Netmask:      bn.Netmask,
+		PrefixLength: bn.prefixLength,
+		Gateway:      bn.Gateway,
+		DNS:          strings.Join(bn.DNS, ","),
+		DNSServers:   bn.DNS,
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"testing"
+)
+
+func TestBuildNetwork(t *testing.T) {
+	buildNetworkTest := []struct {
+		name            string
+		buildNetwork    map[string]interface{}
+		communicator    map[string]interface{}
+		expectFailure   bool
+		expectedIP      string
+		expectedNetmask string
+		expectedPrefix  int
+		expectedSSHHost string
+	}{
+		{
+			name:            "no build network, no error",
+			buildNetwork:    map[string]interface{}{},
+			expectedSSHHost: "",
+		},
+		{
+			name:            "ip with netmask, no error",
+			buildNetwork:    map[string]interface{}{"ip": "192.0.2.50", "netmask": "255.255.255.0", "gateway": "192.0.2.1", "dns": []string{"192.0.2.53"}},
+			expectedIP:      "192.0.2.50",
+			expectedNetmask: "255.255.255.0",
+			expectedPrefix:  24,
+			expectedSSHHost: "192.0.2.50",
+		},
+		{
+			name:            "ip in CIDR notation, no error",
+			buildNetwork:    map[string]interface{}{"ip": "192.0.2.50/25"},
+			expectedIP:      "192.0.2.50",
+			expectedNetmask: "255.255.255.128",
+			expectedPrefix:  25,
+			expectedSSHHost: "192.0.2.50",
+		},
+		{
+			name:            "IPv6 in CIDR notation, no error",
+			buildNetwork:    map[string]interface{}{"ip": "2001:db8::50/64", "gateway": "2001:db8::1"},
+			expectedIP:      "2001:db8::50",
+			expectedPrefix:  64,
+			expectedSSHHost: "2001:db8::50",
+		},
+		{
+			name:            "ssh_host set, kept",
+			buildNetwork:    map[string]interface{}{"ip": "192.0.2.50/24"},
+			communicator:    map[string]interface{}{"ssh_host": "build.example.com"},
+			expectedIP:      "192.0.2.50",
+			expectedNetmask: "255.255.255.0",
+			expectedPrefix:  24,
+			expectedSSHHost: "build.example.com",
+		},
+		{
+			name:          "settings without ip, fail",
+			buildNetwork:  map[string]interface{}{"gateway": "192.0.2.1"},
+			expectFailure: true,
+		},
+		{
+			name:          "ip without netmask, fail",
+			buildNetwork:  map[string]interface{}{"ip": "192.0.2.50"},
+			expectFailure: true,
+		},
+		{
+			name:          "invalid netmask, fail",
+			buildNetwork:  map[string]interface{}{"ip": "192.0.2.50", "netmask": "255.0.255.0"},
+			expectFailure: true,
+		},
+		{
+			name:          "netmask not matching prefix length, fail",
+			buildNetwork:  map[string]interface{}{"ip": "192.0.2.50/24", "netmask": "255.255.0.0"},
+			expectFailure: true,
+		},
+		{
+			name:          "gateway outside the network, fail",
+			buildNetwork:  map[string]interface{}{"ip": "192.0.2.50/24", "gateway": "198.51.100.1"},
+			expectFailure: true,
+		},
+		{
+			name:          "invalid dns server, fail",
+			buildNetwork:  map[string]interface{}{"ip": "192.0.2.50/24", "dns": []string{"ns.example.com"}},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range buildNetworkTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			cfg["build_network"] = tt.buildNetwork
+			for key, value := range tt.communicator {
+				cfg[key] = value
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+			if err != nil {
+				return
+			}
+
+			data := c.buildNetworkData()
+			if data.IP != tt.expectedIP {
+				t.Errorf("expected IP %q, got %q", tt.expectedIP, data.IP)
+			}
+			if data.Netmask != tt.expectedNetmask {
+				t.Errorf("expected netmask %q, got %q", tt.expectedNetmask, data.Netmask)
+			}
+			if data.PrefixLength != tt.expectedPrefix {
+				t.Errorf("expected prefix length %d, got %d", tt.expectedPrefix, data.PrefixLength)
+			}
+			if c.Comm.SSHHost != tt.expectedSSHHost {
+				t.Errorf("expected ssh_host %q, got %q", tt.expectedSSHHost, c.Comm.SSHHost)
+			}
+		})
+	}
+}
@@ -55,49 +55,86 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook,
 		&stepStartVM{
 			vmCreator: b.vmCreator,
 		},
-		commonsteps.HTTPServerFromHTTPConfig(&b.config.HTTPConfig),
+		&stepLineage{},
+		&stepHTTPServer{},
 		&stepTypeBootCommand{
 			BootConfig: b.config.BootConfig,
 			Ctx:        b.config.Ctx,
 		},
+		&stepNodeBastion{},
 		&communicator.StepConnect{
 			Config:    comm,
 			Host:      commHost((*comm).Host()),
 			SSHConfig: (*comm).SSHConfigFunc(),
 		},
-		&commonsteps.StepProvision{},
+		&stepGeneratedData{},
+		&stepCheckpoint{name: checkpointConnected},
+		&stepProvision{},
+		&stepCheckpoint{name: checkpointProvisioned},
 		&commonsteps.StepCleanupTempKeys{
 			Comm: &b.config.Comm,
 		},
 		&stepRemoveCloudInitDrive{},
+		&stepRemoveCheckpoints{},
 		&stepConvertToTemplate{},
 		&stepFinalizeTemplateConfig{},
+		&stepArtifactMetadata{},
 		&stepSuccess{},
 	}
 	preSteps := b.preSteps
-	for idx := range b.config.AdditionalISOFiles {
-		preSteps = append(preSteps,
-			&commonsteps.StepCreateCD{
-				Files:   b.config.AdditionalISOFiles[idx].CDConfig.CDFiles,
-				Content: b.config.AdditionalISOFiles[idx].CDConfig.CDContent,
-				Label:   b.config.AdditionalISOFiles[idx].CDConfig.CDLabel,
-			},
-			&commonsteps.StepDownload{
-				Checksum:    b.config.AdditionalISOFiles[idx].ISOChecksum,
-				Description: "additional ISO",
-				Extension:   b.config.AdditionalISOFiles[idx].TargetExtension,
-				ResultKey:   b.config.AdditionalISOFiles[idx].DownloadPathKey,
-				TargetPath:  b.config.AdditionalISOFiles[idx].DownloadPathKey,
-				Url:         b.config.AdditionalISOFiles[idx].ISOUrls,
-			},
-			&stepUploadAdditionalISO{
-				ISO: &b.config.AdditionalISOFiles[idx],
-			},
-		)
-	}
-
-	steps := append(preSteps, coreSteps...)
-	steps = append(steps, b.postSteps...)
+	// A resumed VM already has its ISOs and hookscript attached
+	if b.config.ResumeFromSnapshot == "" {
+		// The boot_files ISO is created with the additional ISOs, its content
+		// is rendered once the builder specific steps prepared the SSH key
+		preSteps = append(preSteps, &stepRenderBootFiles{})
+		for idx := range b.config.AdditionalISOFiles {
+			preSteps = append(preSteps,
+				&commonsteps.StepCreateCD{
+					Files:   b.config.AdditionalISOFiles[idx].CDConfig.CDFiles,
+					Content: b.config.AdditionalISOFiles[idx].CDConfig.CDContent,
+					Label:   b.config.AdditionalISOFiles[idx].CDConfig.CDLabel,
+				},
+				&commonsteps.StepDownload{
+					Checksum:    b.config.AdditionalISOFiles[idx].ISOChecksum,
+					Description: "additional ISO",
+					Extension:   b.config.AdditionalISOFiles[idx].TargetExtension,
+					ResultKey:   b.config.AdditionalISOFiles[idx].DownloadPathKey,
+					TargetPath:  b.config.AdditionalISOFiles[idx].DownloadPathKey,
+					Url:         b.config.AdditionalISOFiles[idx].ISOUrls,
+				},
+				&stepUploadAdditionalISO{
+					ISO: &b.config.AdditionalISOFiles[idx],
+				},
+			)
+		}
+
+		preSteps = append(preSteps, &stepUploadHookscript{})
+	}
+
+	// Permissions and storage are validated before the builder specific
+	// steps, so problems are reported before any ISO or disk image is
+	// downloaded and uploaded
+	steps := []multistep.Step{
+		&stepCheckPermissions{},
+		&stepValidateStorage{},
+	}
+	if b.config.DryRun {
+		// Nothing is uploaded or created in dry runs, and only downloaded if
+		// the VM config depends on it
+		if preparer, ok := b.vmCreator.(ProxmoxDryRunPreparer); ok {
+			steps = append(steps, preparer.DryRunSteps()...)
+		}
+		steps = append(steps, &stepDryRun{vmCreator: b.vmCreator})
+	} else {
+		steps = append(steps, preSteps...)
+		steps = append(steps, coreSteps...)
+		steps = append(steps, b.postSteps...)
+	}
+	if b.config.KeepVMOnFailure && b.config.cleansUpOnError() {
+		for idx := range steps {
+			steps[idx] = &stepRecordFailure{Step: steps[idx]}
+		}
+	}
 	// Run the steps
 	b.runner = commonsteps.NewRunner(steps, b.config.PackerConfig, ui)
 	b.runner.Run(ctx, state)
@@ -110,6 +147,10 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook,
 		return nil, errors.New("build was cancelled")
 	}
 
+	if b.config.DryRun {
+		return nil, nil
+	}
+
 	// Verify that the template_id was set properly, otherwise we didn't progress through the last step
 	tplID, ok := state.Get("template_id").(int)
 	if !ok {
@@ -122,6 +163,11 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook,
 		proxmoxClient: b.proxmoxClient,
 		StateData:     map[string]interface{}{"generated_data": state.Get("generated_data")},
 	}
+	if artifactState, ok := state.Get("artifact_state").(map[string]interface{}); ok {
+		for key, value := range artifactState {
+			artifact.StateData[key] = value
+		}
+	}
 
 	return artifact, nil
 }
@@ -137,10 +183,16 @@ func commHost(host string) func(state multistep.StateBag) (string, error) {
 	return getVMIP
 }
 
+type agentNetworkReader interface {
+	GetVmAgentNetworkInterfaces(vmr *proxmox.VmRef) ([]proxmox.AgentNetworkInterface, error)
+}
+
+var _ agentNetworkReader = &proxmox.Client{}
+
 // Reads the first non-loopback interface's IP address from the VM.
 // qemu-guest-agent package must be installed on the VM
 func getVMIP(state multistep.StateBag) (string, error) {
-	client := state.Get("proxmoxClient").(*proxmox.Client)
+	client := state.Get("proxmoxClient").(agentNetworkReader)
 	config := state.Get("config").(*Config)
 	vmRef := state.Get("vmRef").(*proxmox.VmRef)
 
@@ -2,7 +2,7 @@
 // SPDX-License-Identifier: MPL-2.0
 
 //go:generate packer-sdc struct-markdown
-//go:generate packer-sdc mapstructure-to-hcl2 -type Config,NICConfig,diskConfig,rng0Config,pciDeviceConfig,vgaConfig,additionalISOsConfig,efiConfig
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,NICConfig,diskConfig,rng0Config,pciDeviceConfig,vgaConfig,additionalISOsConfig,efiConfig,tpmConfig,windowsUnattendConfig,hookscriptConfig,buildNetworkConfig,bootFilesConfig,nodeSSHConfig
 
 package proxmox
 
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/url"
 	"os"
 	"regexp"
@@ -31,6 +32,12 @@ import (
 type Config struct {
 	common.PackerConfig    `mapstructure:",squash"`
 	commonsteps.HTTPConfig `mapstructure:",squash"`
+	HTTPTemplates          map[string]string `mapstructure:"http_templates"`
+	HTTPBindCIDR           string            `mapstructure:"http_bind_cidr"`
+	HTTPTunnel             bool              `mapstructure:"http_tunnel"`
+	HTTPTunnelBridge       string            `mapstructure:"http_tunnel_bridge"`
+	NodeSSH                nodeSSHConfig     `mapstructure:"node_ssh"`
+	SSHBastionNode         bool              `mapstructure:"ssh_bastion_node"`
 	bootcommand.BootConfig `mapstructure:",squash"`
 	BootKeyInterval        time.Duration       `mapstructure:"boot_key_interval"`
 	Comm                   communicator.Config `mapstructure:",squash"`
@@ -59,10 +66,12 @@ type Config struct {
 	BIOS           string            `mapstructure:"bios"`
 	EFIConfig      efiConfig         `mapstructure:"efi_config"`
 	EFIDisk        string            `mapstructure:"efidisk"`
+	TPMConfig      tpmConfig         `mapstructure:"tpm_config"`
 	Machine        string            `mapstructure:"machine"`
 	Rng0           rng0Config        `mapstructure:"rng0"`
 	VGA            vgaConfig         `mapstructure:"vga"`
 	NICs           []NICConfig       `mapstructure:"network_adapters"`
+	MACAddressHook []string          `mapstructure:"mac_address_hook"`
 	Disks          []diskConfig      `mapstructure:"disks"`
 	PCIDevices     []pciDeviceConfig `mapstructure:"pci_devices"`
 	Serials        []string          `mapstructure:"serials"`
@@ -71,14 +80,32 @@ type Config struct {
 	Onboot         bool              `mapstructure:"onboot"`
 	DisableKVM     bool              `mapstructure:"disable_kvm"`
 
+	HardwareProfile string `mapstructure:"hardware_profile"`
+
 	TemplateName        string `mapstructure:"template_name"`
 	TemplateDescription string `mapstructure:"template_description"`
+	RecordLineage       bool   `mapstructure:"record_lineage"`
+
+	ExtraConfig         map[string]string `mapstructure:"extra_config"`
+	TemplateExtraConfig map[string]string `mapstructure:"template_extra_config"`
+	Hookscript          hookscriptConfig  `mapstructure:"hookscript"`
+
+	Checkpoints        bool   `mapstructure:"checkpoints"`
+	ResumeFromSnapshot string `mapstructure:"resume_from_snapshot"`
+	KeepVMOnFailure    bool   `mapstructure:"keep_vm_on_failure"`
 
 	CloudInit            bool   `mapstructure:"cloud_init"`
 	CloudInitStoragePool string `mapstructure:"cloud_init_storage_pool"`
 
 	AdditionalISOFiles []additionalISOsConfig `mapstructure:"additional_iso_files"`
 	VMInterface        string                 `mapstructure:"vm_interface"`
+	BuildNetwork       buildNetworkConfig     `mapstructure:"build_network"`
+	BootFiles          bootFilesConfig        `mapstructure:"boot_files"`
+	bootFilesISO       int
+
+	WindowsUnattend windowsUnattendConfig `mapstructure:"windows_unattend"`
+
+	DryRun bool `mapstructure:"dry_run"`
 
 	Ctx interpolate.Context `mapstructure-to-hcl2:",skip"`
 }
@@ -95,13 +122,15 @@ type additionalISOsConfig struct {
 }
 
 type NICConfig struct {
-	Model        string `mapstructure:"model"`
-	PacketQueues int    `mapstructure:"packet_queues"`
-	MACAddress   string `mapstructure:"mac_address"`
-	MTU          int    `mapstructure:"mtu"`
-	Bridge       string `mapstructure:"bridge"`
-	VLANTag      string `mapstructure:"vlan_tag"`
-	Firewall     bool   `mapstructure:"firewall"`
+	Model             string `mapstructure:"model"`
+	PacketQueues      int    `mapstructure:"packet_queues"`
+	MACAddress        string `mapstructure:"mac_address"`
+	MACAddressPrefix  string `mapstructure:"mac_address_prefix"`
+	ReservedIPAddress string `mapstructure:"reserved_ip_address"`
+	MTU               int    `mapstructure:"mtu"`
+	Bridge            string `mapstructure:"bridge"`
+	VLANTag           string `mapstructure:"vlan_tag"`
+	Firewall          bool   `mapstructure:"firewall"`
 }
 type diskConfig struct {
 	Type            string `mapstructure:"type"`
@@ -119,6 +148,31 @@ type efiConfig struct {
 	PreEnrolledKeys bool   `mapstructure:"pre_enrolled_keys"`
 	EFIType         string `mapstructure:"efi_type"`
 }
+type hookscriptConfig struct {
+	File           string `mapstructure:"file"`
+	StoragePool    string `mapstructure:"storage_pool"`
+	Volume         string `mapstructure:"volume"`
+	KeepOnTemplate bool   `mapstructure:"keep_on_template"`
+}
+
+// storagePool returns the storage the hookscript is uploaded to or the
+// storage of the hookscript volume, or an empty string if the volume isn't a
+// snippets volume.
+func (h hookscriptConfig) storagePool() string {
+	if h.File != "" {
+		return h.StoragePool
+	}
+	storage, volume, ok := strings.Cut(h.Volume, ":")
+	if !ok || storage == "" || !strings.HasPrefix(volume, "snippets/") || volume == "snippets/" {
+		return ""
+	}
+	return storage
+}
+
+type tpmConfig struct {
+	TPMStoragePool string `mapstructure:"tpm_storage_pool"`
+	Version        string `mapstructure:"tpm_version"`
+}
 
 // - `rng0` (object): Configure Random Number Generator via VirtIO.
 // A virtual hardware-RNG can be used to provide entropy from the host system to a guest VM helping avoid entropy starvation which might cause the guest system slow down.
@@ -258,6 +312,7 @@ func (c *Config) Prepare(upper interface{}, raws ...interface{}) ([]string, []st
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"boot_command",
+				"http_content",
 			},
 		},
 	}, raws...)
@@ -292,6 +347,13 @@ func (c *Config) Prepare(upper interface{}, raws ...interface{}) ([]string, []st
 	if c.Token == "" {
 		c.Token = os.Getenv("PROXMOX_TOKEN")
 	}
+	if !c.DryRun && os.Getenv("PROXMOX_DRY_RUN") != "" {
+		dryRun, err := strconv.ParseBool(os.Getenv("PROXMOX_DRY_RUN"))
+		if err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("PROXMOX_DRY_RUN: %s", err))
+		}
+		c.DryRun = dryRun
+	}
 	if c.TaskTimeout == 0 {
 		c.TaskTimeout = 60 * time.Second
 	}
@@ -306,6 +368,14 @@ func (c *Config) Prepare(upper interface{}, raws ...interface{}) ([]string, []st
 		c.BootKeyInterval = 5 * time.Millisecond
 	}
 
+	// Presets of the hardware profile must be applied before any of the
+	// defaults below, so they only fill in options that are not set.
+	profileWarnings, err := c.applyHardwareProfile()
+	if err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+	warnings = append(warnings, profileWarnings...)
+
 	// Technically Proxmox VMIDs are unsigned 32bit integers, but are limited to
 	// the range 100-999999999. Source:
 	// https://pve-devel.pve.proxmox.narkive.com/Pa6mH1OP/avoiding-vmid-reuse#post8
@@ -387,6 +457,16 @@ func (c *Config) Prepare(upper interface{}, raws ...interface{}) ([]string, []st
 	errs = packersdk.MultiErrorAppend(errs, c.Comm.Prepare(&c.Ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.BootConfig.Prepare(&c.Ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.HTTPConfig.Prepare(&c.Ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.prepareBuildNetwork()...)
+	errs = packersdk.MultiErrorAppend(errs, c.prepareHTTPTemplates()...)
+	if c.HTTPBindCIDR != "" {
+		if _, _, err := net.ParseCIDR(c.HTTPBindCIDR); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("http_bind_cidr %q is not a CIDR", c.HTTPBindCIDR))
+		}
+		if c.HTTPAddress != "0.0.0.0" {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("http_bind_cidr can't be used with http_bind_address"))
+		}
+	}
 
 	// Required configurations that will display errors if not set
 	if c.Username == "" {
@@ -427,7 +507,60 @@ func (c *Config) Prepare(upper interface{}, raws ...interface{}) ([]string, []st
 		if (nic.MTU < 0) || (nic.MTU > 65520) {
 			errs = packersdk.MultiErrorAppend(errs, errors.New("network_adapters[%d].mtu only positive values up to 65520 are supported"))
 		}
+		if nic.MACAddress == macAddressAutoDeterministic {
+			if nic.MACAddressPrefix == "" {
+				c.NICs[idx].MACAddressPrefix = defaultMACAddressPrefix
+			} else if _, err := parseMACAddressPrefix(nic.MACAddressPrefix); err != nil {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d].mac_address_prefix %s", idx, err))
+			}
+		} else if nic.MACAddressPrefix != "" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d].mac_address_prefix requires mac_address %q", idx, macAddressAutoDeterministic))
+		}
+		if nic.ReservedIPAddress != "" {
+			if nic.MACAddress == "" {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d].reserved_ip_address requires a mac_address", idx))
+			}
+			if net.ParseIP(nic.ReservedIPAddress) == nil {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("network_adapters[%d].reserved_ip_address %q is not an IP address", idx, nic.ReservedIPAddress))
+			}
+		}
 	}
+	if len(c.MACAddressHook) > 0 {
+		hasMACAddress := false
+		for _, nic := range c.NICs {
+			hasMACAddress = hasMACAddress || nic.MACAddress != ""
+		}
+		if !hasMACAddress {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("mac_address_hook requires a network adapter with a mac_address"))
+		}
+	}
+	// The Autounattend ISO is added to the additional ISO files, so it is
+	// validated and uploaded along with them
+	unattendWarnings, unattendErrs := c.prepareWindowsUnattend()
+	warnings = append(warnings, unattendWarnings...)
+	errs = packersdk.MultiErrorAppend(errs, unattendErrs...)
+	bootFilesWarnings, bootFilesErrs := c.prepareBootFiles()
+	warnings = append(warnings, bootFilesWarnings...)
+	errs = packersdk.MultiErrorAppend(errs, bootFilesErrs...)
+	if c.HTTPTunnel {
+		if c.bootFilesEnabled() {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("http_tunnel can't be used with boot_files"))
+		}
+		// The port is opened on the address of the node on the bridge the
+		// VM reaches it on
+		if c.HTTPTunnelBridge == "" {
+			if len(c.NICs) == 0 {
+				errs = packersdk.MultiErrorAppend(errs, errors.New("http_tunnel requires http_tunnel_bridge or a network adapter, to find the address of the node on its bridge"))
+			} else {
+				c.HTTPTunnelBridge = c.NICs[0].Bridge
+			}
+		}
+	} else if c.HTTPTunnelBridge != "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("http_tunnel_bridge requires http_tunnel"))
+	}
+	nodeSSHWarnings, nodeSSHErrs := c.prepareNodeSSH()
+	warnings = append(warnings, nodeSSHWarnings...)
+	errs = packersdk.MultiErrorAppend(errs, nodeSSHErrs...)
 	for idx := range c.AdditionalISOFiles {
 		// Check AdditionalISO config
 		// Either a pre-uploaded ISO should be referenced in iso_file, OR a URL
@@ -519,6 +652,77 @@ func (c *Config) Prepare(upper interface{}, raws ...interface{}) ([]string, []st
 			errs = packersdk.MultiErrorAppend(errs, errors.New("efi_storage_pool not set for efi_config"))
 		}
 	}
+	if c.TPMConfig.TPMStoragePool != "" {
+		if c.TPMConfig.Version == "" {
+			log.Printf("TPM state defined, but no tpm_version given, using v2.0")
+			c.TPMConfig.Version = "v2.0"
+		}
+		if !(c.TPMConfig.Version == "v1.2" || c.TPMConfig.Version == "v2.0") {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("tpm_version must be one of \"v1.2\", \"v2.0\""))
+		}
+		if c.BIOS != "ovmf" {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("tpm_config requires bios to be set to ovmf"))
+		}
+	} else {
+		if c.TPMConfig.Version != "" {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("tpm_storage_pool not set for tpm_config"))
+		}
+	}
+	errs = packersdk.MultiErrorAppend(errs, validateExtraConfig("extra_config", c.ExtraConfig, false)...)
+	errs = packersdk.MultiErrorAppend(errs, validateExtraConfig("template_extra_config", c.TemplateExtraConfig, true)...)
+	if c.Hookscript != (hookscriptConfig{}) {
+		switch {
+		case c.Hookscript.File == "" && c.Hookscript.Volume == "":
+			errs = packersdk.MultiErrorAppend(errs, errors.New("one of hookscript.file or hookscript.volume must be specified"))
+		case c.Hookscript.File != "" && c.Hookscript.Volume != "":
+			errs = packersdk.MultiErrorAppend(errs, errors.New("hookscript.file and hookscript.volume can't both be specified"))
+		case c.Hookscript.File != "":
+			// Snippets can't be uploaded through the API, the file is copied
+			// to the storage over node_ssh
+			if _, err := os.Stat(c.Hookscript.File); err != nil {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("hookscript.file: %s", err))
+			}
+			if c.Hookscript.StoragePool == "" {
+				errs = packersdk.MultiErrorAppend(errs, errors.New("hookscript.storage_pool must be specified with hookscript.file"))
+			}
+		default:
+			if c.Hookscript.StoragePool != "" {
+				errs = packersdk.MultiErrorAppend(errs, errors.New("hookscript.storage_pool only applies to hookscript.file"))
+			}
+			if c.Hookscript.storagePool() == "" {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("hookscript.volume %q must be a snippets volume like local:snippets/hook.pl", c.Hookscript.Volume))
+			}
+		}
+		if _, ok := c.ExtraConfig["hookscript"]; ok {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("extra_config: \"hookscript\" is managed by the plugin, use hookscript instead"))
+		}
+		if _, ok := c.TemplateExtraConfig["hookscript"]; ok {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("template_extra_config: \"hookscript\" is managed by the plugin, use hookscript instead"))
+		}
+		if c.Username != "root@pam" {
+			// Proxmox checks this itself, not through a privilege
+			warnings = append(warnings, "Proxmox only allows root@pam to set hookscripts, setting the hookscript will fail for other users and API tokens")
+		}
+	}
+	if c.Checkpoints && c.PackerOnError != "abort" {
+		warnings = append(warnings, "checkpoints are only kept with -on-error=abort, otherwise the VM is deleted when the build fails")
+	}
+	if c.KeepVMOnFailure && !c.cleansUpOnError() {
+		warnings = append(warnings, fmt.Sprintf("keep_vm_on_failure only applies with -on-error=cleanup, with -on-error=%s the VM is handled by Packer", c.PackerOnError))
+	}
+	if c.ResumeFromSnapshot != "" {
+		if c.VMID == 0 {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("vm_id must be set to the VM to resume with resume_from_snapshot"))
+		}
+		if !validSnapshotName.MatchString(c.ResumeFromSnapshot) {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("resume_from_snapshot: %q is not a valid snapshot name", c.ResumeFromSnapshot))
+		}
+		// The VM only knows the temporary key of the aborted build, which is
+		// gone, a new one would be generated for this build
+		if c.usesTemporarySSHKey() {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("resume_from_snapshot requires ssh_password, ssh_private_key_file or ssh_agent_auth, the VM doesn't accept a new temporary SSH key"))
+		}
+	}
 	if c.Rng0 != (rng0Config{}) {
 		if !(c.Rng0.Source == "/dev/urandom" || c.Rng0.Source == "/dev/random" || c.Rng0.Source == "/dev/hwrng") {
 			errs = packersdk.MultiErrorAppend(errs, errors.New("source must be one of \"/dev/urandom\", \"/dev/random\", \"/dev/hwrng\""))
@@ -565,5 +769,5 @@ func (c *Config) Prepare(upper interface{}, raws ...interface{}) ([]string, []st
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, warnings, errs
 	}
-	return nil, warnings, nil
+	return append([]string{}, generatedDataKeys...), warnings, nil
 }
@@ -24,6 +24,12 @@ type FlatConfig struct {
 	HTTPPortMax               *int                       `mapstructure:"http_port_max" cty:"http_port_max" hcl:"http_port_max"`
 	HTTPAddress               *string                    `mapstructure:"http_bind_address" cty:"http_bind_address" hcl:"http_bind_address"`
 	HTTPInterface             *string                    `mapstructure:"http_interface" undocumented:"true" cty:"http_interface" hcl:"http_interface"`
+	HTTPTemplates             map[string]string          `mapstructure:"http_templates" cty:"http_templates" hcl:"http_templates"`
+	HTTPBindCIDR              *string                    `mapstructure:"http_bind_cidr" cty:"http_bind_cidr" hcl:"http_bind_cidr"`
+	HTTPTunnel                *bool                      `mapstructure:"http_tunnel" cty:"http_tunnel" hcl:"http_tunnel"`
+	HTTPTunnelBridge          *string                    `mapstructure:"http_tunnel_bridge" cty:"http_tunnel_bridge" hcl:"http_tunnel_bridge"`
+	NodeSSH                   *FlatnodeSSHConfig         `mapstructure:"node_ssh" cty:"node_ssh" hcl:"node_ssh"`
+	SSHBastionNode            *bool                      `mapstructure:"ssh_bastion_node" cty:"ssh_bastion_node" hcl:"ssh_bastion_node"`
 	BootGroupInterval         *string                    `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
 	BootWait                  *string                    `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
 	BootCommand               []string                   `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
@@ -98,10 +104,12 @@ type FlatConfig struct {
 	BIOS                      *string                    `mapstructure:"bios" cty:"bios" hcl:"bios"`
 	EFIConfig                 *FlatefiConfig             `mapstructure:"efi_config" cty:"efi_config" hcl:"efi_config"`
 	EFIDisk                   *string                    `mapstructure:"efidisk" cty:"efidisk" hcl:"efidisk"`
+	TPMConfig                 *FlattpmConfig             `mapstructure:"tpm_config" cty:"tpm_config" hcl:"tpm_config"`
 	Machine                   *string                    `mapstructure:"machine" cty:"machine" hcl:"machine"`
 	Rng0                      *Flatrng0Config            `mapstructure:"rng0" cty:"rng0" hcl:"rng0"`
 	VGA                       *FlatvgaConfig             `mapstructure:"vga" cty:"vga" hcl:"vga"`
 	NICs                      []FlatNICConfig            `mapstructure:"network_adapters" cty:"network_adapters" hcl:"network_adapters"`
+	MACAddressHook            []string                   `mapstructure:"mac_address_hook" cty:"mac_address_hook" hcl:"mac_address_hook"`
 	Disks                     []FlatdiskConfig           `mapstructure:"disks" cty:"disks" hcl:"disks"`
 	PCIDevices                []FlatpciDeviceConfig      `mapstructure:"pci_devices" cty:"pci_devices" hcl:"pci_devices"`
 	Serials                   []string                   `mapstructure:"serials" cty:"serials" hcl:"serials"`
@@ -109,12 +117,24 @@ type FlatConfig struct {
 	SCSIController            *string                    `mapstructure:"scsi_controller" cty:"scsi_controller" hcl:"scsi_controller"`
 	Onboot                    *bool                      `mapstructure:"onboot" cty:"onboot" hcl:"onboot"`
 	DisableKVM                *bool                      `mapstructure:"disable_kvm" cty:"disable_kvm" hcl:"disable_kvm"`
+	HardwareProfile           *string                    `mapstructure:"hardware_profile" cty:"hardware_profile" hcl:"hardware_profile"`
 	TemplateName              *string                    `mapstructure:"template_name" cty:"template_name" hcl:"template_name"`
 	TemplateDescription       *string                    `mapstructure:"template_description" cty:"template_description" hcl:"template_description"`
+	RecordLineage             *bool                      `mapstructure:"record_lineage" cty:"record_lineage" hcl:"record_lineage"`
+	ExtraConfig               map[string]string          `mapstructure:"extra_config" cty:"extra_config" hcl:"extra_config"`
+	TemplateExtraConfig       map[string]string          `mapstructure:"template_extra_config" cty:"template_extra_config" hcl:"template_extra_config"`
+	Hookscript                *FlathookscriptConfig      `mapstructure:"hookscript" cty:"hookscript" hcl:"hookscript"`
+	Checkpoints               *bool                      `mapstructure:"checkpoints" cty:"checkpoints" hcl:"checkpoints"`
+	ResumeFromSnapshot        *string                    `mapstructure:"resume_from_snapshot" cty:"resume_from_snapshot" hcl:"resume_from_snapshot"`
+	KeepVMOnFailure           *bool                      `mapstructure:"keep_vm_on_failure" cty:"keep_vm_on_failure" hcl:"keep_vm_on_failure"`
 	CloudInit                 *bool                      `mapstructure:"cloud_init" cty:"cloud_init" hcl:"cloud_init"`
 	CloudInitStoragePool      *string                    `mapstructure:"cloud_init_storage_pool" cty:"cloud_init_storage_pool" hcl:"cloud_init_storage_pool"`
 	AdditionalISOFiles        []FlatadditionalISOsConfig `mapstructure:"additional_iso_files" cty:"additional_iso_files" hcl:"additional_iso_files"`
 	VMInterface               *string                    `mapstructure:"vm_interface" cty:"vm_interface" hcl:"vm_interface"`
+	BuildNetwork              *FlatbuildNetworkConfig    `mapstructure:"build_network" cty:"build_network" hcl:"build_network"`
+	BootFiles                 *FlatbootFilesConfig       `mapstructure:"boot_files" cty:"boot_files" hcl:"boot_files"`
+	WindowsUnattend           *FlatwindowsUnattendConfig `mapstructure:"windows_unattend" cty:"windows_unattend" hcl:"windows_unattend"`
+	DryRun                    *bool                      `mapstructure:"dry_run" cty:"dry_run" hcl:"dry_run"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -143,6 +163,12 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"http_port_max":                &hcldec.AttrSpec{Name: "http_port_max", Type: cty.Number, Required: false},
 		"http_bind_address":            &hcldec.AttrSpec{Name: "http_bind_address", Type: cty.String, Required: false},
 		"http_interface":               &hcldec.AttrSpec{Name: "http_interface", Type: cty.String, Required: false},
+		"http_templates":               &hcldec.AttrSpec{Name: "http_templates", Type: cty.Map(cty.String), Required: false},
+		"http_bind_cidr":               &hcldec.AttrSpec{Name: "http_bind_cidr", Type: cty.String, Required: false},
+		"http_tunnel":                  &hcldec.AttrSpec{Name: "http_tunnel", Type: cty.Bool, Required: false},
+		"http_tunnel_bridge":           &hcldec.AttrSpec{Name: "http_tunnel_bridge", Type: cty.String, Required: false},
+		"node_ssh":                     &hcldec.BlockSpec{TypeName: "node_ssh", Nested: hcldec.ObjectSpec((*FlatnodeSSHConfig)(nil).HCL2Spec())},
+		"ssh_bastion_node":             &hcldec.AttrSpec{Name: "ssh_bastion_node", Type: cty.Bool, Required: false},
 		"boot_keygroup_interval":       &hcldec.AttrSpec{Name: "boot_keygroup_interval", Type: cty.String, Required: false},
 		"boot_wait":                    &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
 		"boot_command":                 &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
@@ -217,10 +243,12 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"bios":                         &hcldec.AttrSpec{Name: "bios", Type: cty.String, Required: false},
 		"efi_config":                   &hcldec.BlockSpec{TypeName: "efi_config", Nested: hcldec.ObjectSpec((*FlatefiConfig)(nil).HCL2Spec())},
 		"efidisk":                      &hcldec.AttrSpec{Name: "efidisk", Type: cty.String, Required: false},
+		"tpm_config":                   &hcldec.BlockSpec{TypeName: "tpm_config", Nested: hcldec.ObjectSpec((*FlattpmConfig)(nil).HCL2Spec())},
 		"machine":                      &hcldec.AttrSpec{Name: "machine", Type: cty.String, Required: false},
 		"rng0":                         &hcldec.BlockSpec{TypeName: "rng0", Nested: hcldec.ObjectSpec((*Flatrng0Config)(nil).HCL2Spec())},
 		"vga":                          &hcldec.BlockSpec{TypeName: "vga", Nested: hcldec.ObjectSpec((*FlatvgaConfig)(nil).HCL2Spec())},
 		"network_adapters":             &hcldec.BlockListSpec{TypeName: "network_adapters", Nested: hcldec.ObjectSpec((*FlatNICConfig)(nil).HCL2Spec())},
+		"mac_address_hook":             &hcldec.AttrSpec{Name: "mac_address_hook", Type: cty.List(cty.String), Required: false},
 		"disks":                        &hcldec.BlockListSpec{TypeName: "disks", Nested: hcldec.ObjectSpec((*FlatdiskConfig)(nil).HCL2Spec())},
 		"pci_devices":                  &hcldec.BlockListSpec{TypeName: "pci_devices", Nested: hcldec.ObjectSpec((*FlatpciDeviceConfig)(nil).HCL2Spec())},
 		"serials":                      &hcldec.AttrSpec{Name: "serials", Type: cty.List(cty.String), Required: false},
@@ -228,12 +256,24 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"scsi_controller":              &hcldec.AttrSpec{Name: "scsi_controller", Type: cty.String, Required: false},
 		"onboot":                       &hcldec.AttrSpec{Name: "onboot", Type: cty.Bool, Required: false},
 		"disable_kvm":                  &hcldec.AttrSpec{Name: "disable_kvm", Type: cty.Bool, Required: false},
+		"hardware_profile":             &hcldec.AttrSpec{Name: "hardware_profile", Type: cty.String, Required: false},
 		"template_name":                &hcldec.AttrSpec{Name: "template_name", Type: cty.String, Required: false},
 		"template_description":         &hcldec.AttrSpec{Name: "template_description", Type: cty.String, Required: false},
+		"record_lineage":               &hcldec.AttrSpec{Name: "record_lineage", Type: cty.Bool, Required: false},
+		"extra_config":                 &hcldec.AttrSpec{Name: "extra_config", Type: cty.Map(cty.String), Required: false},
+		"template_extra_config":        &hcldec.AttrSpec{Name: "template_extra_config", Type: cty.Map(cty.String), Required: false},
+		"hookscript":                   &hcldec.BlockSpec{TypeName: "hookscript", Nested: hcldec.ObjectSpec((*FlathookscriptConfig)(nil).HCL2Spec())},
+		"checkpoints":                  &hcldec.AttrSpec{Name: "checkpoints", Type: cty.Bool, Required: false},
+		"resume_from_snapshot":         &hcldec.AttrSpec{Name: "resume_from_snapshot", Type: cty.String, Required: false},
+		"keep_vm_on_failure":           &hcldec.AttrSpec{Name: "keep_vm_on_failure", Type: cty.Bool, Required: false},
 		"cloud_init":                   &hcldec.AttrSpec{Name: "cloud_init", Type: cty.Bool, Required: false},
 		"cloud_init_storage_pool":      &hcldec.AttrSpec{Name: "cloud_init_storage_pool", Type: cty.String, Required: false},
 		"additional_iso_files":         &hcldec.BlockListSpec{TypeName: "additional_iso_files", Nested: hcldec.ObjectSpec((*FlatadditionalISOsConfig)(nil).HCL2Spec())},
 		"vm_interface":                 &hcldec.AttrSpec{Name: "vm_interface", Type: cty.String, Required: false},
+		"build_network":                &hcldec.BlockSpec{TypeName: "build_network", Nested: hcldec.ObjectSpec((*FlatbuildNetworkConfig)(nil).HCL2Spec())},
+		"boot_files":                   &hcldec.BlockSpec{TypeName: "boot_files", Nested: hcldec.ObjectSpec((*FlatbootFilesConfig)(nil).HCL2Spec())},
+		"windows_unattend":             &hcldec.BlockSpec{TypeName: "windows_unattend", Nested: hcldec.ObjectSpec((*FlatwindowsUnattendConfig)(nil).HCL2Spec())},
+		"dry_run":                      &hcldec.AttrSpec{Name: "dry_run", Type: cty.Bool, Required: false},
 	}
 	return s
 }
@@ -241,13 +281,15 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 // FlatNICConfig is an auto-generated flat version of NICConfig.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatNICConfig struct {
-	Model        *string `mapstructure:"model" cty:"model" hcl:"model"`
-	PacketQueues *int    `mapstructure:"packet_queues" cty:"packet_queues" hcl:"packet_queues"`
-	MACAddress   *string `mapstructure:"mac_address" cty:"mac_address" hcl:"mac_address"`
-	MTU          *int    `mapstructure:"mtu" cty:"mtu" hcl:"mtu"`
-	Bridge       *string `mapstructure:"bridge" cty:"bridge" hcl:"bridge"`
-	VLANTag      *string `mapstructure:"vlan_tag" cty:"vlan_tag" hcl:"vlan_tag"`
-	Firewall     *bool   `mapstructure:"firewall" cty:"firewall" hcl:"firewall"`
+	Model             *string `mapstructure:"model" cty:"model" hcl:"model"`
+	PacketQueues      *int    `mapstructure:"packet_queues" cty:"packet_queues" hcl:"packet_queues"`
+	MACAddress        *string `mapstructure:"mac_address" cty:"mac_address" hcl:"mac_address"`
+	MACAddressPrefix  *string `mapstructure:"mac_address_prefix" cty:"mac_address_prefix" hcl:"mac_address_prefix"`
+	ReservedIPAddress *string `mapstructure:"reserved_ip_address" cty:"reserved_ip_address" hcl:"reserved_ip_address"`
+	MTU               *int    `mapstructure:"mtu" cty:"mtu" hcl:"mtu"`
+	Bridge            *string `mapstructure:"bridge" cty:"bridge" hcl:"bridge"`
+	VLANTag           *string `mapstructure:"vlan_tag" cty:"vlan_tag" hcl:"vlan_tag"`
+	Firewall          *bool   `mapstructure:"firewall" cty:"firewall" hcl:"firewall"`
 }
 
 // FlatMapstructure returns a new FlatNICConfig.
@@ -262,13 +304,15 @@ func (*NICConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spe
 // The decoded values from this spec will then be applied to a FlatNICConfig.
 func (*FlatNICConfig) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"model":         &hcldec.AttrSpec{Name: "model", Type: cty.String, Required: false},
-		"packet_queues": &hcldec.AttrSpec{Name: "packet_queues", Type: cty.Number, Required: false},
-		"mac_address":   &hcldec.AttrSpec{Name: "mac_address", Type: cty.String, Required: false},
-		"mtu":           &hcldec.AttrSpec{Name: "mtu", Type: cty.Number, Required: false},
-		"bridge":        &hcldec.AttrSpec{Name: "bridge", Type: cty.String, Required: false},
-		"vlan_tag":      &hcldec.AttrSpec{Name: "vlan_tag", Type: cty.String, Required: false},
-		"firewall":      &hcldec.AttrSpec{Name: "firewall", Type: cty.Bool, Required: false},
+		"model":               &hcldec.AttrSpec{Name: "model", Type: cty.String, Required: false},
+		"packet_queues":       &hcldec.AttrSpec{Name: "packet_queues", Type: cty.Number, Required: false},
+		"mac_address":         &hcldec.AttrSpec{Name: "mac_address", Type: cty.String, Required: false},
+		"mac_address_prefix":  &hcldec.AttrSpec{Name: "mac_address_prefix", Type: cty.String, Required: false},
+		"reserved_ip_address": &hcldec.AttrSpec{Name: "reserved_ip_address", Type: cty.String, Required: false},
+		"mtu":                 &hcldec.AttrSpec{Name: "mtu", Type: cty.Number, Required: false},
+		"bridge":              &hcldec.AttrSpec{Name: "bridge", Type: cty.String, Required: false},
+		"vlan_tag":            &hcldec.AttrSpec{Name: "vlan_tag", Type: cty.String, Required: false},
+		"firewall":            &hcldec.AttrSpec{Name: "firewall", Type: cty.Bool, Required: false},
 	}
 	return s
 }
@@ -318,6 +362,62 @@ func (*FlatadditionalISOsConfig) HCL2Spec() map[string]hcldec.Spec {
 	return s
 }
 
+// FlatbootFilesConfig is an auto-generated flat version of bootFilesConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatbootFilesConfig struct {
+	ISOStoragePool *string `mapstructure:"iso_storage_pool" cty:"iso_storage_pool" hcl:"iso_storage_pool"`
+	Device         *string `mapstructure:"device" cty:"device" hcl:"device"`
+	Label          *string `mapstructure:"label" cty:"label" hcl:"label"`
+}
+
+// FlatMapstructure returns a new FlatbootFilesConfig.
+// FlatbootFilesConfig is an auto-generated flat version of bootFilesConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*bootFilesConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatbootFilesConfig)
+}
+
+// HCL2Spec returns the hcl spec of a bootFilesConfig.
+// This spec is used by HCL to read the fields of bootFilesConfig.
+// The decoded values from this spec will then be applied to a FlatbootFilesConfig.
+func (*FlatbootFilesConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"iso_storage_pool": &hcldec.AttrSpec{Name: "iso_storage_pool", Type: cty.String, Required: false},
+		"device":           &hcldec.AttrSpec{Name: "device", Type: cty.String, Required: false},
+		"label":            &hcldec.AttrSpec{Name: "label", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatbuildNetworkConfig is an auto-generated flat version of buildNetworkConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatbuildNetworkConfig struct {
+	IP      *string  `mapstructure:"ip" cty:"ip" hcl:"ip"`
+	Netmask *string  `mapstructure:"netmask" cty:"netmask" hcl:"netmask"`
+	Gateway *string  `mapstructure:"gateway" cty:"gateway" hcl:"gateway"`
+	DNS     []string `mapstructure:"dns" cty:"dns" hcl:"dns"`
+}
+
+// FlatMapstructure returns a new FlatbuildNetworkConfig.
+// FlatbuildNetworkConfig is an auto-generated flat version of buildNetworkConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*buildNetworkConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatbuildNetworkConfig)
+}
+
+// HCL2Spec returns the hcl spec of a buildNetworkConfig.
+// This spec is used by HCL to read the fields of buildNetworkConfig.
+// The decoded values from this spec will then be applied to a FlatbuildNetworkConfig.
+func (*FlatbuildNetworkConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"ip":      &hcldec.AttrSpec{Name: "ip", Type: cty.String, Required: false},
+		"netmask": &hcldec.AttrSpec{Name: "netmask", Type: cty.String, Required: false},
+		"gateway": &hcldec.AttrSpec{Name: "gateway", Type: cty.String, Required: false},
+		"dns":     &hcldec.AttrSpec{Name: "dns", Type: cty.List(cty.String), Required: false},
+	}
+	return s
+}
+
 // FlatdiskConfig is an auto-generated flat version of diskConfig.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatdiskConfig struct {
@@ -384,6 +484,72 @@ func (*FlatefiConfig) HCL2Spec() map[string]hcldec.Spec {
 	return s
 }
 
+// FlathookscriptConfig is an auto-generated flat version of hookscriptConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlathookscriptConfig struct {
+	File           *string `mapstructure:"file" cty:"file" hcl:"file"`
+	StoragePool    *string `mapstructure:"storage_pool" cty:"storage_pool" hcl:"storage_pool"`
+	Volume         *string `mapstructure:"volume" cty:"volume" hcl:"volume"`
+	KeepOnTemplate *bool   `mapstructure:"keep_on_template" cty:"keep_on_template" hcl:"keep_on_template"`
+}
+
+// FlatMapstructure returns a new FlathookscriptConfig.
+// FlathookscriptConfig is an auto-generated flat version of hookscriptConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*hookscriptConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlathookscriptConfig)
+}
+
+// HCL2Spec returns the hcl spec of a hookscriptConfig.
+// This spec is used by HCL to read the fields of hookscriptConfig.
+// The decoded values from this spec will then be applied to a FlathookscriptConfig.
+func (*FlathookscriptConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"file":             &hcldec.AttrSpec{Name: "file", Type: cty.String, Required: false},
+		"storage_pool":     &hcldec.AttrSpec{Name: "storage_pool", Type: cty.String, Required: false},
+		"volume":           &hcldec.AttrSpec{Name: "volume", Type: cty.String, Required: false},
+		"keep_on_template": &hcldec.AttrSpec{Name: "keep_on_template", Type: cty.Bool, Required: false},
+	}
+	return s
+}
+
+// FlatnodeSSHConfig is an auto-generated flat version of nodeSSHConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatnodeSSHConfig struct {
+	Host           *string `mapstructure:"host" cty:"host" hcl:"host"`
+	Port           *int    `mapstructure:"port" cty:"port" hcl:"port"`
+	Username       *string `mapstructure:"username" cty:"username" hcl:"username"`
+	Password       *string `mapstructure:"password" cty:"password" hcl:"password"`
+	PrivateKeyFile *string `mapstructure:"private_key_file" cty:"private_key_file" hcl:"private_key_file"`
+	HostKey        *string `mapstructure:"host_key" cty:"host_key" hcl:"host_key"`
+	KnownHostsFile *string `mapstructure:"known_hosts_file" cty:"known_hosts_file" hcl:"known_hosts_file"`
+	Timeout        *string `mapstructure:"timeout" cty:"timeout" hcl:"timeout"`
+}
+
+// FlatMapstructure returns a new FlatnodeSSHConfig.
+// FlatnodeSSHConfig is an auto-generated flat version of nodeSSHConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*nodeSSHConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatnodeSSHConfig)
+}
+
+// HCL2Spec returns the hcl spec of a nodeSSHConfig.
+// This spec is used by HCL to read the fields of nodeSSHConfig.
+// The decoded values from this spec will then be applied to a FlatnodeSSHConfig.
+func (*FlatnodeSSHConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"host":             &hcldec.AttrSpec{Name: "host", Type: cty.String, Required: false},
+		"port":             &hcldec.AttrSpec{Name: "port", Type: cty.Number, Required: false},
+		"username":         &hcldec.AttrSpec{Name: "username", Type: cty.String, Required: false},
+		"password":         &hcldec.AttrSpec{Name: "password", Type: cty.String, Required: false},
+		"private_key_file": &hcldec.AttrSpec{Name: "private_key_file", Type: cty.String, Required: false},
+		"host_key":         &hcldec.AttrSpec{Name: "host_key", Type: cty.String, Required: false},
+		"known_hosts_file": &hcldec.AttrSpec{Name: "known_hosts_file", Type: cty.String, Required: false},
+		"timeout":          &hcldec.AttrSpec{Name: "timeout", Type: cty.String, Required: false},
+	}
+	return s
+}
+
 // FlatpciDeviceConfig is an auto-generated flat version of pciDeviceConfig.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatpciDeviceConfig struct {
@@ -456,6 +622,31 @@ func (*Flatrng0Config) HCL2Spec() map[string]hcldec.Spec {
 	return s
 }
 
+// FlattpmConfig is an auto-generated flat version of tpmConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlattpmConfig struct {
+	TPMStoragePool *string `mapstructure:"tpm_storage_pool" cty:"tpm_storage_pool" hcl:"tpm_storage_pool"`
+	Version        *string `mapstructure:"tpm_version" cty:"tpm_version" hcl:"tpm_version"`
+}
+
+// FlatMapstructure returns a new FlattpmConfig.
+// FlattpmConfig is an auto-generated flat version of tpmConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*tpmConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlattpmConfig)
+}
+
+// HCL2Spec returns the hcl spec of a tpmConfig.
+// This spec is used by HCL to read the fields of tpmConfig.
+// The decoded values from this spec will then be applied to a FlattpmConfig.
+func (*FlattpmConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"tpm_storage_pool": &hcldec.AttrSpec{Name: "tpm_storage_pool", Type: cty.String, Required: false},
+		"tpm_version":      &hcldec.AttrSpec{Name: "tpm_version", Type: cty.String, Required: false},
+	}
+	return s
+}
+
 // FlatvgaConfig is an auto-generated flat version of vgaConfig.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatvgaConfig struct {
@@ -480,3 +671,44 @@ func (*FlatvgaConfig) HCL2Spec() map[string]hcldec.Spec {
 	}
 	return s
 }
+
+// FlatwindowsUnattendConfig is an auto-generated flat version of windowsUnattendConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatwindowsUnattendConfig struct {
+	ProductKey     *string `mapstructure:"product_key" cty:"product_key" hcl:"product_key"`
+	Locale         *string `mapstructure:"locale" cty:"locale" hcl:"locale"`
+	AdminPassword  *string `mapstructure:"admin_password" cty:"admin_password" hcl:"admin_password"`
+	ImageName      *string `mapstructure:"image_name" cty:"image_name" hcl:"image_name"`
+	Template       *string `mapstructure:"template" cty:"template" hcl:"template"`
+	Device         *string `mapstructure:"device" cty:"device" hcl:"device"`
+	ISOStoragePool *string `mapstructure:"iso_storage_pool" cty:"iso_storage_pool" hcl:"iso_storage_pool"`
+	VirtIOISOFile  *string `mapstructure:"virtio_iso_file" cty:"virtio_iso_file" hcl:"virtio_iso_file"`
+	VirtIODevice   *string `mapstructure:"virtio_device" cty:"virtio_device" hcl:"virtio_device"`
+	VirtIOOSDir    *string `mapstructure:"virtio_os_dir" cty:"virtio_os_dir" hcl:"virtio_os_dir"`
+}
+
+// FlatMapstructure returns a new FlatwindowsUnattendConfig.
+// FlatwindowsUnattendConfig is an auto-generated flat version of windowsUnattendConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*windowsUnattendConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatwindowsUnattendConfig)
+}
+
+// HCL2Spec returns the hcl spec of a windowsUnattendConfig.
+// This spec is used by HCL to read the fields of windowsUnattendConfig.
+// The decoded values from this spec will then be applied to a FlatwindowsUnattendConfig.
+func (*FlatwindowsUnattendConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"product_key":      &hcldec.AttrSpec{Name: "product_key", Type: cty.String, Required: false},
+		"locale":           &hcldec.AttrSpec{Name: "locale", Type: cty.String, Required: false},
+		"admin_password":   &hcldec.AttrSpec{Name: "admin_password", Type: cty.String, Required: false},
+		"image_name":       &hcldec.AttrSpec{Name: "image_name", Type: cty.String, Required: false},
+		"template":         &hcldec.AttrSpec{Name: "template", Type: cty.String, Required: false},
+		"device":           &hcldec.AttrSpec{Name: "device", Type: cty.String, Required: false},
+		"iso_storage_pool": &hcldec.AttrSpec{Name: "iso_storage_pool", Type: cty.String, Required: false},
+		"virtio_iso_file":  &hcldec.AttrSpec{Name: "virtio_iso_file", Type: cty.String, Required: false},
+		"virtio_device":    &hcldec.AttrSpec{Name: "virtio_device", Type: cty.String, Required: false},
+		"virtio_os_dir":    &hcldec.AttrSpec{Name: "virtio_os_dir", Type: cty.String, Required: false},
+	}
+	return s
+}
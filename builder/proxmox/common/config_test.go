@@ -5,6 +5,8 @@ package proxmox
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -557,3 +559,726 @@ func TestPCIDeviceMapping(t *testing.T) {
 		})
 	}
 }
+
+func TestHardwareProfile(t *testing.T) {
+	profileTest := []struct {
+		name          string
+		profile       string
+		extra         map[string]interface{}
+		expectFailure bool
+		expectCPU     string
+		expectBIOS    string
+		expectSCSI    string
+		expectNIC     string
+		expectDisk    string
+	}{
+		{
+			name:       "no profile, plugin defaults",
+			expectCPU:  "kvm64",
+			expectSCSI: "lsi",
+			expectNIC:  "e1000",
+			expectDisk: "scsi",
+		},
+		{
+			name:       "linux-modern",
+			profile:    "linux-modern",
+			expectCPU:  "x86-64-v2-AES",
+			expectBIOS: "ovmf",
+			expectSCSI: "virtio-scsi-single",
+			expectNIC:  "virtio",
+			expectDisk: "scsi",
+		},
+		{
+			name:       "legacy-bios",
+			profile:    "legacy-bios",
+			expectCPU:  "kvm64",
+			expectBIOS: "seabios",
+			expectSCSI: "lsi",
+			expectNIC:  "e1000",
+			expectDisk: "ide",
+		},
+		{
+			name:    "windows-11 with explicit overrides",
+			profile: "windows-11",
+			extra: map[string]interface{}{
+				"cpu_type": "host",
+				"network_adapters": []map[string]interface{}{
+					{"bridge": "vmbr0", "model": "e1000"},
+				},
+				"disks": []map[string]interface{}{
+					{"storage_pool": "local-lvm", "type": "sata"},
+				},
+			},
+			expectCPU:  "host",
+			expectBIOS: "ovmf",
+			expectSCSI: "virtio-scsi-single",
+			expectNIC:  "e1000",
+			expectDisk: "sata",
+		},
+		{
+			name:          "unknown profile, fail",
+			profile:       "windows-95",
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range profileTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			cfg["hardware_profile"] = tt.profile
+			cfg["network_adapters"] = []map[string]interface{}{
+				{"bridge": "vmbr0"},
+			}
+			cfg["disks"] = []map[string]interface{}{
+				{"storage_pool": "local-lvm"},
+			}
+			for k, v := range tt.extra {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil {
+				if !tt.expectFailure {
+					t.Fatalf("unexpected failure to prepare config: %s", err)
+				}
+				t.Logf("got expected failure: %s", err)
+				return
+			}
+			if tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+
+			if c.CPUType != tt.expectCPU {
+				t.Errorf("expected cpu_type %q, got %q", tt.expectCPU, c.CPUType)
+			}
+			if c.BIOS != tt.expectBIOS {
+				t.Errorf("expected bios %q, got %q", tt.expectBIOS, c.BIOS)
+			}
+			if c.SCSIController != tt.expectSCSI {
+				t.Errorf("expected scsi_controller %q, got %q", tt.expectSCSI, c.SCSIController)
+			}
+			if c.NICs[0].Model != tt.expectNIC {
+				t.Errorf("expected network adapter model %q, got %q", tt.expectNIC, c.NICs[0].Model)
+			}
+			if c.Disks[0].Type != tt.expectDisk {
+				t.Errorf("expected disk type %q, got %q", tt.expectDisk, c.Disks[0].Type)
+			}
+		})
+	}
+}
+
+func TestHardwareProfileSecureBootDevices(t *testing.T) {
+	cs := []struct {
+		name          string
+		extra         map[string]interface{}
+		expectEFI     efiConfig
+		expectTPM     tpmConfig
+		expectFailure bool
+	}{
+		{
+			name:      "defaults on the storage pool of the first disk",
+			expectEFI: efiConfig{EFIStoragePool: "local-lvm", PreEnrolledKeys: true, EFIType: "4m"},
+			expectTPM: tpmConfig{TPMStoragePool: "local-lvm", Version: "v2.0"},
+		},
+		{
+			name: "explicit efi_config and tpm_config are kept",
+			extra: map[string]interface{}{
+				"efi_config": map[string]interface{}{"efi_storage_pool": "ceph", "efi_type": "2m"},
+				"tpm_config": map[string]interface{}{"tpm_storage_pool": "ceph"},
+			},
+			expectEFI: efiConfig{EFIStoragePool: "ceph", EFIType: "2m"},
+			expectTPM: tpmConfig{TPMStoragePool: "ceph", Version: "v2.0"},
+		},
+		{
+			name:          "no disks and no tpm_config, fail",
+			extra:         map[string]interface{}{"disks": []map[string]interface{}{}},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range cs {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			cfg["hardware_profile"] = "windows-11"
+			cfg["disks"] = []map[string]interface{}{
+				{"storage_pool": "local-lvm"},
+			}
+			for k, v := range tt.extra {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil {
+				if !tt.expectFailure {
+					t.Fatalf("unexpected failure to prepare config: %s", err)
+				}
+				t.Logf("got expected failure: %s", err)
+				return
+			}
+			if tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+			if c.EFIConfig != tt.expectEFI {
+				t.Errorf("expected efi_config %+v, got %+v", tt.expectEFI, c.EFIConfig)
+			}
+			if c.TPMConfig != tt.expectTPM {
+				t.Errorf("expected tpm_config %+v, got %+v", tt.expectTPM, c.TPMConfig)
+			}
+		})
+	}
+}
+
+func TestTPMConfig(t *testing.T) {
+	tpmTest := []struct {
+		name          string
+		bios          string
+		tpmConfig     map[string]interface{}
+		expectVersion string
+		expectFailure bool
+	}{
+		{
+			name: "no tpm_config, no error",
+		},
+		{
+			name:          "tpm_storage_pool with ovmf, version defaults to v2.0",
+			bios:          "ovmf",
+			tpmConfig:     map[string]interface{}{"tpm_storage_pool": "local-lvm"},
+			expectVersion: "v2.0",
+		},
+		{
+			name:          "tpm v1.2 with ovmf, no error",
+			bios:          "ovmf",
+			tpmConfig:     map[string]interface{}{"tpm_storage_pool": "local-lvm", "tpm_version": "v1.2"},
+			expectVersion: "v1.2",
+		},
+		{
+			name:          "tpm with seabios, fail",
+			bios:          "seabios",
+			tpmConfig:     map[string]interface{}{"tpm_storage_pool": "local-lvm"},
+			expectFailure: true,
+		},
+		{
+			name:          "invalid tpm_version, fail",
+			bios:          "ovmf",
+			tpmConfig:     map[string]interface{}{"tpm_storage_pool": "local-lvm", "tpm_version": "v3.0"},
+			expectFailure: true,
+		},
+		{
+			name:          "tpm_version without tpm_storage_pool, fail",
+			bios:          "ovmf",
+			tpmConfig:     map[string]interface{}{"tpm_version": "v2.0"},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range tpmTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			if tt.bios != "" {
+				cfg["bios"] = tt.bios
+			}
+			if tt.tpmConfig != nil {
+				cfg["tpm_config"] = tt.tpmConfig
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil {
+				if !tt.expectFailure {
+					t.Fatalf("unexpected failure to prepare config: %s", err)
+				}
+				t.Logf("got expected failure: %s", err)
+				return
+			}
+			if tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+			if c.TPMConfig.Version != tt.expectVersion {
+				t.Errorf("expected tpm_version %q, got %q", tt.expectVersion, c.TPMConfig.Version)
+			}
+		})
+	}
+}
+
+func TestExtraConfig(t *testing.T) {
+	extraConfigTest := []struct {
+		name                string
+		extraConfig         map[string]string
+		templateExtraConfig map[string]string
+		expectFailure       bool
+	}{
+		{
+			name: "no extra config, no error",
+		},
+		{
+			name: "unmanaged options, no error",
+			extraConfig: map[string]string{
+				"hookscript": "local:snippets/hook.sh",
+				"affinity":   "0-3",
+				"audio0":     "device=ich9-intel-hda,driver=spice",
+			},
+			templateExtraConfig: map[string]string{
+				"tags":   "golden",
+				"ciuser": "admin",
+			},
+		},
+		{
+			name:          "managed option, fail",
+			extraConfig:   map[string]string{"memory": "4096"},
+			expectFailure: true,
+		},
+		{
+			name:          "managed device, fail",
+			extraConfig:   map[string]string{"net1": "virtio,bridge=vmbr1"},
+			expectFailure: true,
+		},
+		{
+			name:          "cloud-init user for the build VM, fail",
+			extraConfig:   map[string]string{"ciuser": "admin"},
+			expectFailure: true,
+		},
+		{
+			name:                "template name, fail",
+			templateExtraConfig: map[string]string{"name": "other"},
+			expectFailure:       true,
+		},
+		{
+			name:          "invalid key, fail",
+			extraConfig:   map[string]string{"Hook Script": "x"},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range extraConfigTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			if tt.extraConfig != nil {
+				cfg["extra_config"] = tt.extraConfig
+			}
+			if tt.templateExtraConfig != nil {
+				cfg["template_extra_config"] = tt.templateExtraConfig
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil {
+				if !tt.expectFailure {
+					t.Fatalf("unexpected failure to prepare config: %s", err)
+				}
+				t.Logf("got expected failure: %s", err)
+				return
+			}
+			if tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+		})
+	}
+}
+
+func TestExtraConfigConflictMessage(t *testing.T) {
+	errs := validateExtraConfig("extra_config", map[string]string{"memory": "4096", "lock": "backup"}, false)
+	expected := []string{
+		`extra_config: "lock" is managed by the plugin and can't be set`,
+		`extra_config: "memory" is managed by the plugin, use memory instead`,
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), errs)
+	}
+	for idx := range expected {
+		if errs[idx].Error() != expected[idx] {
+			t.Errorf("expected error %q, got %q", expected[idx], errs[idx])
+		}
+	}
+}
+
+func TestHookscript(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	hostKey := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDXJEcHMOmfvZzmXLRX6m/1JvH0CXSE4ATsmeKq9F7vL root@pve"
+
+	hookscriptTest := []struct {
+		name          string
+		hookscript    map[string]interface{}
+		nodeSSH       map[string]interface{}
+		extraConfig   map[string]string
+		username      string
+		expectFailure bool
+		expectWarning bool
+	}{
+		{
+			name: "no hookscript, no error",
+		},
+		{
+			name: "hookscript as root@pam, no error",
+			hookscript: map[string]interface{}{
+				"volume": "local:snippets/hook.pl",
+			},
+			username: "root@pam",
+		},
+		{
+			name: "hookscript with an API token, warning",
+			hookscript: map[string]interface{}{
+				"volume": "local:snippets/hook.pl",
+			},
+			username:      "root@pam!packer",
+			expectWarning: true,
+		},
+		{
+			name: "local file uploaded over node_ssh, no error",
+			hookscript: map[string]interface{}{
+				"file":         script,
+				"storage_pool": "local",
+			},
+			nodeSSH:  map[string]interface{}{"password": "secret", "host_key": hostKey},
+			username: "root@pam",
+		},
+		{
+			name: "local file without node_ssh, fail",
+			hookscript: map[string]interface{}{
+				"file":         script,
+				"storage_pool": "local",
+			},
+			username:      "root@pam",
+			expectFailure: true,
+		},
+		{
+			name: "local file without storage_pool, fail",
+			hookscript: map[string]interface{}{
+				"file": script,
+			},
+			nodeSSH:       map[string]interface{}{"password": "secret", "host_key": hostKey},
+			expectFailure: true,
+		},
+		{
+			name: "missing local file, fail",
+			hookscript: map[string]interface{}{
+				"file":         filepath.Join(t.TempDir(), "missing.sh"),
+				"storage_pool": "local",
+			},
+			nodeSSH:       map[string]interface{}{"password": "secret", "host_key": hostKey},
+			expectFailure: true,
+		},
+		{
+			name: "file and volume, fail",
+			hookscript: map[string]interface{}{
+				"file":         script,
+				"storage_pool": "local",
+				"volume":       "local:snippets/hook.pl",
+			},
+			nodeSSH:       map[string]interface{}{"password": "secret", "host_key": hostKey},
+			expectFailure: true,
+		},
+		{
+			name: "volume with storage_pool, fail",
+			hookscript: map[string]interface{}{
+				"volume":       "local:snippets/hook.pl",
+				"storage_pool": "local",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "missing file and volume, fail",
+			hookscript: map[string]interface{}{
+				"keep_on_template": true,
+			},
+			expectFailure: true,
+		},
+		{
+			name: "volume without storage, fail",
+			hookscript: map[string]interface{}{
+				"volume": "snippets/hook.pl",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "volume not a snippet, fail",
+			hookscript: map[string]interface{}{
+				"volume": "local:iso/hook.pl",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "local file instead of volume, fail",
+			hookscript: map[string]interface{}{
+				"volume": "scripts/hook.pl",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "hookscript in extra_config as well, fail",
+			hookscript: map[string]interface{}{
+				"volume": "local:snippets/hook.pl",
+			},
+			extraConfig:   map[string]string{"hookscript": "local:snippets/hook.sh"},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range hookscriptTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			if tt.hookscript != nil {
+				cfg["hookscript"] = tt.hookscript
+			}
+			if tt.nodeSSH != nil {
+				cfg["node_ssh"] = tt.nodeSSH
+			}
+			if tt.extraConfig != nil {
+				cfg["extra_config"] = tt.extraConfig
+			}
+			if tt.username != "" {
+				cfg["username"] = tt.username
+			}
+
+			var c Config
+			_, warnings, err := c.Prepare(&c, cfg)
+			if err != nil {
+				if !tt.expectFailure {
+					t.Fatalf("unexpected failure to prepare config: %s", err)
+				}
+				t.Logf("got expected failure: %s", err)
+				return
+			}
+			if tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+
+			hasWarning := false
+			for _, w := range warnings {
+				if strings.Contains(w, "hookscript") {
+					hasWarning = true
+				}
+			}
+			if hasWarning != tt.expectWarning {
+				t.Errorf("expected hookscript warning: %t, got warnings %v", tt.expectWarning, warnings)
+			}
+		})
+	}
+}
+
+func TestCheckpoints(t *testing.T) {
+	checkpointsTest := []struct {
+		name               string
+		checkpoints        bool
+		onError            string
+		vmID               int
+		resumeFromSnapshot string
+		comm               map[string]interface{}
+		expectFailure      bool
+		expectWarning      bool
+	}{
+		{
+			name: "no checkpoints, no error",
+		},
+		{
+			name:        "checkpoints with on-error=abort, no warning",
+			checkpoints: true,
+			onError:     "abort",
+		},
+		{
+			name:          "checkpoints without on-error=abort, warning",
+			checkpoints:   true,
+			expectWarning: true,
+		},
+		{
+			name:               "resume with vm_id, no error",
+			vmID:               100,
+			resumeFromSnapshot: "packer-provisioned",
+			comm:               map[string]interface{}{"ssh_password": "packer"},
+		},
+		{
+			name:               "resume without communicator, no error",
+			vmID:               100,
+			resumeFromSnapshot: "packer-provisioned",
+			comm:               map[string]interface{}{"communicator": "none"},
+		},
+		{
+			name:               "resume without vm_id, fail",
+			resumeFromSnapshot: "packer-provisioned",
+			comm:               map[string]interface{}{"ssh_password": "packer"},
+			expectFailure:      true,
+		},
+		{
+			name:               "invalid snapshot name, fail",
+			vmID:               100,
+			resumeFromSnapshot: "before provisioning",
+			comm:               map[string]interface{}{"ssh_password": "packer"},
+			expectFailure:      true,
+		},
+		{
+			name:               "resume with a temporary SSH key, fail",
+			vmID:               100,
+			resumeFromSnapshot: "packer-provisioned",
+			expectFailure:      true,
+		},
+	}
+
+	for _, tt := range checkpointsTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			cfg["checkpoints"] = tt.checkpoints
+			if tt.onError != "" {
+				cfg["packer_on_error"] = tt.onError
+			}
+			if tt.vmID != 0 {
+				cfg["vm_id"] = tt.vmID
+			}
+			if tt.resumeFromSnapshot != "" {
+				cfg["resume_from_snapshot"] = tt.resumeFromSnapshot
+			}
+			for k, v := range tt.comm {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, warnings, err := c.Prepare(&c, cfg)
+			if err != nil {
+				if !tt.expectFailure {
+					t.Fatalf("unexpected failure to prepare config: %s", err)
+				}
+				t.Logf("got expected failure: %s", err)
+				return
+			}
+			if tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+
+			hasWarning := false
+			for _, w := range warnings {
+				if strings.Contains(w, "checkpoints") {
+					hasWarning = true
+				}
+			}
+			if hasWarning != tt.expectWarning {
+				t.Errorf("expected checkpoints warning: %t, got warnings %v", tt.expectWarning, warnings)
+			}
+		})
+	}
+}
+
+func TestKeepVMOnFailure(t *testing.T) {
+	keepVMTest := []struct {
+		name          string
+		onError       string
+		expectWarning bool
+	}{
+		{
+			name: "default on-error, no warning",
+		},
+		{
+			name:    "on-error=cleanup, no warning",
+			onError: "cleanup",
+		},
+		{
+			name:          "on-error=abort, warning",
+			onError:       "abort",
+			expectWarning: true,
+		},
+	}
+
+	for _, tt := range keepVMTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			cfg["keep_vm_on_failure"] = true
+			if tt.onError != "" {
+				cfg["packer_on_error"] = tt.onError
+			}
+
+			var c Config
+			_, warnings, err := c.Prepare(&c, cfg)
+			if err != nil {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+
+			hasWarning := false
+			for _, w := range warnings {
+				if strings.Contains(w, "keep_vm_on_failure") {
+					hasWarning = true
+				}
+			}
+			if hasWarning != tt.expectWarning {
+				t.Errorf("expected keep_vm_on_failure warning: %t, got warnings %v", tt.expectWarning, warnings)
+			}
+		})
+	}
+}
+
+func TestMACAddress(t *testing.T) {
+	macAddressTest := []struct {
+		name           string
+		nic            map[string]interface{}
+		hook           []string
+		expectFailure  bool
+		expectedPrefix string
+	}{
+		{
+			name:           "auto-deterministic, default prefix",
+			nic:            map[string]interface{}{"mac_address": "auto-deterministic"},
+			expectedPrefix: "02:50:4B",
+		},
+		{
+			name:           "auto-deterministic with prefix, no error",
+			nic:            map[string]interface{}{"mac_address": "auto-deterministic", "mac_address_prefix": "02:00:5E"},
+			expectedPrefix: "02:00:5E",
+		},
+		{
+			name:          "multicast prefix, fail",
+			nic:           map[string]interface{}{"mac_address": "auto-deterministic", "mac_address_prefix": "01:00:5E"},
+			expectFailure: true,
+		},
+		{
+			name:          "prefix without auto-deterministic, fail",
+			nic:           map[string]interface{}{"mac_address": "BC:24:11:00:00:01", "mac_address_prefix": "02:00:5E"},
+			expectFailure: true,
+		},
+		{
+			name:           "reserved IP with hook, no error",
+			nic:            map[string]interface{}{"mac_address": "auto-deterministic", "reserved_ip_address": "192.0.2.50"},
+			hook:           []string{"/usr/local/bin/dhcp-reserve"},
+			expectedPrefix: "02:50:4B",
+		},
+		{
+			name:          "reserved IP without MAC address, fail",
+			nic:           map[string]interface{}{"reserved_ip_address": "192.0.2.50"},
+			expectFailure: true,
+		},
+		{
+			name:          "invalid reserved IP, fail",
+			nic:           map[string]interface{}{"mac_address": "auto-deterministic", "reserved_ip_address": "192.0.2"},
+			expectFailure: true,
+		},
+		{
+			name:          "hook without MAC address, fail",
+			nic:           map[string]interface{}{},
+			hook:          []string{"/usr/local/bin/dhcp-reserve"},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range macAddressTest {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.nic["bridge"] = "vmbr0"
+			cfg := mandatoryConfig(t)
+			cfg["network_adapters"] = []map[string]interface{}{tt.nic}
+			if tt.hook != nil {
+				cfg["mac_address_hook"] = tt.hook
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+			if err == nil && c.NICs[0].MACAddressPrefix != tt.expectedPrefix {
+				t.Errorf("expected mac_address_prefix %q, got %q", tt.expectedPrefix, c.NICs[0].MACAddressPrefix)
+			}
+		})
+	}
+}
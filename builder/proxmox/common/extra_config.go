@@ -0,0 +1,99 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// managedConfigKey is a VM config key the plugin sets itself, together with
+// the option configuring it, if there is one. Keys only managed for the build
+// VM can still be set on the template.
+type managedConfigKey struct {
+	key       *regexp.Regexp
+	option    string
+	buildOnly bool
+}
+
+// managedConfigKeys can't be set with extra_config or template_extra_config,
+// the plugin would overwrite them or depends on their value.
+var managedConfigKeys = []managedConfigKey{
+	{regexp.MustCompile(`^name$`), "vm_name or template_name", false},
+	{regexp.MustCompile(`^vmid$`), "vm_id", false},
+	{regexp.MustCompile(`^pool$`), "pool", false},
+	{regexp.MustCompile(`^description$`), "template_description", false},
+	{regexp.MustCompile(`^template$`), "", false},
+	{regexp.MustCompile(`^(delete|digest|revert|lock)$`), "", false},
+	{regexp.MustCompile(`^boot$`), "boot", false},
+	{regexp.MustCompile(`^memory$`), "memory", false},
+	{regexp.MustCompile(`^balloon$`), "ballooning_minimum", false},
+	{regexp.MustCompile(`^cores$`), "cores", false},
+	{regexp.MustCompile(`^cpu$`), "cpu_type", false},
+	{regexp.MustCompile(`^sockets$`), "sockets", false},
+	{regexp.MustCompile(`^numa$`), "numa", false},
+	{regexp.MustCompile(`^ostype$`), "os", false},
+	{regexp.MustCompile(`^bios$`), "bios", false},
+	{regexp.MustCompile(`^efidisk0$`), "efi_config", false},
+	{regexp.MustCompile(`^tpmstate0$`), "tpm_config", false},
+	{regexp.MustCompile(`^machine$`), "machine", false},
+	{regexp.MustCompile(`^rng0$`), "rng0", false},
+	{regexp.MustCompile(`^vga$`), "vga", false},
+	{regexp.MustCompile(`^net\d+$`), "network_adapters", false},
+	{regexp.MustCompile(`^(ide|sata|scsi|virtio)\d+$`), "disks or additional_iso_files", false},
+	{regexp.MustCompile(`^unused\d+$`), "disks", false},
+	{regexp.MustCompile(`^hostpci\d+$`), "pci_devices", false},
+	{regexp.MustCompile(`^serial\d+$`), "serials", false},
+	{regexp.MustCompile(`^agent$`), "qemu_agent", false},
+	{regexp.MustCompile(`^scsihw$`), "scsi_controller", false},
+	{regexp.MustCompile(`^onboot$`), "onboot", false},
+	{regexp.MustCompile(`^kvm$`), "disable_kvm", false},
+	// cloud-init is used to give the communicator access to clones and
+	// imported images
+	{regexp.MustCompile(`^(ciuser|cipassword|sshkeys)$`), "the communicator options", true},
+	{regexp.MustCompile(`^(nameserver|searchdomain|ipconfig\d+)$`), "nameserver, searchdomain or ipconfig", true},
+}
+
+var validConfigKey = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// validateExtraConfig returns an error for every key of extra, set with
+// option, that isn't a valid VM config key or is managed by the plugin. With
+// template set, extra is applied to the template instead of the build VM.
+func validateExtraConfig(option string, extra map[string]string, template bool) []error {
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		if !validConfigKey.MatchString(key) {
+			errs = append(errs, fmt.Errorf("%s: %q is not a valid VM config key", option, key))
+			continue
+		}
+		for _, managed := range managedConfigKeys {
+			if !managed.key.MatchString(key) || (template && managed.buildOnly) {
+				continue
+			}
+			if managed.option == "" {
+				errs = append(errs, fmt.Errorf("%s: %q is managed by the plugin and can't be set", option, key))
+			} else {
+				errs = append(errs, fmt.Errorf("%s: %q is managed by the plugin, use %s instead", option, key, managed.option))
+			}
+			break
+		}
+	}
+	return errs
+}
+
+// extraConfigParams returns extra as parameters for a VM config update.
+func extraConfigParams(extra map[string]string) map[string]interface{} {
+	params := make(map[string]interface{}, len(extra))
+	for key, value := range extra {
+		params[key] = value
+	}
+	return params
+}
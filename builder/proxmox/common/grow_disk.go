@@ -0,0 +1,49 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+)
+
+type diskGrower interface {
+	GetVmConfig(vmr *proxmox.VmRef) (vmConfig map[string]interface{}, err error)
+	ResizeQemuDiskRaw(vmr *proxmox.VmRef, disk string, size string) (exitStatus interface{}, err error)
+}
+
+var _ diskGrower = &proxmox.Client{}
+
+// GrowDisk resizes disk, usually one imported from an image, to size unless
+// it is already at least that large. Proxmox refuses to shrink disks.
+func GrowDisk(client diskGrower, vmRef *proxmox.VmRef, disk string, size string) error {
+	vmParams, err := client.GetVmConfig(vmRef)
+	if err != nil {
+		return fmt.Errorf("error fetching config of imported disk: %s", err)
+	}
+	diskParams, ok := vmParams[disk].(string)
+	if !ok {
+		return fmt.Errorf("imported disk %s not found in VM config", disk)
+	}
+	currentSize := ""
+	for _, param := range strings.Split(diskParams, ",") {
+		if strings.HasPrefix(param, "size=") {
+			currentSize = strings.TrimPrefix(param, "size=")
+		}
+	}
+	if currentSize != "" && proxmox.DiskSizeGB(size) <= proxmox.DiskSizeGB(currentSize) {
+		log.Printf("imported disk %s is %s, not resizing to %s", disk, currentSize, size)
+		return nil
+	}
+
+	log.Printf("resizing imported disk %s from %s to %s", disk, currentSize, size)
+	_, err = client.ResizeQemuDiskRaw(vmRef, disk, size)
+	if err != nil {
+		return fmt.Errorf("error resizing imported disk %s: %s", disk, err)
+	}
+	return nil
+}
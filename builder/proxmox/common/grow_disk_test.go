@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+)
+
+type diskGrowerMock struct {
+	vmConfig    map[string]interface{}
+	resizedTo   string
+	resizeCalls int
+}
+
+func (m *diskGrowerMock) GetVmConfig(*proxmox.VmRef) (map[string]interface{}, error) {
+	return m.vmConfig, nil
+}
+func (m *diskGrowerMock) ResizeQemuDiskRaw(vmr *proxmox.VmRef, disk string, size string) (interface{}, error) {
+	m.resizeCalls++
+	m.resizedTo = size
+	return nil, nil
+}
+
+var _ diskGrower = &diskGrowerMock{}
+
+func TestGrowDisk(t *testing.T) {
+	cs := []struct {
+		name         string
+		diskParams   string
+		size         string
+		expectResize bool
+		expectError  bool
+	}{
+		{
+			name:         "image smaller than disk_size is grown",
+			diskParams:   "local-lvm:vm-100-disk-0,size=2G",
+			size:         "20G",
+			expectResize: true,
+		},
+		{
+			name:         "image sized in megabytes is grown",
+			diskParams:   "local-lvm:vm-100-disk-0,discard=on,size=2252M",
+			size:         "10G",
+			expectResize: true,
+		},
+		{
+			name:         "image larger than disk_size is kept",
+			diskParams:   "local-lvm:vm-100-disk-0,size=30G",
+			size:         "20G",
+			expectResize: false,
+		},
+		{
+			name:         "disk without size is grown",
+			diskParams:   "local-lvm:vm-100-disk-0",
+			size:         "20G",
+			expectResize: true,
+		},
+		{
+			name:        "missing disk fails",
+			size:        "20G",
+			expectError: true,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			m := &diskGrowerMock{vmConfig: map[string]interface{}{}}
+			if c.diskParams != "" {
+				m.vmConfig["virtio0"] = c.diskParams
+			}
+
+			err := GrowDisk(m, proxmox.NewVmRef(100), "virtio0", c.size)
+			if (err != nil) != c.expectError {
+				t.Fatalf("expected error: %t, got %v", c.expectError, err)
+			}
+			if (m.resizeCalls > 0) != c.expectResize {
+				t.Errorf("expected resize: %t, got %d calls", c.expectResize, m.resizeCalls)
+			}
+			if c.expectResize && m.resizedTo != c.size {
+				t.Errorf("expected disk to be resized to %s, got %s", c.size, m.resizedTo)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// hardwareProfile is a named set of device settings, selected with the
+// hardware_profile option. Values of the profile are only used for options
+// that are not set explicitly.
+type hardwareProfile struct {
+	CPUType        string
+	Machine        string
+	BIOS           string
+	SCSIController string
+	VGAType        string
+	NICModel       string
+	DiskType       string
+	OS             string
+	// SecureBoot adds an EFI disk with pre-enrolled keys and a v2.0 TPM if
+	// efi_config and tpm_config aren't set
+	SecureBoot bool
+}
+
+var hardwareProfiles = map[string]hardwareProfile{
+	"linux-modern": {
+		CPUType:        "x86-64-v2-AES",
+		Machine:        "q35",
+		BIOS:           "ovmf",
+		SCSIController: "virtio-scsi-single",
+		VGAType:        "std",
+		NICModel:       "virtio",
+		DiskType:       "scsi",
+		OS:             "l26",
+	},
+	"windows-11": {
+		CPUType:        "x86-64-v2-AES",
+		Machine:        "q35",
+		BIOS:           "ovmf",
+		SCSIController: "virtio-scsi-single",
+		VGAType:        "std",
+		NICModel:       "virtio",
+		DiskType:       "scsi",
+		OS:             "win11",
+		SecureBoot:     true,
+	},
+	"legacy-bios": {
+		CPUType:        "kvm64",
+		Machine:        "pc",
+		BIOS:           "seabios",
+		SCSIController: "lsi",
+		VGAType:        "std",
+		NICModel:       "e1000",
+		DiskType:       "ide",
+		OS:             "other",
+	},
+}
+
+// applyHardwareProfile fills all unset device options from the configured
+// hardware profile. It must run before the defaults are set in Prepare.
+func (c *Config) applyHardwareProfile() ([]string, error) {
+	if c.HardwareProfile == "" {
+		return nil, nil
+	}
+	profile, ok := hardwareProfiles[c.HardwareProfile]
+	if !ok {
+		names := []string{}
+		for name := range hardwareProfiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("hardware_profile must be one of %s, got %q", strings.Join(names, ", "), c.HardwareProfile)
+	}
+
+	var warnings []string
+	if c.BIOS != "" && c.BIOS != profile.BIOS {
+		warnings = append(warnings, fmt.Sprintf("bios %s overrides %s of hardware_profile %s", c.BIOS, profile.BIOS, c.HardwareProfile))
+	}
+
+	setIfEmpty(&c.CPUType, profile.CPUType)
+	setIfEmpty(&c.Machine, profile.Machine)
+	setIfEmpty(&c.BIOS, profile.BIOS)
+	setIfEmpty(&c.SCSIController, profile.SCSIController)
+	setIfEmpty(&c.VGA.Type, profile.VGAType)
+	setIfEmpty(&c.OS, profile.OS)
+	for idx := range c.NICs {
+		setIfEmpty(&c.NICs[idx].Model, profile.NICModel)
+	}
+	for idx := range c.Disks {
+		setIfEmpty(&c.Disks[idx].Type, profile.DiskType)
+	}
+	if profile.SecureBoot {
+		err := c.applySecureBootDevices()
+		if err != nil {
+			return warnings, err
+		}
+	}
+	return warnings, nil
+}
+
+// applySecureBootDevices adds an EFI disk with the Microsoft keys enrolled and
+// a v2.0 TPM, which Windows 11 setup requires, unless they are configured
+// explicitly. They are stored on the storage pool of the first disk.
+func (c *Config) applySecureBootDevices() error {
+	needsEFI := c.EFIConfig == (efiConfig{}) && c.EFIDisk == ""
+	needsTPM := c.TPMConfig == (tpmConfig{})
+	if !needsEFI && !needsTPM {
+		return nil
+	}
+	if len(c.Disks) == 0 || c.Disks[0].StoragePool == "" {
+		return fmt.Errorf("hardware_profile %s requires efi_config and tpm_config, or a disk with a storage_pool to store them on", c.HardwareProfile)
+	}
+	if needsEFI {
+		c.EFIConfig = efiConfig{
+			EFIStoragePool:  c.Disks[0].StoragePool,
+			PreEnrolledKeys: true,
+			EFIType:         "4m",
+		}
+	}
+	if needsTPM {
+		c.TPMConfig = tpmConfig{
+			TPMStoragePool: c.Disks[0].StoragePool,
+			Version:        "v2.0",
+		}
+	}
+	return nil
+}
+
+func setIfEmpty(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+)
+
+// httpServerIP returns the address the VM reaches the HTTP server on. Unless
+// it is set with http_bind_address, or limited to http_interface or
+// http_bind_cidr, it is the source address the machine running Packer uses
+// to reach the build network, or else the Proxmox node. Other interfaces,
+// like the ones of Docker or VPNs, often have addresses the VM can't reach.
+func httpServerIP(c *Config) (string, error) {
+	if c.HTTPAddress != "0.0.0.0" {
+		return c.HTTPAddress, nil
+	}
+
+	var cidr *net.IPNet
+	if c.HTTPBindCIDR != "" {
+		// http_bind_cidr was checked by Config.Prepare
+		_, cidr, _ = net.ParseCIDR(c.HTTPBindCIDR)
+	}
+	if c.HTTPInterface != "" || cidr != nil {
+		return hostIP(c.HTTPInterface, cidr)
+	}
+
+	var targets []string
+	if c.BuildNetwork.IP != "" {
+		targets = append(targets, c.BuildNetwork.IP)
+	}
+	if c.proxmoxURL != nil {
+		targets = append(targets, c.proxmoxURL.Hostname())
+	}
+	for _, target := range targets {
+		ip, err := sourceIP(target)
+		if err != nil {
+			log.Printf("Failed to determine the source address for %s: %s", target, err)
+			continue
+		}
+		return ip, nil
+	}
+	return hostIP("", nil)
+}
+
+// sourceIP returns the local address the OS sends packets to host from.
+// Connecting a UDP socket only looks up the route, nothing is sent.
+func sourceIP(host string) (string, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(host, "9"))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	ip := conn.LocalAddr().(*net.UDPAddr).IP
+	if ip.IsLoopback() {
+		return "", fmt.Errorf("%s is reached over loopback", host)
+	}
+	return ip.String(), nil
+}
+
+// hostIP returns the address selectHostIP picks from the addresses of
+// interface ifname, or of all interfaces if ifname is empty.
+func hostIP(ifname string, cidr *net.IPNet) (string, error) {
+	var addrs []net.Addr
+	var err error
+
+	if ifname != "" {
+		iface, err := net.InterfaceByName(ifname)
+		if err != nil {
+			return "", err
+		}
+		addrs, err = iface.Addrs()
+		if err != nil {
+			return "", err
+		}
+	} else {
+		addrs, err = net.InterfaceAddrs()
+		if err != nil {
+			return "", err
+		}
+	}
+	return selectHostIP(addrs, cidr)
+}
+
+// selectHostIP returns the first of addrs that isn't a loopback or
+// link-local address. IPv4 addresses are preferred over IPv6 addresses,
+// unless cidr is set, in which case the first address in cidr is returned.
+func selectHostIP(addrs []net.Addr, cidr *net.IPNet) (string, error) {
+	var ipv6 net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if cidr != nil {
+			if cidr.Contains(ipnet.IP) {
+				return ipnet.IP.String(), nil
+			}
+			continue
+		}
+		if ipnet.IP.To4() != nil {
+			return ipnet.IP.String(), nil
+		}
+		if ipv6 == nil {
+			ipv6 = ipnet.IP
+		}
+	}
+	if ipv6 != nil {
+		return ipv6.String(), nil
+	}
+	if cidr != nil {
+		return "", fmt.Errorf("No host IP in %s found", cidr)
+	}
+	return "", errors.New("No host IP found")
+}
@@ -0,0 +1,262 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestHTTPServerIP(t *testing.T) {
+	httpServerIPTest := []struct {
+		name          string
+		config        map[string]interface{}
+		expectedIP    string
+		expectFailure bool
+	}{
+		{
+			name:       "bind address, used",
+			config:     map[string]interface{}{"http_bind_address": "192.0.2.10"},
+			expectedIP: "192.0.2.10",
+		},
+		{
+			name:          "bind CIDR without local address, fail",
+			config:        map[string]interface{}{"http_bind_cidr": "198.51.100.0/24"},
+			expectFailure: true,
+		},
+		{
+			name:          "bind CIDR without local IPv6 address, fail",
+			config:        map[string]interface{}{"http_bind_cidr": "2001:db8:1::/48"},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range httpServerIPTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for key, value := range tt.config {
+				cfg[key] = value
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+
+			ip, err := httpServerIP(&c)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure to determine the host IP: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatalf("expected failure, got %s", ip)
+			}
+			if ip != tt.expectedIP {
+				t.Errorf("expected host IP %q, got %q", tt.expectedIP, ip)
+			}
+		})
+	}
+}
+
+func TestHTTPBindCIDRConfig(t *testing.T) {
+	httpBindCIDRTest := []struct {
+		name          string
+		config        map[string]interface{}
+		expectFailure bool
+	}{
+		{
+			name:   "IPv4 CIDR, no error",
+			config: map[string]interface{}{"http_bind_cidr": "192.0.2.0/24"},
+		},
+		{
+			name:   "IPv6 CIDR, no error",
+			config: map[string]interface{}{"http_bind_cidr": "2001:db8::/64"},
+		},
+		{
+			name:          "invalid CIDR, fail",
+			config:        map[string]interface{}{"http_bind_cidr": "192.0.2.0"},
+			expectFailure: true,
+		},
+		{
+			name:          "CIDR with bind address, fail",
+			config:        map[string]interface{}{"http_bind_cidr": "192.0.2.0/24", "http_bind_address": "192.0.2.10"},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range httpBindCIDRTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for key, value := range tt.config {
+				cfg[key] = value
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+		})
+	}
+}
+
+// localIP returns an address of the machine running the tests that
+// selectHostIP would consider, or skips the test if there is none.
+func localIP(t *testing.T) net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && !ipnet.IP.IsLinkLocalUnicast() {
+			return ipnet.IP
+		}
+	}
+	t.Skip("no non-loopback address to test with")
+	return nil
+}
+
+func TestHTTPServerIPRoute(t *testing.T) {
+	local := localIP(t)
+	prefix := 64
+	if local.To4() != nil {
+		prefix = 24
+	}
+
+	httpServerIPRouteTest := []struct {
+		name   string
+		config map[string]interface{}
+	}{
+		{
+			name: "route to the Proxmox node",
+			config: map[string]interface{}{
+				"proxmox_url": fmt.Sprintf("https://%s/api2/json", net.JoinHostPort(local.String(), "8006")),
+			},
+		},
+		{
+			name: "route to the build network",
+			config: map[string]interface{}{
+				"build_network": map[string]interface{}{
+					"ip": fmt.Sprintf("%s/%d", local, prefix),
+				},
+			},
+		},
+	}
+
+	for _, tt := range httpServerIPRouteTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for key, value := range tt.config {
+				cfg[key] = value
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+
+			// Packets to an address of the machine itself are sent from it
+			ip, err := httpServerIP(&c)
+			if err != nil {
+				t.Fatalf("unexpected failure to determine the host IP: %s", err)
+			}
+			if ip != local.String() {
+				t.Errorf("expected host IP %s, got %s", local, ip)
+			}
+		})
+	}
+}
+
+func TestSelectHostIP(t *testing.T) {
+	ipNet := func(s string) *net.IPNet {
+		ip, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ipnet.IP = ip
+		return ipnet
+	}
+
+	selectHostIPTest := []struct {
+		name          string
+		addrs         []string
+		cidr          string
+		expectedIP    string
+		expectFailure bool
+	}{
+		{
+			name:       "IPv4 preferred over an earlier IPv6 address",
+			addrs:      []string{"::1/128", "2001:db8::10/64", "fe80::1/64", "192.0.2.10/24"},
+			expectedIP: "192.0.2.10",
+		},
+		{
+			name:       "first IPv6 address without IPv4",
+			addrs:      []string{"127.0.0.1/8", "fe80::1/64", "2001:db8::10/64", "2001:db8::20/64"},
+			expectedIP: "2001:db8::10",
+		},
+		{
+			name:       "IPv6 address in CIDR preferred over IPv4",
+			addrs:      []string{"192.0.2.10/24", "2001:db8::10/64"},
+			cidr:       "2001:db8::/64",
+			expectedIP: "2001:db8::10",
+		},
+		{
+			name:          "only loopback and link-local, fail",
+			addrs:         []string{"127.0.0.1/8", "::1/128", "169.254.0.1/16", "fe80::1/64"},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range selectHostIPTest {
+		t.Run(tt.name, func(t *testing.T) {
+			var addrs []net.Addr
+			for _, addr := range tt.addrs {
+				addrs = append(addrs, ipNet(addr))
+			}
+			var cidr *net.IPNet
+			if tt.cidr != "" {
+				_, cidr, _ = net.ParseCIDR(tt.cidr)
+			}
+
+			ip, err := selectHostIP(addrs, cidr)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure to select the host IP: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatalf("expected failure, got %s", ip)
+			}
+			if ip != tt.expectedIP {
+				t.Errorf("expected host IP %q, got %q", tt.expectedIP, ip)
+			}
+		})
+	}
+}
+
+func TestHostIPInCIDR(t *testing.T) {
+	local := localIP(t)
+
+	bits := 128
+	if local.To4() != nil {
+		bits = 32
+	}
+	cidr := &net.IPNet{IP: local, Mask: net.CIDRMask(bits, bits)}
+	ip, err := hostIP("", cidr)
+	if err != nil {
+		t.Fatalf("unexpected failure to determine the host IP: %s", err)
+	}
+	if ip != local.String() {
+		t.Errorf("expected host IP %s, got %s", local, ip)
+	}
+}
+
+func TestSourceIPLoopback(t *testing.T) {
+	if ip, err := sourceIP("127.0.0.1"); err == nil {
+		t.Errorf("expected failure for a loopback target, got %s", ip)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"golang.org/x/crypto/ssh"
+)
+
+type nodeNetworkReader interface {
+	GetItemList(url string) (map[string]interface{}, error)
+}
+
+var _ nodeNetworkReader = &proxmox.Client{}
+
+// bridgeAddress returns the address of the Proxmox node on bridge, the
+// IPv4 address if it has one.
+func bridgeAddress(client nodeNetworkReader, node string, bridge string) (string, error) {
+	list, err := client.GetItemList(fmt.Sprintf("/nodes/%s/network/%s", node, bridge))
+	if err != nil {
+		return "", fmt.Errorf("Error reading bridge %s of node %s: %s", bridge, node, err)
+	}
+	data, _ := list["data"].(map[string]interface{})
+	for _, key := range []string{"address", "address6"} {
+		if address, _ := data[key].(string); address != "" {
+			return address, nil
+		}
+	}
+	return "", fmt.Errorf("Bridge %s of node %s has no address the VM can reach the node on", bridge, node)
+}
+
+// httpTunnel forwards connections to a port of the Proxmox node, opened
+// over SSH, to the HTTP server.
+type httpTunnel struct {
+	client   *ssh.Client
+	listener net.Listener
+}
+
+// openHTTPTunnel connects to the Proxmox node and forwards a free port on
+// remoteIP to port localPort on localIP. sshd only opens ports on other
+// addresses than loopback with GatewayPorts set to clientspecified.
+func openHTTPTunnel(ns nodeSSHConfig, remoteIP string, localIP string, localPort int) (*httpTunnel, error) {
+	client, err := dialNode(ns)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := client.Listen("tcp", net.JoinHostPort(remoteIP, "0"))
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("Error opening a port on %s of the Proxmox node, check that sshd allows it with GatewayPorts clientspecified: %s", remoteIP, err)
+	}
+
+	t := &httpTunnel{
+		client:   client,
+		listener: listener,
+	}
+	go t.serve(net.JoinHostPort(localIP, strconv.Itoa(localPort)))
+
+	// With GatewayPorts no, sshd doesn't fail but opens the port on loopback
+	// only, where the VM can't reach it. Connect to it from the node to be
+	// sure.
+	remoteAddr := net.JoinHostPort(remoteIP, strconv.Itoa(t.Port()))
+	probe, err := client.Dial("tcp", remoteAddr)
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("Error connecting to %s on the Proxmox node, sshd probably opened the port on loopback only, set GatewayPorts clientspecified in its config: %s", remoteAddr, err)
+	}
+	probe.Close()
+	return t, nil
+}
+
+// Port returns the port opened on the Proxmox node.
+func (t *httpTunnel) Port() int {
+	return t.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (t *httpTunnel) serve(localAddr string) {
+	for {
+		remote, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer remote.Close()
+			local, err := net.Dial("tcp", localAddr)
+			if err != nil {
+				log.Printf("Error forwarding connection to the HTTP server: %s", err)
+				return
+			}
+			defer local.Close()
+
+			// Each direction is closed on its own, so a request body
+			// ending doesn't cut off the response
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				forward(local, remote)
+			}()
+			go func() {
+				defer wg.Done()
+				forward(remote, local)
+			}()
+			wg.Wait()
+		}()
+	}
+}
+
+// closeWriter is implemented by TCP connections and SSH channels, which can
+// signal EOF to the other side while still reading.
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// forward copies src to dst until src is done, then closes the write side of
+// dst.
+func forward(dst net.Conn, src net.Conn) {
+	if _, err := io.Copy(dst, src); err != nil {
+		log.Printf("Error forwarding connection to the HTTP server: %s", err)
+	}
+	if cw, ok := dst.(closeWriter); ok {
+		cw.CloseWrite()
+		return
+	}
+	dst.Close()
+}
+
+func (t *httpTunnel) Close() error {
+	t.listener.Close()
+	return t.client.Close()
+}
@@ -0,0 +1,550 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type nodeNetworkReaderMock struct {
+	getItemList func(url string) (map[string]interface{}, error)
+}
+
+func (m nodeNetworkReaderMock) GetItemList(url string) (map[string]interface{}, error) {
+	return m.getItemList(url)
+}
+
+var _ nodeNetworkReader = nodeNetworkReaderMock{}
+
+// startTunnelSSHServer starts an SSH server accepting the password
+// "secret", which forwards ports like sshd, and returns its port and host
+// key. Remote ports are opened on the requested address like with
+// GatewayPorts clientspecified, or on loopback like with GatewayPorts no.
+func startTunnelSSHServer(t *testing.T, gatewayPorts bool) (int, ssh.PublicKey) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != "secret" {
+				return nil, fmt.Errorf("wrong password")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveTunnelSSHConn(conn, config, gatewayPorts)
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port, signer.PublicKey()
+}
+
+func serveTunnelSSHConn(conn net.Conn, config *ssh.ServerConfig, gatewayPorts bool) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer sconn.Close()
+	go func() {
+		for newCh := range chans {
+			if newCh.ChannelType() != "direct-tcpip" {
+				newCh.Reject(ssh.Prohibited, "only port forwarding")
+				continue
+			}
+			var target struct {
+				Addr       string
+				Port       uint32
+				OriginAddr string
+				OriginPort uint32
+			}
+			if err := ssh.Unmarshal(newCh.ExtraData(), &target); err != nil {
+				newCh.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			local, err := net.Dial("tcp", net.JoinHostPort(target.Addr, strconv.Itoa(int(target.Port))))
+			if err != nil {
+				newCh.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			ch, chReqs, err := newCh.Accept()
+			if err != nil {
+				local.Close()
+				continue
+			}
+			go ssh.DiscardRequests(chReqs)
+			go func() {
+				defer local.Close()
+				defer ch.Close()
+				go io.Copy(ch, local)
+				io.Copy(local, ch)
+			}()
+		}
+	}()
+
+	for req := range reqs {
+		if req.Type != "tcpip-forward" {
+			req.Reply(false, nil)
+			continue
+		}
+		var forward struct {
+			Addr string
+			Port uint32
+		}
+		if err := ssh.Unmarshal(req.Payload, &forward); err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+		bindAddr := forward.Addr
+		if !gatewayPorts {
+			bindAddr = "127.0.0.1"
+		}
+		l, err := net.Listen("tcp", net.JoinHostPort(bindAddr, strconv.Itoa(int(forward.Port))))
+		if err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+		defer l.Close()
+		port := uint32(l.Addr().(*net.TCPAddr).Port)
+		req.Reply(true, ssh.Marshal(struct{ Port uint32 }{port}))
+
+		go func() {
+			for {
+				remote, err := l.Accept()
+				if err != nil {
+					return
+				}
+				origin := remote.RemoteAddr().(*net.TCPAddr)
+				ch, chReqs, err := sconn.OpenChannel("forwarded-tcpip", ssh.Marshal(struct {
+					Addr       string
+					Port       uint32
+					OriginAddr string
+					OriginPort uint32
+				}{forward.Addr, port, origin.IP.String(), uint32(origin.Port)}))
+				if err != nil {
+					remote.Close()
+					continue
+				}
+				go ssh.DiscardRequests(chReqs)
+				go func() {
+					defer remote.Close()
+					defer ch.Close()
+					// Like sshd, pass on the EOF of the connection
+					go func() {
+						io.Copy(ch, remote)
+						ch.CloseWrite()
+					}()
+					io.Copy(remote, ch)
+				}()
+			}
+		}()
+	}
+}
+
+func TestHTTPServerTunnel(t *testing.T) {
+	sshPort, hostKey := startTunnelSSHServer(t, true)
+
+	cfg := mandatoryConfig(t)
+	cfg["network_adapters"] = []map[string]interface{}{{"bridge": "vmbr0"}, {"bridge": "vmbr1"}}
+	cfg["http_tunnel"] = true
+	cfg["http_tunnel_bridge"] = "vmbr1"
+	cfg["node_ssh"] = map[string]interface{}{
+		"host":     "127.0.0.1",
+		"port":     sshPort,
+		"password": "secret",
+		"host_key": string(ssh.MarshalAuthorizedKey(hostKey)),
+	}
+	cfg["http_content"] = map[string]string{
+		"/ks.cfg": "url --url=http://{{ .HTTPIP }}:{{ .HTTPPort }}/repo",
+	}
+
+	var c Config
+	_, _, err := c.Prepare(&c, cfg)
+	if err != nil {
+		t.Fatalf("unexpected failure to prepare config: %s", err)
+	}
+
+	var requestedURL string
+	client := nodeNetworkReaderMock{
+		getItemList: func(url string) (map[string]interface{}, error) {
+			requestedURL = url
+			return map[string]interface{}{"data": map[string]interface{}{"iface": "vmbr1", "address": "127.0.0.1"}}, nil
+		},
+	}
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("config", &c)
+	state.Put("vmRef", proxmox.NewVmRef(110))
+	state.Put("proxmoxClient", client)
+
+	step := &stepHTTPServer{}
+	action := step.Run(context.TODO(), state)
+	defer step.Cleanup(state)
+	if action != multistep.ActionContinue {
+		t.Fatalf("expected action continue, got %v: %v", action, state.Get("error"))
+	}
+
+	if requestedURL != "/nodes/my-proxmox/network/vmbr1" {
+		t.Errorf("expected the address of vmbr1 to be read, got %s", requestedURL)
+	}
+	if state.Get("http_ip") != "127.0.0.1" {
+		t.Errorf("expected http_ip to be the bridge address, got %v", state.Get("http_ip"))
+	}
+	port := state.Get("http_port").(int)
+	if port != step.tunnel.Port() || port == step.l.Port {
+		t.Errorf("expected http_port to be the tunnel port %d, got %d", step.tunnel.Port(), port)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/ks.cfg", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	expected := fmt.Sprintf("url --url=http://127.0.0.1:%d/repo", port)
+	if string(body) != expected {
+		t.Errorf("expected %q through the tunnel, got %q", expected, string(body))
+	}
+
+	// A client closing its side after the request still gets the response
+	conn, err := net.DialTCP("tcp", nil, &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(conn, "GET /ks.cfg HTTP/1.0\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(raw), expected) {
+		t.Errorf("expected %q through the half-closed tunnel, got %q", expected, string(raw))
+	}
+}
+
+func TestOpenHTTPTunnel(t *testing.T) {
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSigner, err := ssh.NewSignerFromKey(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	openHTTPTunnelTest := []struct {
+		name          string
+		gatewayPorts  bool
+		remoteIP      string
+		hostKey       func(ssh.PublicKey) string
+		knownHosts    func(port int, key ssh.PublicKey) string
+		expectFailure string
+	}{
+		{
+			name:         "GatewayPorts clientspecified, no error",
+			gatewayPorts: true,
+			remoteIP:     "127.0.0.1",
+		},
+		{
+			name:          "GatewayPorts no, fail",
+			remoteIP:      "127.0.0.2",
+			expectFailure: "GatewayPorts clientspecified",
+		},
+		{
+			name:         "matching host key, no error",
+			gatewayPorts: true,
+			remoteIP:     "127.0.0.1",
+			hostKey: func(key ssh.PublicKey) string {
+				return string(ssh.MarshalAuthorizedKey(key))
+			},
+		},
+		{
+			name:         "other host key, fail",
+			gatewayPorts: true,
+			remoteIP:     "127.0.0.1",
+			hostKey: func(ssh.PublicKey) string {
+				return string(ssh.MarshalAuthorizedKey(otherSigner.PublicKey()))
+			},
+			expectFailure: "host key mismatch",
+		},
+		{
+			name:         "host in known_hosts_file, no error",
+			gatewayPorts: true,
+			remoteIP:     "127.0.0.1",
+			knownHosts: func(port int, key ssh.PublicKey) string {
+				return knownhosts.Line([]string{knownhosts.Normalize(fmt.Sprintf("127.0.0.1:%d", port))}, key)
+			},
+		},
+		{
+			name:         "host not in known_hosts_file, fail",
+			gatewayPorts: true,
+			remoteIP:     "127.0.0.1",
+			knownHosts: func(port int, key ssh.PublicKey) string {
+				return knownhosts.Line([]string{"pve.example.com"}, key)
+			},
+			expectFailure: "key is unknown",
+		},
+	}
+
+	for _, tt := range openHTTPTunnelTest {
+		t.Run(tt.name, func(t *testing.T) {
+			sshPort, hostKey := startTunnelSSHServer(t, tt.gatewayPorts)
+			ns := nodeSSHConfig{
+				Host:     "127.0.0.1",
+				Port:     sshPort,
+				Username: "root",
+				Password: "secret",
+				Timeout:  5 * time.Second,
+			}
+			if tt.hostKey != nil {
+				ns.HostKey = tt.hostKey(hostKey)
+			}
+			if tt.knownHosts != nil {
+				ns.KnownHostsFile = filepath.Join(t.TempDir(), "known_hosts")
+				if err := os.WriteFile(ns.KnownHostsFile, []byte(tt.knownHosts(sshPort, hostKey)+"\n"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tunnel, err := openHTTPTunnel(ns, tt.remoteIP, "127.0.0.1", 9)
+			if err == nil {
+				defer tunnel.Close()
+			}
+			if tt.expectFailure == "" && err != nil {
+				t.Fatalf("unexpected failure to open the tunnel: %s", err)
+			}
+			if tt.expectFailure != "" {
+				if err == nil {
+					t.Fatal("expected failure, but the tunnel was opened")
+				}
+				if !strings.Contains(err.Error(), tt.expectFailure) {
+					t.Errorf("expected error containing %q, got %s", tt.expectFailure, err)
+				}
+			}
+		})
+	}
+}
+
+func TestBridgeAddress(t *testing.T) {
+	bridgeAddressTest := []struct {
+		name            string
+		data            map[string]interface{}
+		expectedAddress string
+		expectFailure   bool
+	}{
+		{
+			name:            "IPv4 address",
+			data:            map[string]interface{}{"address": "192.0.2.2", "address6": "2001:db8::2"},
+			expectedAddress: "192.0.2.2",
+		},
+		{
+			name:            "IPv6 address only",
+			data:            map[string]interface{}{"address6": "2001:db8::2"},
+			expectedAddress: "2001:db8::2",
+		},
+		{
+			name:          "no address, fail",
+			data:          map[string]interface{}{"iface": "vmbr1"},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range bridgeAddressTest {
+		t.Run(tt.name, func(t *testing.T) {
+			client := nodeNetworkReaderMock{
+				getItemList: func(url string) (map[string]interface{}, error) {
+					return map[string]interface{}{"data": tt.data}, nil
+				},
+			}
+			address, err := bridgeAddress(client, "pve", "vmbr1")
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatalf("expected failure, got %s", address)
+			}
+			if address != tt.expectedAddress {
+				t.Errorf("expected address %q, got %q", tt.expectedAddress, address)
+			}
+		})
+	}
+}
+
+func TestHTTPTunnelConfig(t *testing.T) {
+	httpTunnelTest := []struct {
+		name           string
+		config         map[string]interface{}
+		expectFailure  bool
+		expectedHost   string
+		expectedBridge string
+	}{
+		{
+			name: "tunnel with password, no error",
+			config: map[string]interface{}{
+				"http_tunnel": true,
+				"node_ssh":    map[string]interface{}{"password": "secret"},
+			},
+			expectedHost:   "",
+			expectedBridge: "vmbr1",
+		},
+		{
+			name: "tunnel with bridge, no error",
+			config: map[string]interface{}{
+				"http_tunnel":        true,
+				"http_tunnel_bridge": "vmbr0",
+				"node_ssh":           map[string]interface{}{"password": "secret"},
+			},
+			expectedHost:   "",
+			expectedBridge: "vmbr0",
+		},
+		{
+			name:          "bridge without tunnel, fail",
+			config:        map[string]interface{}{"http_tunnel_bridge": "vmbr0"},
+			expectFailure: true,
+		},
+		{
+			name: "tunnel with host, no error",
+			config: map[string]interface{}{
+				"http_tunnel": true,
+				"node_ssh":    map[string]interface{}{"host": "192.0.2.2", "password": "secret"},
+			},
+			expectedHost:   "192.0.2.2",
+			expectedBridge: "vmbr1",
+		},
+		{
+			name:          "tunnel without credentials, fail",
+			config:        map[string]interface{}{"http_tunnel": true},
+			expectFailure: true,
+		},
+		{
+			name: "tunnel with missing key file, fail",
+			config: map[string]interface{}{
+				"http_tunnel": true,
+				"node_ssh":    map[string]interface{}{"private_key_file": "/nonexistent/id_ed25519"},
+			},
+			expectFailure: true,
+		},
+		{
+			name: "tunnel with host key, no error",
+			config: map[string]interface{}{
+				"http_tunnel": true,
+				"node_ssh": map[string]interface{}{
+					"password": "secret",
+					"host_key": "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDXJEcHMOmfvZzmXLRX6m/1JvH0CXSE4ATsmeKq9F7vL root@pve",
+				},
+			},
+			expectedHost:   "",
+			expectedBridge: "vmbr1",
+		},
+		{
+			name: "tunnel with invalid host key, fail",
+			config: map[string]interface{}{
+				"http_tunnel": true,
+				"node_ssh":    map[string]interface{}{"password": "secret", "host_key": "ssh-ed25519 notakey"},
+			},
+			expectFailure: true,
+		},
+		{
+			name: "tunnel with missing known_hosts_file, fail",
+			config: map[string]interface{}{
+				"http_tunnel": true,
+				"node_ssh":    map[string]interface{}{"password": "secret", "known_hosts_file": "/nonexistent/known_hosts"},
+			},
+			expectFailure: true,
+		},
+		{
+			name: "tunnel with host key and known_hosts_file, fail",
+			config: map[string]interface{}{
+				"http_tunnel": true,
+				"node_ssh": map[string]interface{}{
+					"password":         "secret",
+					"host_key":         "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIDXJEcHMOmfvZzmXLRX6m/1JvH0CXSE4ATsmeKq9F7vL root@pve",
+					"known_hosts_file": "/etc/ssh/ssh_known_hosts",
+				},
+			},
+			expectFailure: true,
+		},
+		{
+			name: "tunnel with boot files, fail",
+			config: map[string]interface{}{
+				"http_tunnel":  true,
+				"node_ssh":     map[string]interface{}{"password": "secret"},
+				"boot_files":   map[string]interface{}{"iso_storage_pool": "local"},
+				"http_content": map[string]string{"/ks.cfg": "text"},
+			},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range httpTunnelTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			cfg["network_adapters"] = []map[string]interface{}{{"bridge": "vmbr1"}}
+			for key, value := range tt.config {
+				cfg[key] = value
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+			if err == nil {
+				if c.NodeSSH.Host != tt.expectedHost {
+					t.Errorf("expected node_ssh host %q, got %q", tt.expectedHost, c.NodeSSH.Host)
+				}
+				if c.HTTPTunnelBridge != tt.expectedBridge {
+					t.Errorf("expected http_tunnel_bridge %q, got %q", tt.expectedBridge, c.HTTPTunnelBridge)
+				}
+				if c.NodeSSH.Username != "root" || c.NodeSSH.Port != 22 {
+					t.Errorf("expected node_ssh defaults root and 22, got %q and %d", c.NodeSSH.Username, c.NodeSSH.Port)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+// The lineage of a template is stored as a JSON list in a fenced block at
+// the end of its description, so it is copied along when the template is
+// cloned and stays readable in the Proxmox interface.
+const (
+	lineageBlockStart = "```packer-lineage\n"
+	lineageBlockEnd   = "\n```"
+)
+
+// ParseLineage returns the lineage recorded in the description of a
+// template, oldest entry first, and the description without it.
+func ParseLineage(description string) ([]map[string]string, string, error) {
+	start := strings.LastIndex(description, lineageBlockStart)
+	if start < 0 {
+		return nil, description, nil
+	}
+	block := description[start+len(lineageBlockStart):]
+	end := strings.Index(block, lineageBlockEnd)
+	if end < 0 {
+		return nil, description, fmt.Errorf("lineage block is not terminated")
+	}
+
+	var lineage []map[string]string
+	err := json.Unmarshal([]byte(block[:end]), &lineage)
+	if err != nil {
+		return nil, description, fmt.Errorf("error parsing lineage: %s", err)
+	}
+	rest := strings.TrimRight(description[:start], "\n") + block[end+len(lineageBlockEnd):]
+	return lineage, strings.TrimRight(rest, "\n"), nil
+}
+
+// formatLineage appends the lineage block to description.
+func formatLineage(description string, lineage []map[string]string) string {
+	// Marshalling maps of strings can't fail
+	out, _ := json.MarshalIndent(lineage, "", "  ")
+	if description != "" {
+		description += "\n\n"
+	}
+	return description + lineageBlockStart + string(out) + lineageBlockEnd
+}
+
+// stepLineage puts the lineage of the template being built into the
+// "lineage" state key, for stepFinalizeTemplateConfig to record it with
+// record_lineage. It is made available to provisioners and post-processors
+// as the Lineage generated data, holding the lineage as JSON, and to
+// post-processors as the "lineage" artifact state.
+//
+// The lineage is made of the entries in the "lineage_base" state key, set by
+// builders that start from another template, followed by an entry for this
+// build. Builders add where the build started from, like the ISO and its
+// checksum, to the entry with the "lineage_origin" state key.
+type stepLineage struct{}
+
+func (s *stepLineage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c := state.Get("config").(*Config)
+	vmRef := state.Get("vmRef").(*proxmox.VmRef)
+
+	entry := map[string]string{
+		"template_id":   strconv.Itoa(vmRef.VmId()),
+		"template_name": c.VMName,
+		"node":          c.Node,
+		"builder":       c.PackerBuilderType,
+		"created_at":    time.Now().UTC().Format(time.RFC3339),
+	}
+	if c.TemplateName != "" {
+		entry["template_name"] = c.TemplateName
+	}
+	if c.PackerBuildName != "" {
+		entry["build_name"] = c.PackerBuildName
+	}
+	if origin, ok := state.Get("lineage_origin").(map[string]string); ok {
+		for key, value := range origin {
+			entry[key] = value
+		}
+	}
+
+	base, _ := state.Get("lineage_base").([]map[string]string)
+	lineage := make([]map[string]string, 0, len(base)+1)
+	lineage = append(lineage, base...)
+	lineage = append(lineage, entry)
+	state.Put("lineage", lineage)
+
+	// []interface{} and map[string]string can be sent over the plugin RPC,
+	// []map[string]string can't
+	entries := make([]interface{}, len(lineage))
+	for idx := range lineage {
+		entries[idx] = lineage[idx]
+	}
+	AddArtifactState(state, "lineage", entries)
+
+	// Can't fail, see formatLineage
+	out, _ := json.Marshal(lineage)
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("Lineage", string(out))
+
+	return multistep.ActionContinue
+}
+
+func (s *stepLineage) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLineage(t *testing.T) {
+	cs := []struct {
+		name                string
+		description         string
+		expectFailure       bool
+		expectedLineage     []map[string]string
+		expectedDescription string
+	}{
+		{
+			name:                "no lineage",
+			description:         "Debian 12",
+			expectedDescription: "Debian 12",
+		},
+		{
+			name:        "lineage after the description",
+			description: "Debian 12\n\n```packer-lineage\n[{\"template_id\": \"9000\"}]\n```",
+			expectedLineage: []map[string]string{
+				{"template_id": "9000"},
+			},
+			expectedDescription: "Debian 12",
+		},
+		{
+			name:        "lineage only",
+			description: "```packer-lineage\n[{\"template_id\": \"9000\"}, {\"template_id\": \"9001\"}]\n```",
+			expectedLineage: []map[string]string{
+				{"template_id": "9000"},
+				{"template_id": "9001"},
+			},
+		},
+		{
+			name:          "unterminated block, fail",
+			description:   "Debian 12\n\n```packer-lineage\n[{\"template_id\": \"9000\"}]",
+			expectFailure: true,
+		},
+		{
+			name:          "invalid JSON, fail",
+			description:   "```packer-lineage\n{\"template_id\": 9000}\n```",
+			expectFailure: true,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			lineage, description, err := ParseLineage(c.description)
+			if c.expectFailure {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expectedLineage, lineage)
+			assert.Equal(t, c.expectedDescription, description)
+		})
+	}
+}
+
+func TestFormatLineageRoundTrip(t *testing.T) {
+	lineage := []map[string]string{
+		{"template_id": "9000", "iso_checksum": "sha256:abcd"},
+		{"template_id": "9001", "clone_source_vm_id": "9000"},
+	}
+	for _, description := range []string{"", "Debian 12\nwith nginx"} {
+		parsed, rest, err := ParseLineage(formatLineage(description, lineage))
+		require.NoError(t, err)
+		assert.Equal(t, lineage, parsed)
+		assert.Equal(t, description, rest)
+	}
+}
+
+func TestLineage(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	c := &Config{VMName: "debian-12-build", TemplateName: "debian-12-custom", Node: "pve"}
+	c.PackerBuilderType = "proxmox-clone"
+	state.Put("config", c)
+	state.Put("vmRef", proxmox.NewVmRef(100))
+	state.Put("lineage_base", []map[string]string{{"template_id": "9000"}})
+	state.Put("lineage_origin", map[string]string{"clone_source_vm_id": "9000"})
+
+	step := &stepLineage{}
+	action := step.Run(context.TODO(), state)
+	require.Equal(t, multistep.ActionContinue, action)
+
+	lineage := state.Get("lineage").([]map[string]string)
+	require.Len(t, lineage, 2)
+	assert.Equal(t, map[string]string{"template_id": "9000"}, lineage[0])
+	assert.Equal(t, "100", lineage[1]["template_id"])
+	assert.Equal(t, "debian-12-custom", lineage[1]["template_name"])
+	assert.Equal(t, "pve", lineage[1]["node"])
+	assert.Equal(t, "proxmox-clone", lineage[1]["builder"])
+	assert.Equal(t, "9000", lineage[1]["clone_source_vm_id"])
+	assert.NotEmpty(t, lineage[1]["created_at"])
+
+	artifactState := state.Get("artifact_state").(map[string]interface{})
+	assert.Len(t, artifactState["lineage"], 2)
+	generatedData := state.Get("generated_data").(map[string]interface{})
+	assert.Contains(t, generatedData["Lineage"], `"clone_source_vm_id":"9000"`)
+}
@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// macAddressAutoDeterministic as mac_address derives the MAC address of a
+// network adapter from the build name and the VM ID
+const macAddressAutoDeterministic = "auto-deterministic"
+
+// A locally administered prefix, so generated addresses can't collide with
+// vendor assigned ones, like those Proxmox assigns from its OUI BC:24:11
+const defaultMACAddressPrefix = "02:50:4B"
+
+// parseMACAddressPrefix parses a prefix of one to five octets, like
+// BC:24:11. The prefix must not make the addresses multicast addresses.
+func parseMACAddressPrefix(prefix string) ([]byte, error) {
+	octets := strings.Split(prefix, ":")
+	if len(octets) < 1 || len(octets) > 5 {
+		return nil, fmt.Errorf("must have 1 to 5 octets, got %q", prefix)
+	}
+	result := make([]byte, len(octets))
+	for idx, octet := range octets {
+		value, err := strconv.ParseUint(octet, 16, 8)
+		if err != nil || len(octet) != 2 {
+			return nil, fmt.Errorf("must be octets in hex separated by colons, got %q", prefix)
+		}
+		result[idx] = byte(value)
+	}
+	if result[0]&1 != 0 {
+		return nil, fmt.Errorf("must not be a multicast prefix, got %q", prefix)
+	}
+	return result, nil
+}
+
+// deterministicMACAddress returns the MAC address of network adapter index
+// of the VM vmID built by buildName. The address starts with prefix, the
+// rest is taken from a hash of the build name, VM ID and adapter, so builds
+// get the same address every time they run with the same VM ID.
+func deterministicMACAddress(prefix string, buildName string, vmID int, index int) string {
+	// The prefix was checked by Config.Prepare
+	mac, _ := parseMACAddressPrefix(prefix)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d/%d", buildName, vmID, index)))
+	mac = append(mac, sum[:6-len(mac)]...)
+	return strings.ToUpper(net.HardwareAddr(mac).String())
+}
+
+// resolveMACAddresses returns the network adapters with the MAC addresses
+// set to auto-deterministic replaced by the address for VM vmID.
+func resolveMACAddresses(c *Config, vmID int) []NICConfig {
+	nics := make([]NICConfig, len(c.NICs))
+	copy(nics, c.NICs)
+	for idx := range nics {
+		if nics[idx].MACAddress == macAddressAutoDeterministic {
+			nics[idx].MACAddress = deterministicMACAddress(nics[idx].MACAddressPrefix, c.PackerBuildName, vmID, idx)
+		}
+	}
+	return nics
+}
+
+// macAddressReservation is a MAC address and the IP address reserved for it,
+// as passed to the mac_address_hook.
+type macAddressReservation struct {
+	MACAddress string
+	IPAddress  string
+}
+
+// registerMACAddresses runs the mac_address_hook with register for the
+// network adapters that have a MAC address. The registered addresses are
+// kept in the "mac_address_reservations" state key, for
+// deregisterMACAddresses to release them.
+func registerMACAddresses(c *Config, nics []NICConfig, state multistep.StateBag) error {
+	ui := state.Get("ui").(packersdk.Ui)
+	var registered []macAddressReservation
+	for _, nic := range nics {
+		if nic.MACAddress == "" {
+			continue
+		}
+		reservation := macAddressReservation{MACAddress: nic.MACAddress, IPAddress: nic.ReservedIPAddress}
+		ui.Say(fmt.Sprintf("Registering MAC address %s", reservation.MACAddress))
+		err := runMACAddressHook(c.MACAddressHook, "register", reservation)
+		if err != nil {
+			return err
+		}
+		registered = append(registered, reservation)
+		state.Put("mac_address_reservations", registered)
+	}
+	return nil
+}
+
+// deregisterMACAddresses runs the mac_address_hook with deregister for the
+// MAC addresses registered by registerMACAddresses. Failures are reported,
+// but don't stop the cleanup.
+func deregisterMACAddresses(c *Config, state multistep.StateBag) {
+	ui := state.Get("ui").(packersdk.Ui)
+	registered, _ := state.Get("mac_address_reservations").([]macAddressReservation)
+	for _, reservation := range registered {
+		ui.Say(fmt.Sprintf("Deregistering MAC address %s", reservation.MACAddress))
+		err := runMACAddressHook(c.MACAddressHook, "deregister", reservation)
+		if err != nil {
+			ui.Error(err.Error())
+		}
+	}
+	state.Remove("mac_address_reservations")
+}
+
+func runMACAddressHook(hook []string, action string, reservation macAddressReservation) error {
+	args := append(append([]string{}, hook[1:]...), action, reservation.MACAddress, reservation.IPAddress)
+	out, err := exec.Command(hook[0], args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Error running mac_address_hook to %s %s: %s: %s", action, reservation.MACAddress, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
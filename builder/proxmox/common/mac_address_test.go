@@ -0,0 +1,56 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeterministicMACAddress(t *testing.T) {
+	mac := deterministicMACAddress("BC:24:11", "proxmox-iso.debian", 100, 0)
+	assert.Equal(t, mac, deterministicMACAddress("BC:24:11", "proxmox-iso.debian", 100, 0), "same build should get the same address")
+	assert.True(t, strings.HasPrefix(mac, "BC:24:11:"))
+	hw, err := net.ParseMAC(mac)
+	require.NoError(t, err)
+	assert.Len(t, hw, 6)
+
+	assert.NotEqual(t, mac, deterministicMACAddress("BC:24:11", "proxmox-iso.debian", 101, 0), "VM ID should change the address")
+	assert.NotEqual(t, mac, deterministicMACAddress("BC:24:11", "proxmox-iso.ubuntu", 100, 0), "build name should change the address")
+	assert.NotEqual(t, mac, deterministicMACAddress("BC:24:11", "proxmox-iso.debian", 100, 1), "adapter should change the address")
+
+	mac = deterministicMACAddress("02:00:5E:10:20", "proxmox-iso.debian", 100, 0)
+	assert.True(t, strings.HasPrefix(mac, "02:00:5E:10:20:"))
+}
+
+func TestParseMACAddressPrefix(t *testing.T) {
+	cs := []struct {
+		prefix        string
+		expectFailure bool
+	}{
+		{prefix: "BC:24:11"},
+		{prefix: "02"},
+		{prefix: "02:00:00:00:00"},
+		{prefix: "02:00:00:00:00:00", expectFailure: true},
+		{prefix: "", expectFailure: true},
+		{prefix: "BC-24-11", expectFailure: true},
+		{prefix: "B:24:11", expectFailure: true},
+		{prefix: "01:00:5E", expectFailure: true},
+	}
+
+	for _, c := range cs {
+		t.Run(c.prefix, func(t *testing.T) {
+			_, err := parseMACAddressPrefix(c.prefix)
+			if c.expectFailure {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/pathing"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type nodeSSHConfig struct {
+	Host           string        `mapstructure:"host"`
+	Port           int           `mapstructure:"port"`
+	Username       string        `mapstructure:"username"`
+	Password       string        `mapstructure:"password"`
+	PrivateKeyFile string        `mapstructure:"private_key_file"`
+	HostKey        string        `mapstructure:"host_key"`
+	KnownHostsFile string        `mapstructure:"known_hosts_file"`
+	Timeout        time.Duration `mapstructure:"timeout"`
+}
+
+// prepareNodeSSH validates the node_ssh block, which is needed by the
+// features reaching the guest network through the Proxmox node and to copy
+// hookscript.file to the node. The host is looked up when the build runs if
+// it isn't set.
+func (c *Config) prepareNodeSSH() ([]string, []error) {
+	ns := &c.NodeSSH
+	if !c.HTTPTunnel && !c.SSHBastionNode && c.Hookscript.File == "" {
+		if *ns != (nodeSSHConfig{}) {
+			log.Printf("node_ssh is set, but not used by http_tunnel, ssh_bastion_node or hookscript")
+		}
+		return nil, nil
+	}
+
+	var errs []error
+	if ns.Port == 0 {
+		ns.Port = 22
+	}
+	if ns.Username == "" {
+		log.Printf("node_ssh username not set, using default 'root'")
+		ns.Username = "root"
+	}
+	if ns.Timeout == 0 {
+		ns.Timeout = 30 * time.Second
+	}
+	if ns.Password == "" && ns.PrivateKeyFile == "" {
+		errs = append(errs, errors.New("node_ssh password or private_key_file must be specified"))
+	}
+	if ns.PrivateKeyFile != "" {
+		path, err := pathing.ExpandUser(ns.PrivateKeyFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node_ssh private_key_file: %s", err))
+		} else if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("node_ssh private_key_file: %s", err))
+		} else {
+			ns.PrivateKeyFile = path
+		}
+	}
+	if ns.KnownHostsFile != "" {
+		path, err := pathing.ExpandUser(ns.KnownHostsFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node_ssh known_hosts_file: %s", err))
+		} else {
+			ns.KnownHostsFile = path
+		}
+	}
+	var warnings []string
+	if ns.HostKey != "" && ns.KnownHostsFile != "" {
+		errs = append(errs, errors.New("node_ssh host_key and known_hosts_file can't both be set"))
+	} else if _, err := ns.hostKeyCallback(); err != nil {
+		errs = append(errs, err)
+	} else if (c.HTTPTunnel || c.Hookscript.File != "") && ns.HostKey == "" && ns.KnownHostsFile == "" {
+		warnings = append(warnings, "node_ssh host_key and known_hosts_file are not set, the host key of the Proxmox node isn't verified when opening http_tunnel or uploading hookscript.file")
+	}
+	packersdk.LogSecretFilter.Set(ns.Password)
+
+	if c.SSHBastionNode {
+		if c.Comm.Type != "ssh" {
+			errs = append(errs, errors.New("ssh_bastion_node requires the ssh communicator"))
+		}
+		if c.Comm.SSHBastionHost != "" || c.Comm.SSHProxyHost != "" {
+			errs = append(errs, errors.New("ssh_bastion_node can't be used with ssh_bastion_host or ssh_proxy_host"))
+		}
+		// The bastion connection is made by the communicator, which doesn't
+		// support host key verification
+		if ns.HostKey != "" || ns.KnownHostsFile != "" {
+			warnings = append(warnings, "node_ssh host_key and known_hosts_file only apply to http_tunnel and hookscript.file, the communicator doesn't verify the host key of ssh_bastion_node")
+		}
+	}
+	return warnings, errs
+}
+
+// hostKeyCallback returns the callback verifying the host key of the node
+// against host_key or known_hosts_file. Without either, any host key is
+// accepted.
+func (ns nodeSSHConfig) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if ns.HostKey != "" {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(ns.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("node_ssh host_key: %s", err)
+		}
+		return ssh.FixedHostKey(key), nil
+	}
+	if ns.KnownHostsFile != "" {
+		callback, err := knownhosts.New(ns.KnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("node_ssh known_hosts_file: %s", err)
+		}
+		return callback, nil
+	}
+	return ssh.InsecureIgnoreHostKey(), nil
+}
+
+// resolveNodeSSH returns the node_ssh settings with the host set to the
+// address of the node in the cluster status if it isn't set, so the node
+// names don't need to resolve on the machine running Packer. If the cluster
+// status has no address, the host of proxmox_url is used.
+func (c *Config) resolveNodeSSH(client nodeNetworkReader) nodeSSHConfig {
+	ns := c.NodeSSH
+	if ns.Host != "" {
+		return ns
+	}
+	address, err := getNodeAddress(client, c.Node)
+	if err != nil {
+		log.Printf("Error reading the address of node %s: %s", c.Node, err)
+	}
+	if address == "" {
+		address = c.proxmoxURL.Hostname()
+	}
+	ns.Host = address
+	return ns
+}
+
+// getNodeAddress returns the address of node in the cluster status, or an
+// empty string if it has none.
+func getNodeAddress(client nodeNetworkReader, node string) (string, error) {
+	list, err := client.GetItemList("/cluster/status")
+	if err != nil {
+		return "", err
+	}
+	entries, _ := list["data"].([]interface{})
+	for _, entry := range entries {
+		entry, ok := entry.(map[string]interface{})
+		if ok && entry["type"] == "node" && entry["name"] == node {
+			address, _ := entry["ip"].(string)
+			return address, nil
+		}
+	}
+	return "", nil
+}
+
+// dialNode opens an SSH connection to the Proxmox node with the node_ssh
+// credentials.
+func dialNode(ns nodeSSHConfig) (*ssh.Client, error) {
+	var auth []ssh.AuthMethod
+	if ns.PrivateKeyFile != "" {
+		key, err := os.ReadFile(ns.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading node_ssh private_key_file: %s", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing node_ssh private_key_file: %s", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if ns.Password != "" {
+		auth = append(auth, ssh.Password(ns.Password))
+	}
+
+	hostKeyCallback, err := ns.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.JoinHostPort(ns.Host, strconv.Itoa(ns.Port))
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            ns.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         ns.Timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to the Proxmox node %s over SSH: %s", addr, err)
+	}
+	return client, nil
+}
@@ -0,0 +1,133 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestResolveNodeSSH(t *testing.T) {
+	clusterStatus := map[string]interface{}{"data": []interface{}{
+		map[string]interface{}{"type": "cluster", "name": "lab"},
+		map[string]interface{}{"type": "node", "name": "pve1", "ip": "192.0.2.2"},
+		map[string]interface{}{"type": "node", "name": "my-proxmox", "ip": "192.0.2.3"},
+	}}
+
+	resolveNodeSSHTest := []struct {
+		name         string
+		host         string
+		status       map[string]interface{}
+		statusErr    error
+		expectedHost string
+	}{
+		{
+			name:         "host from cluster status",
+			status:       clusterStatus,
+			expectedHost: "192.0.2.3",
+		},
+		{
+			name:         "host set, kept",
+			host:         "pve.example.com",
+			status:       clusterStatus,
+			expectedHost: "pve.example.com",
+		},
+		{
+			name:         "node not in cluster status, proxmox_url host",
+			status:       map[string]interface{}{"data": []interface{}{}},
+			expectedHost: "my-proxmox.my-domain",
+		},
+		{
+			name:         "cluster status error, proxmox_url host",
+			statusErr:    fmt.Errorf("permission denied"),
+			expectedHost: "my-proxmox.my-domain",
+		},
+	}
+
+	for _, tt := range resolveNodeSSHTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			cfg["ssh_bastion_node"] = true
+			cfg["node_ssh"] = map[string]interface{}{"host": tt.host, "password": "secret"}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+
+			client := nodeNetworkReaderMock{
+				getItemList: func(url string) (map[string]interface{}, error) {
+					if url != "/cluster/status" {
+						t.Errorf("unexpected request of %s", url)
+					}
+					return tt.status, tt.statusErr
+				},
+			}
+			ns := c.resolveNodeSSH(client)
+			if ns.Host != tt.expectedHost {
+				t.Errorf("expected host %q, got %q", tt.expectedHost, ns.Host)
+			}
+		})
+	}
+}
+
+func TestSSHBastionNodeConfig(t *testing.T) {
+	sshBastionNodeTest := []struct {
+		name          string
+		config        map[string]interface{}
+		expectFailure bool
+	}{
+		{
+			name: "bastion node with password, no error",
+			config: map[string]interface{}{
+				"ssh_bastion_node": true,
+				"node_ssh":         map[string]interface{}{"password": "secret"},
+			},
+		},
+		{
+			name:          "bastion node without credentials, fail",
+			config:        map[string]interface{}{"ssh_bastion_node": true},
+			expectFailure: true,
+		},
+		{
+			name: "bastion node with bastion host, fail",
+			config: map[string]interface{}{
+				"ssh_bastion_node":     true,
+				"node_ssh":             map[string]interface{}{"password": "secret"},
+				"ssh_bastion_host":     "bastion.example.com",
+				"ssh_bastion_password": "secret",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "bastion node with winrm, fail",
+			config: map[string]interface{}{
+				"ssh_bastion_node": true,
+				"node_ssh":         map[string]interface{}{"password": "secret"},
+				"communicator":     "winrm",
+				"winrm_username":   "Administrator",
+			},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range sshBastionNodeTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for key, value := range tt.config {
+				cfg[key] = value
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+		})
+	}
+}
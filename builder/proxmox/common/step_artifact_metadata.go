@@ -0,0 +1,116 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+type artifactMetadataReader interface {
+	GetVmConfig(*proxmox.VmRef) (map[string]interface{}, error)
+	GetItemList(url string) (map[string]interface{}, error)
+}
+
+var _ artifactMetadataReader = &proxmox.Client{}
+
+// Drives holding the disks of a VM, cdroms excluded
+var templateDiskRe = regexp.MustCompile(`^((ide|sata|scsi|virtio)\d+|efidisk0|tpmstate0)$`)
+
+// stepArtifactMetadata describes the template in the "metadata" state of
+// the artifact, which is also the source of the labels for the HCP Packer
+// registry. Builders set what the template was built from with the
+// "source_image" state key, and "source_image_id" if it is a template
+// tracked in the registry as well.
+//
+// The template is already built at this point, so details that can't be
+// read are left out instead of failing the build.
+type stepArtifactMetadata struct{}
+
+func (s *stepArtifactMetadata) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	client := state.Get("proxmoxClient").(artifactMetadataReader)
+	c := state.Get("config").(*Config)
+	vmRef := state.Get("vmRef").(*proxmox.VmRef)
+
+	metadata := map[string]string{
+		"vm_id":         strconv.Itoa(vmRef.VmId()),
+		"node":          c.Node,
+		"template_name": c.VMName,
+	}
+	if c.TemplateName != "" {
+		metadata["template_name"] = c.TemplateName
+	}
+	if c.Pool != "" {
+		metadata["pool"] = c.Pool
+	}
+	if sourceImage, ok := state.Get("source_image").(string); ok {
+		metadata["source_image"] = sourceImage
+	}
+	if sourceImageID, ok := state.Get("source_image_id").(string); ok {
+		metadata["source_image_id"] = sourceImageID
+	}
+
+	vmConfig, err := client.GetVmConfig(vmRef)
+	if err != nil {
+		log.Printf("Error reading the storage of the template: %s", err)
+	} else if storages := templateStorages(vmConfig); len(storages) > 0 {
+		metadata["storage"] = strings.Join(storages, ",")
+	}
+
+	clusterName, err := getClusterName(client)
+	if err != nil {
+		log.Printf("Error reading the cluster name: %s", err)
+	} else if clusterName != "" {
+		metadata["cluster_name"] = clusterName
+	}
+
+	AddArtifactState(state, "metadata", metadata)
+	return multistep.ActionContinue
+}
+
+func (s *stepArtifactMetadata) Cleanup(state multistep.StateBag) {}
+
+// templateStorages returns the storages the disks of a VM are on, sorted.
+func templateStorages(vmConfig map[string]interface{}) []string {
+	seen := map[string]bool{}
+	storages := []string{}
+	for key, value := range vmConfig {
+		drive, ok := value.(string)
+		if !ok || !templateDiskRe.MatchString(key) || strings.Contains(drive, "media=cdrom") {
+			continue
+		}
+		storage, _, _ := strings.Cut(drive, ":")
+		if !seen[storage] {
+			seen[storage] = true
+			storages = append(storages, storage)
+		}
+	}
+	sort.Strings(storages)
+	return storages
+}
+
+// getClusterName returns the name of the cluster the nodes are in, or an
+// empty string for a standalone node.
+func getClusterName(client artifactMetadataReader) (string, error) {
+	list, err := client.GetItemList("/cluster/status")
+	if err != nil {
+		return "", err
+	}
+	entries, _ := list["data"].([]interface{})
+	for _, entry := range entries {
+		entry, ok := entry.(map[string]interface{})
+		if ok && entry["type"] == "cluster" {
+			name, _ := entry["name"].(string)
+			return name, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,137 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	registryimage "github.com/hashicorp/packer-plugin-sdk/packer/registry/image"
+	"github.com/stretchr/testify/assert"
+)
+
+type artifactMetadataReaderMock struct {
+	vmConfig      map[string]interface{}
+	clusterStatus []interface{}
+	fail          bool
+}
+
+func (m *artifactMetadataReaderMock) GetVmConfig(*proxmox.VmRef) (map[string]interface{}, error) {
+	if m.fail {
+		return nil, fmt.Errorf("500 Internal Server Error")
+	}
+	return m.vmConfig, nil
+}
+
+func (m *artifactMetadataReaderMock) GetItemList(url string) (map[string]interface{}, error) {
+	if m.fail {
+		return nil, fmt.Errorf("403 Permission check failed")
+	}
+	return map[string]interface{}{"data": m.clusterStatus}, nil
+}
+
+var _ artifactMetadataReader = &artifactMetadataReaderMock{}
+
+func TestArtifactMetadata(t *testing.T) {
+	cs := []struct {
+		name             string
+		config           *Config
+		mock             *artifactMetadataReaderMock
+		sourceImageID    string
+		expectedMetadata map[string]string
+		expectedRegion   string
+	}{
+		{
+			name:   "standalone node",
+			config: &Config{Node: "pve", TemplateName: "debian-12"},
+			mock: &artifactMetadataReaderMock{
+				vmConfig: map[string]interface{}{
+					"scsi0":    "local-lvm:base-100-disk-0,size=10G",
+					"scsi1":    "ceph:base-100-disk-1,size=10G",
+					"efidisk0": "local-lvm:base-100-disk-2,size=4M",
+					"ide2":     "local:iso/debian-12.iso,media=cdrom",
+				},
+				clusterStatus: []interface{}{
+					map[string]interface{}{"type": "node", "name": "pve"},
+				},
+			},
+			expectedMetadata: map[string]string{
+				"vm_id":         "100",
+				"node":          "pve",
+				"template_name": "debian-12",
+				"storage":       "ceph,local-lvm",
+				"source_image":  "local:iso/debian-12.iso",
+			},
+			expectedRegion: "pve",
+		},
+		{
+			name:   "cluster and pool",
+			config: &Config{Node: "pve", VMName: "debian-12", Pool: "templates"},
+			mock: &artifactMetadataReaderMock{
+				vmConfig: map[string]interface{}{
+					"virtio0": "local-lvm:base-100-disk-0,size=10G",
+				},
+				clusterStatus: []interface{}{
+					map[string]interface{}{"type": "cluster", "name": "lab"},
+					map[string]interface{}{"type": "node", "name": "pve"},
+				},
+			},
+			sourceImageID: "9000",
+			expectedMetadata: map[string]string{
+				"vm_id":           "100",
+				"node":            "pve",
+				"template_name":   "debian-12",
+				"pool":            "templates",
+				"storage":         "local-lvm",
+				"cluster_name":    "lab",
+				"source_image":    "local:iso/debian-12.iso",
+				"source_image_id": "9000",
+			},
+			expectedRegion: "lab",
+		},
+		{
+			name:   "failed lookups are left out",
+			config: &Config{Node: "pve", VMName: "debian-12"},
+			mock:   &artifactMetadataReaderMock{fail: true},
+			expectedMetadata: map[string]string{
+				"vm_id":         "100",
+				"node":          "pve",
+				"template_name": "debian-12",
+				"source_image":  "local:iso/debian-12.iso",
+			},
+			expectedRegion: "pve",
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			state := new(multistep.BasicStateBag)
+			state.Put("config", c.config)
+			state.Put("proxmoxClient", c.mock)
+			state.Put("vmRef", proxmox.NewVmRef(100))
+			state.Put("source_image", "local:iso/debian-12.iso")
+			if c.sourceImageID != "" {
+				state.Put("source_image_id", c.sourceImageID)
+			}
+
+			step := &stepArtifactMetadata{}
+			action := step.Run(context.TODO(), state)
+			assert.Equal(t, multistep.ActionContinue, action)
+
+			artifactState := state.Get("artifact_state").(map[string]interface{})
+			assert.Equal(t, c.expectedMetadata, artifactState["metadata"])
+
+			artifact := &Artifact{builderID: "proxmox.iso", templateID: 100, StateData: artifactState}
+			img := artifact.State(registryimage.ArtifactStateURI).(*registryimage.Image)
+			assert.Equal(t, "proxmox", img.ProviderName)
+			assert.Equal(t, "100", img.ImageID)
+			assert.Equal(t, c.expectedRegion, img.ProviderRegion)
+			assert.Equal(t, c.sourceImageID, img.SourceImageID)
+			assert.Equal(t, c.expectedMetadata, img.Labels)
+		})
+	}
+}
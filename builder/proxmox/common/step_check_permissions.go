@@ -0,0 +1,246 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// PermissionRequirement is a privilege the API user or token needs on an
+// ACL path for the build.
+//
+// Builders can add their own requirements to the ones of the common config by
+// putting a []PermissionRequirement in the "permission_requirements" state key.
+type PermissionRequirement struct {
+	Path      string
+	Privilege string
+}
+
+// stepCheckPermissions checks the effective permissions of the API user or
+// token before any resource is created, so missing privileges are reported
+// together instead of as a 403 halfway through the build.
+type stepCheckPermissions struct{}
+
+type permissionLister interface {
+	GetItemList(url string) (map[string]interface{}, error)
+	GetVersion() (map[string]interface{}, error)
+}
+
+// privilegeAlternatives lists privileges removed in a Proxmox VE major
+// version, and the privileges that grant the same access from that version
+// on.
+var privilegeAlternatives = map[string]struct {
+	removedIn    int
+	alternatives []string
+}{
+	// VM.Monitor was split up in PVE 9, reading guest agent data needs
+	// VM.GuestAgent.Audit or Sys.Audit instead
+	"VM.Monitor": {removedIn: 9, alternatives: []string{"Sys.Audit", "VM.GuestAgent.Audit"}},
+}
+
+var _ permissionLister = &proxmox.Client{}
+
+func (s *stepCheckPermissions) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(permissionLister)
+	c := state.Get("config").(*Config)
+
+	storage := c.storageRequirements()
+	if extra, ok := state.GetOk("storage_requirements"); ok {
+		storage = append(storage, extra.([]StorageRequirement)...)
+	}
+	requirements := c.permissionRequirements(storage)
+	if extra, ok := state.GetOk("permission_requirements"); ok {
+		requirements = append(requirements, extra.([]PermissionRequirement)...)
+	}
+
+	ui.Say("Checking permissions of the API user")
+	version, err := pveMajorVersion(client)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Warning: could not read the Proxmox VE version, privileges removed in newer versions are still required: %s", err))
+	}
+
+	missing := []string{}
+	granted := map[string]map[string]interface{}{}
+	for _, req := range requirements {
+		privileges, ok := granted[req.Path]
+		if !ok {
+			var err error
+			privileges, err = effectivePermissions(client, req.Path)
+			if err != nil {
+				// The check only reports missing privileges early, the build
+				// can still succeed without it.
+				ui.Error(fmt.Sprintf("Warning: could not check permissions on %s, skipping: %s", req.Path, err))
+			}
+			granted[req.Path] = privileges
+		}
+		if privileges == nil {
+			continue
+		}
+		if !hasPrivilege(privileges, req.Privilege, version) {
+			missing = append(missing, fmt.Sprintf("%s on %s", req.Privilege, req.Path))
+		}
+	}
+
+	if len(missing) > 0 {
+		err := fmt.Errorf("API user %s is missing privileges: %s", c.Username, strings.Join(missing, ", "))
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+func (s *stepCheckPermissions) Cleanup(state multistep.StateBag) {}
+
+// hasPrivilege returns whether privileges grants privilege, or the privileges
+// replacing it on Proxmox VE major version version.
+func hasPrivilege(privileges map[string]interface{}, privilege string, version int) bool {
+	if _, ok := privileges[privilege]; ok {
+		return true
+	}
+	alt, ok := privilegeAlternatives[privilege]
+	if !ok || version < alt.removedIn {
+		return false
+	}
+	for _, a := range alt.alternatives {
+		if _, ok := privileges[a]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// pveMajorVersion returns the major version of the Proxmox VE API.
+func pveMajorVersion(client permissionLister) (int, error) {
+	resp, err := client.GetVersion()
+	if err != nil {
+		return 0, err
+	}
+	data, _ := resp["data"].(map[string]interface{})
+	version, _ := data["version"].(string)
+	major, _, _ := strings.Cut(version, ".")
+	v, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected version %q", version)
+	}
+	return v, nil
+}
+
+// effectivePermissions returns the privileges the API user or token has on
+// path, including privileges propagated from parent paths.
+func effectivePermissions(client permissionLister, path string) (map[string]interface{}, error) {
+	list, err := client.GetItemList("/access/permissions?path=" + url.QueryEscape(path))
+	if err != nil {
+		return nil, err
+	}
+	data, ok := list["data"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected response listing permissions: %v", list)
+	}
+	privileges, _ := data[path].(map[string]interface{})
+	if privileges == nil {
+		privileges = map[string]interface{}{}
+	}
+	return privileges, nil
+}
+
+// VMACLPath returns the ACL path privileges for creating and configuring the
+// build VM are checked on. VMs can be created if the privileges are granted
+// on either the VM or the pool it is created in.
+func (c *Config) VMACLPath() string {
+	if c.Pool != "" {
+		return "/pool/" + c.Pool
+	}
+	if c.VMID != 0 {
+		return fmt.Sprintf("/vms/%d", c.VMID)
+	}
+	return "/vms"
+}
+
+// permissionRequirements returns the privileges needed for the common config,
+// with storage being the storage pools used by the build.
+func (c *Config) permissionRequirements(storage []StorageRequirement) []PermissionRequirement {
+	var requirements []PermissionRequirement
+	add := func(path string, privileges ...string) {
+		for _, privilege := range privileges {
+			req := PermissionRequirement{Path: path, Privilege: privilege}
+			found := false
+			for _, r := range requirements {
+				if r == req {
+					found = true
+					break
+				}
+			}
+			if !found {
+				requirements = append(requirements, req)
+			}
+		}
+	}
+
+	createPath := c.VMACLPath()
+	add(createPath,
+		"VM.Allocate",
+		"VM.Audit",
+		"VM.Config.CDROM",
+		"VM.Config.CPU",
+		"VM.Config.Disk",
+		"VM.Config.HWType",
+		"VM.Config.Memory",
+		"VM.Config.Network",
+		"VM.Config.Options",
+		"VM.PowerMgmt",
+	)
+	if len(c.BootCommand) > 0 {
+		add(createPath, "VM.Console")
+	}
+	if c.Comm.Host() == "" {
+		// the IP of the VM is read through the guest agent; on PVE 9 and
+		// later VM.GuestAgent.Audit or Sys.Audit grant this instead
+		add(createPath, "VM.Monitor")
+	}
+	if c.CloudInit {
+		add(createPath, "VM.Config.Cloudinit")
+	}
+	if c.Checkpoints || c.ResumeFromSnapshot != "" {
+		add(createPath, "VM.Snapshot")
+	}
+	if c.ResumeFromSnapshot != "" {
+		add(createPath, "VM.Snapshot.Rollback")
+	}
+	if c.PackerForce {
+		// an existing template is looked up and deleted, which may not be
+		// part of the pool the new VM is created in
+		vmPath := "/vms"
+		if c.VMID != 0 {
+			vmPath = fmt.Sprintf("/vms/%d", c.VMID)
+		}
+		add(vmPath, "VM.Audit", "VM.Allocate")
+	}
+
+	for _, device := range c.PCIDevices {
+		if device.Mapping != "" {
+			add("/mapping/pci/"+device.Mapping, "Mapping.Use")
+		}
+	}
+
+	for _, req := range storage {
+		path := "/storage/" + req.Pool
+		add(path, "Datastore.Audit")
+		if req.Content == "images" {
+			add(path, "Datastore.AllocateSpace")
+		} else {
+			add(path, "Datastore.AllocateTemplate")
+		}
+	}
+	return requirements
+}
@@ -0,0 +1,205 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type permissionListerMock struct {
+	storageListerMock
+	version string
+}
+
+func (m permissionListerMock) GetVersion() (map[string]interface{}, error) {
+	if m.version == "" {
+		return nil, fmt.Errorf("500 Internal Server Error")
+	}
+	return map[string]interface{}{
+		"data": map[string]interface{}{"version": m.version},
+	}, nil
+}
+
+var _ permissionLister = permissionListerMock{}
+
+func TestStepCheckPermissions(t *testing.T) {
+	vmPrivileges := map[string]interface{}{
+		"VM.Allocate": 1, "VM.Audit": 1, "VM.Config.CDROM": 1, "VM.Config.CPU": 1,
+		"VM.Config.Disk": 1, "VM.Config.HWType": 1, "VM.Config.Memory": 1,
+		"VM.Config.Network": 1, "VM.Config.Options": 1, "VM.PowerMgmt": 1,
+		"VM.Monitor": 1,
+	}
+	pve9Privileges := map[string]interface{}{}
+	for k, v := range vmPrivileges {
+		pve9Privileges[k] = v
+	}
+	delete(pve9Privileges, "VM.Monitor")
+	pve9Privileges["VM.GuestAgent.Audit"] = 1
+
+	cs := []struct {
+		name            string
+		config          *Config
+		version         string
+		extra           []PermissionRequirement
+		granted         map[string]map[string]interface{}
+		failPaths       []string
+		expectedAction  multistep.StepAction
+		expectedMissing []string
+	}{
+		{
+			name: "all privileges granted",
+			config: &Config{
+				Disks: []diskConfig{{StoragePool: "local-lvm", Size: "20G"}},
+			},
+			granted: map[string]map[string]interface{}{
+				"/vms":               vmPrivileges,
+				"/storage/local-lvm": {"Datastore.Audit": 1, "Datastore.AllocateSpace": 1},
+			},
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name: "missing privileges are listed together",
+			config: &Config{
+				Pool:       "packer",
+				Disks:      []diskConfig{{StoragePool: "local-lvm", Size: "20G"}},
+				CloudInit:  true,
+				PCIDevices: []pciDeviceConfig{{Mapping: "gpu"}},
+			},
+			extra: []PermissionRequirement{
+				{Path: "/", Privilege: "Sys.Modify"},
+			},
+			granted: map[string]map[string]interface{}{
+				"/pool/packer":       vmPrivileges,
+				"/storage/local-lvm": {"Datastore.Audit": 1},
+			},
+			expectedAction: multistep.ActionHalt,
+			expectedMissing: []string{
+				"VM.Config.Cloudinit on /pool/packer",
+				"Mapping.Use on /mapping/pci/gpu",
+				"Datastore.AllocateSpace on /storage/local-lvm",
+				"Sys.Modify on /",
+			},
+		},
+		{
+			name: "VM.Monitor is replaced by VM.GuestAgent.Audit on PVE 9",
+			config: &Config{
+				Disks: []diskConfig{{StoragePool: "local-lvm", Size: "20G"}},
+			},
+			version: "9.0.3",
+			granted: map[string]map[string]interface{}{
+				"/vms":               pve9Privileges,
+				"/storage/local-lvm": {"Datastore.Audit": 1, "Datastore.AllocateSpace": 1},
+			},
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name: "VM.Monitor is required before PVE 9",
+			config: &Config{
+				Disks: []diskConfig{{StoragePool: "local-lvm", Size: "20G"}},
+			},
+			version: "8.4.1",
+			granted: map[string]map[string]interface{}{
+				"/vms":               pve9Privileges,
+				"/storage/local-lvm": {"Datastore.Audit": 1, "Datastore.AllocateSpace": 1},
+			},
+			expectedAction:  multistep.ActionHalt,
+			expectedMissing: []string{"VM.Monitor on /vms"},
+		},
+		{
+			name: "paths that can't be checked are skipped",
+			config: &Config{
+				Disks: []diskConfig{{StoragePool: "local-lvm", Size: "20G"}},
+			},
+			granted: map[string]map[string]interface{}{
+				"/storage/local-lvm": {"Datastore.Audit": 1},
+			},
+			failPaths:       []string{"/vms"},
+			expectedAction:  multistep.ActionHalt,
+			expectedMissing: []string{"Datastore.AllocateSpace on /storage/local-lvm"},
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			version := c.version
+			if version == "" {
+				version = "8.0.3"
+			}
+			mock := permissionListerMock{version: version}
+			mock.getItemList = func(u string) (map[string]interface{}, error) {
+				parsed, err := url.Parse(u)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if parsed.Path != "/access/permissions" {
+					t.Fatalf("unexpected url %s", u)
+				}
+				path := parsed.Query().Get("path")
+				for _, p := range c.failPaths {
+					if p == path {
+						return nil, fmt.Errorf("403 Permission check failed")
+					}
+				}
+				privileges := map[string]interface{}{}
+				for k, v := range c.granted[path] {
+					privileges[k] = v
+				}
+				return map[string]interface{}{
+					"data": map[string]interface{}{path: privileges},
+				}, nil
+			}
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("config", c.config)
+			state.Put("proxmoxClient", mock)
+			if c.extra != nil {
+				state.Put("permission_requirements", c.extra)
+			}
+
+			step := stepCheckPermissions{}
+			action := step.Run(context.TODO(), state)
+			if action != c.expectedAction {
+				t.Fatalf("Expected action %s, got %s", c.expectedAction, action)
+			}
+			if c.expectedAction != multistep.ActionHalt {
+				return
+			}
+
+			err := state.Get("error").(error)
+			for _, missing := range c.expectedMissing {
+				if !strings.Contains(err.Error(), missing) {
+					t.Errorf("Expected error to list %q, got %s", missing, err)
+				}
+			}
+			if got := strings.Count(err.Error(), " on /"); got != len(c.expectedMissing) {
+				t.Errorf("Expected %d missing privileges, got %d: %s", len(c.expectedMissing), got, err)
+			}
+		})
+	}
+}
+
+func TestPermissionRequirementsWithForce(t *testing.T) {
+	c := &Config{
+		Pool:         "packer",
+		VMID:         1000,
+		PackerConfig: common.PackerConfig{PackerForce: true},
+	}
+	requirements := c.permissionRequirements(nil)
+
+	expected := PermissionRequirement{Path: "/vms/1000", Privilege: "VM.Allocate"}
+	for _, req := range requirements {
+		if req == expected {
+			return
+		}
+	}
+	t.Errorf("Expected %v in requirements, got %v", expected, requirements)
+}
@@ -0,0 +1,154 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Names of the snapshots taken with checkpoints enabled
+const (
+	checkpointConnected   = "packer-connected"
+	checkpointProvisioned = "packer-provisioned"
+)
+
+var checkpointNames = []string{checkpointConnected, checkpointProvisioned}
+
+// See the pve-configid format of the Proxmox API
+var validSnapshotName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]{1,39}$`)
+
+type checkpointer interface {
+	CreateQemuSnapshot(*proxmox.VmRef, string) (string, error)
+	DeleteQemuSnapshot(*proxmox.VmRef, string) (string, error)
+}
+
+var _ checkpointer = &proxmox.Client{}
+
+// stepCheckpoint takes a snapshot of the build VM, so a build that fails in
+// a later step and is aborted can be continued from it with
+// resume_from_snapshot.
+//
+// Packer runs all provisioners in a single hook, there is no way to take a
+// snapshot between two of them.
+//
+// The snapshots taken are kept in the "checkpoints" state key.
+type stepCheckpoint struct {
+	name string
+}
+
+func (s *stepCheckpoint) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(checkpointer)
+	c := state.Get("config").(*Config)
+	vmRef := state.Get("vmRef").(*proxmox.VmRef)
+
+	if !c.Checkpoints {
+		return multistep.ActionContinue
+	}
+
+	checkpoints, _ := state.Get("checkpoints").([]string)
+	// A resumed VM still has the checkpoints of the build it was resumed
+	// from, they are replaced by the ones of this build
+	if containsString(checkpoints, s.name) {
+		_, err := client.DeleteQemuSnapshot(vmRef, s.name)
+		if err != nil {
+			err := fmt.Errorf("Error deleting previous checkpoint %s: %s", s.name, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		checkpoints = removeString(checkpoints, s.name)
+	}
+
+	ui.Say(fmt.Sprintf("Taking checkpoint snapshot %s", s.name))
+	_, err := client.CreateQemuSnapshot(vmRef, s.name)
+	if err != nil {
+		err := fmt.Errorf("Error taking checkpoint %s: %s", s.name, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	state.Put("checkpoints", append(checkpoints, s.name))
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCheckpoint) Cleanup(state multistep.StateBag) {}
+
+// stepProvision runs the provisioners, unless the VM was resumed from the
+// checkpoint taken after they ran.
+type stepProvision struct {
+	commonsteps.StepProvision
+}
+
+func (s *stepProvision) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if snapshot, _ := state.Get("resumed_from_snapshot").(string); snapshot == checkpointProvisioned {
+		ui := state.Get("ui").(packersdk.Ui)
+		ui.Say(fmt.Sprintf("VM was resumed from snapshot %s, skipping provisioners", snapshot))
+		return multistep.ActionContinue
+	}
+	return s.StepProvision.Run(ctx, state)
+}
+
+// usesTemporarySSHKey reports whether the SSH communicator logs in with a
+// key pair generated for the build.
+func (c *Config) usesTemporarySSHKey() bool {
+	return c.Comm.Type == "ssh" && c.Comm.SSHPassword == "" &&
+		c.Comm.SSHPrivateKeyFile == "" && !c.Comm.SSHAgentAuth
+}
+
+// stepRemoveCheckpoints deletes the checkpoint snapshots before the VM is
+// converted to a template, the template shouldn't carry the history of its
+// build.
+type stepRemoveCheckpoints struct{}
+
+func (s *stepRemoveCheckpoints) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(checkpointer)
+	vmRef := state.Get("vmRef").(*proxmox.VmRef)
+
+	checkpoints, _ := state.Get("checkpoints").([]string)
+	// Newest first, so no snapshot has to be reparented
+	for i := len(checkpoints) - 1; i >= 0; i-- {
+		ui.Say(fmt.Sprintf("Deleting checkpoint snapshot %s", checkpoints[i]))
+		_, err := client.DeleteQemuSnapshot(vmRef, checkpoints[i])
+		if err != nil {
+			err := fmt.Errorf("Error deleting checkpoint %s: %s", checkpoints[i], err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		state.Put("checkpoints", checkpoints[:i])
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRemoveCheckpoints) Cleanup(state multistep.StateBag) {}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(list []string, s string) []string {
+	var result []string
+	for _, item := range list {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+	return result
+}
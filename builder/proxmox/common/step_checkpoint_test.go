@@ -0,0 +1,158 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+)
+
+type checkpointerMock struct {
+	createFail bool
+	deleteFail bool
+	created    []string
+	deleted    []string
+}
+
+func (m *checkpointerMock) CreateQemuSnapshot(vmRef *proxmox.VmRef, name string) (string, error) {
+	if m.createFail {
+		return "", fmt.Errorf("snapshot feature is not available")
+	}
+	m.created = append(m.created, name)
+	return "", nil
+}
+
+func (m *checkpointerMock) DeleteQemuSnapshot(vmRef *proxmox.VmRef, name string) (string, error) {
+	if m.deleteFail {
+		return "", fmt.Errorf("snapshot is locked")
+	}
+	m.deleted = append(m.deleted, name)
+	return "", nil
+}
+
+var _ checkpointer = &checkpointerMock{}
+
+func TestCheckpoint(t *testing.T) {
+	cs := []struct {
+		name                string
+		checkpoints         bool
+		previousCheckpoints []string
+		createFail          bool
+		expectedAction      multistep.StepAction
+		expectCreated       []string
+		expectDeleted       []string
+		expectCheckpoints   []string
+	}{
+		{
+			name:           "checkpoints disabled, no snapshot",
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:              "snapshot is taken",
+			checkpoints:       true,
+			expectedAction:    multistep.ActionContinue,
+			expectCreated:     []string{checkpointProvisioned},
+			expectCheckpoints: []string{checkpointProvisioned},
+		},
+		{
+			name:                "checkpoint of a resumed build is replaced",
+			checkpoints:         true,
+			previousCheckpoints: []string{checkpointConnected, checkpointProvisioned},
+			expectedAction:      multistep.ActionContinue,
+			expectCreated:       []string{checkpointProvisioned},
+			expectDeleted:       []string{checkpointProvisioned},
+			expectCheckpoints:   []string{checkpointConnected, checkpointProvisioned},
+		},
+		{
+			name:           "failed snapshot should halt",
+			checkpoints:    true,
+			createFail:     true,
+			expectedAction: multistep.ActionHalt,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			m := &checkpointerMock{createFail: c.createFail}
+
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("config", &Config{Checkpoints: c.checkpoints})
+			state.Put("proxmoxClient", m)
+			state.Put("vmRef", proxmox.NewVmRef(100))
+			if c.previousCheckpoints != nil {
+				state.Put("checkpoints", c.previousCheckpoints)
+			}
+
+			step := &stepCheckpoint{name: checkpointProvisioned}
+			action := step.Run(context.TODO(), state)
+			assert.Equal(t, c.expectedAction, action)
+			assert.Equal(t, c.expectCreated, m.created)
+			assert.Equal(t, c.expectDeleted, m.deleted)
+			if action == multistep.ActionContinue {
+				checkpoints, _ := state.Get("checkpoints").([]string)
+				assert.Equal(t, c.expectCheckpoints, checkpoints)
+			}
+		})
+	}
+}
+
+func TestRemoveCheckpoints(t *testing.T) {
+	cs := []struct {
+		name              string
+		checkpoints       []string
+		deleteFail        bool
+		expectedAction    multistep.StepAction
+		expectDeleted     []string
+		expectCheckpoints []string
+	}{
+		{
+			name:           "no checkpoints, nothing deleted",
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:              "checkpoints are deleted newest first",
+			checkpoints:       []string{checkpointConnected, checkpointProvisioned},
+			expectedAction:    multistep.ActionContinue,
+			expectDeleted:     []string{checkpointProvisioned, checkpointConnected},
+			expectCheckpoints: []string{},
+		},
+		{
+			name:              "failed deletion should halt and keep the checkpoints",
+			checkpoints:       []string{checkpointConnected, checkpointProvisioned},
+			deleteFail:        true,
+			expectedAction:    multistep.ActionHalt,
+			expectCheckpoints: []string{checkpointConnected, checkpointProvisioned},
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			m := &checkpointerMock{deleteFail: c.deleteFail}
+
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("config", &Config{Checkpoints: true})
+			state.Put("proxmoxClient", m)
+			state.Put("vmRef", proxmox.NewVmRef(100))
+			if c.checkpoints != nil {
+				state.Put("checkpoints", c.checkpoints)
+			}
+
+			step := &stepRemoveCheckpoints{}
+			action := step.Run(context.TODO(), state)
+			assert.Equal(t, c.expectedAction, action)
+			assert.Equal(t, c.expectDeleted, m.deleted)
+			if c.checkpoints != nil {
+				assert.Equal(t, c.expectCheckpoints, state.Get("checkpoints"))
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// ProxmoxVMConfigurer can be implemented by a ProxmoxVMCreator to apply its
+// builder specific changes to the VM config without creating the VM. It is
+// used to show the complete config in dry runs.
+type ProxmoxVMConfigurer interface {
+	Configure(*proxmox.ConfigQemu, multistep.StateBag) error
+}
+
+// ProxmoxDryRunPreparer can be implemented by a ProxmoxVMCreator whose
+// Configure depends on state set by local steps, like inspecting a
+// downloaded image. The steps run before stepDryRun, nothing is uploaded to
+// or created on Proxmox.
+type ProxmoxDryRunPreparer interface {
+	DryRunSteps() []multistep.Step
+}
+
+// stepDryRun resolves the config of the VM the build would create and prints
+// it together with the parameters sent to the Proxmox API, without creating
+// any resources.
+type stepDryRun struct {
+	vmCreator ProxmoxVMCreator
+}
+
+type idGetter interface {
+	GetNextID(int) (int, error)
+}
+
+var _ idGetter = &proxmox.Client{}
+
+func (s *stepDryRun) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(idGetter)
+	c := state.Get("config").(*Config)
+
+	config := generateConfigQemu(c)
+
+	id := c.VMID
+	if id == 0 {
+		genID, err := client.GetNextID(0)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		id = genID
+		config.VmID = genID
+	}
+	// Deterministic MAC addresses depend on the VM ID
+	config.QemuNetworks = generateProxmoxNetworkAdapters(resolveMACAddresses(c, id))
+	vmRef := proxmox.NewVmRef(id)
+	vmRef.SetNode(c.Node)
+	if c.Pool != "" {
+		vmRef.SetPool(c.Pool)
+		config.Pool = c.Pool
+	}
+
+	if configurer, ok := s.vmCreator.(ProxmoxVMConfigurer); ok {
+		if err := configurer.Configure(&config, state); err != nil {
+			err := fmt.Errorf("Error configuring VM: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+	if config.CIpassword != "" {
+		config.CIpassword = "<sensitive>"
+	}
+
+	configJSON, err := dryRunJSON(config)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	paramsJSON, err := dryRunJSON(dryRunParams(c, vmRef, config))
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Dry run, VM %d would be created on node %s", id, c.Node))
+	ui.Say("VM config:")
+	ui.Message(configJSON)
+	ui.Say("Proxmox API parameters:")
+	ui.Message(paramsJSON)
+	return multistep.ActionContinue
+}
+
+func (s *stepDryRun) Cleanup(state multistep.StateBag) {}
+
+// dryRunJSON formats v as indented JSON, without escaping characters like <
+// and > that are fine in terminal output.
+func dryRunJSON(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// dryRunParams returns the parameters of the API calls creating the VM. It
+// mirrors the parameters ConfigQemu.CreateVm of proxmox-api-go sends, merged
+// with the devices, hookscript and extra_config stepStartVM adds with config
+// updates.
+func dryRunParams(c *Config, vmRef *proxmox.VmRef, config proxmox.ConfigQemu) map[string]interface{} {
+	params := map[string]interface{}{
+		"vmid":        vmRef.VmId(),
+		"name":        config.Name,
+		"startup":     config.Startup,
+		"agent":       config.Agent,
+		"ostype":      config.QemuOs,
+		"sockets":     config.QemuSockets,
+		"cores":       config.QemuCores,
+		"cpu":         config.QemuCpu,
+		"hotplug":     config.Hotplug,
+		"memory":      config.Memory,
+		"boot":        config.Boot,
+		"description": config.Description,
+		"tags":        config.Tags,
+		"machine":     config.Machine,
+		"args":        config.Args,
+	}
+	if config.QemuNuma != nil {
+		params["numa"] = *config.QemuNuma
+	}
+	if config.QemuKVM != nil {
+		params["kvm"] = *config.QemuKVM
+	}
+	if config.Onboot != nil {
+		params["onboot"] = *config.Onboot
+	}
+	if config.QemuIso != "" {
+		params["ide2"] = config.QemuIso + ",media=cdrom"
+	}
+	if config.Bios != "" {
+		params["bios"] = config.Bios
+	}
+	if config.Balloon >= 1 {
+		params["balloon"] = config.Balloon
+	}
+	if config.Pool != "" {
+		params["pool"] = config.Pool
+	}
+	if config.Scsihw != "" {
+		params["scsihw"] = config.Scsihw
+	}
+	if vga := formatDeviceParam(config.QemuVga); vga != "" {
+		params["vga"] = vga
+	}
+	config.CreateQemuDisksParams(params, false)
+	config.CreateQemuEfiParams(params)
+	config.CreateQemuRngParams(params)
+	config.CreateQemuNetworksParams(params)
+	config.CreateQemuPCIsParams(params)
+	config.CreateQemuSerialsParams(params)
+
+	for _, iso := range c.AdditionalISOFiles {
+		if iso.ShouldUploadISO {
+			iso.ISOFile = fmt.Sprintf("%s:iso/<uploaded during build>", iso.ISOStoragePool)
+		}
+		for device, value := range generateProxmoxAdditionalISOs([]additionalISOsConfig{iso}) {
+			params[device] = value
+		}
+	}
+	if c.TPMConfig.TPMStoragePool != "" {
+		params["tpmstate0"] = generateProxmoxTpm(c.TPMConfig)
+	}
+	if c.Hookscript.File != "" {
+		params["hookscript"] = fmt.Sprintf("%s:snippets/<uploaded during build>", c.Hookscript.StoragePool)
+	} else if c.Hookscript.Volume != "" {
+		params["hookscript"] = c.Hookscript.Volume
+	}
+	for key, value := range c.ExtraConfig {
+		params[key] = value
+	}
+	return params
+}
+
+// formatDeviceParam formats a device the way proxmox-api-go does, with the
+// keys sorted so the output is stable.
+func formatDeviceParam(dev proxmox.QemuDevice) string {
+	keys := []string{}
+	for key := range dev {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	params := []string{}
+	for _, key := range keys {
+		switch value := dev[key].(type) {
+		case bool:
+			if value {
+				params = append(params, key+"=1")
+			}
+		case string:
+			if value != "" {
+				params = append(params, fmt.Sprintf("%s=%s", key, value))
+			}
+		case int:
+			if value > 0 {
+				params = append(params, fmt.Sprintf("%s=%d", key, value))
+			}
+		}
+	}
+	return strings.Join(params, ",")
+}
@@ -0,0 +1,92 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type dryRunMock struct {
+	t *testing.T
+}
+
+func (m dryRunMock) GetNextID(id int) (int, error) {
+	return 123, nil
+}
+
+func (m dryRunMock) Create(*proxmox.VmRef, proxmox.ConfigQemu, multistep.StateBag) error {
+	m.t.Error("VM must not be created in a dry run")
+	return nil
+}
+
+func (m dryRunMock) Configure(config *proxmox.ConfigQemu, state multistep.StateBag) error {
+	config.QemuIso = "local:iso/test.iso"
+	return nil
+}
+
+func TestStepDryRun(t *testing.T) {
+	c := &Config{
+		Node:           "pve",
+		VMName:         "dry-run",
+		SCSIController: "virtio-scsi-single",
+		Disks: []diskConfig{
+			{Type: "scsi", StoragePool: "local-lvm", Size: "20G", CacheMode: "none", DiskFormat: "raw"},
+		},
+		NICs: []NICConfig{
+			{Model: "virtio", Bridge: "vmbr0"},
+			{Model: "virtio", Bridge: "vmbr1", MACAddress: macAddressAutoDeterministic, MACAddressPrefix: defaultMACAddressPrefix},
+		},
+		AdditionalISOFiles: []additionalISOsConfig{
+			{Device: "ide3", ISOStoragePool: "local", ShouldUploadISO: true},
+			{Device: "sata4", ISOFile: "local:iso/virtio-win.iso"},
+		},
+		BIOS:      "ovmf",
+		TPMConfig: tpmConfig{TPMStoragePool: "local-lvm", Version: "v2.0"},
+	}
+
+	var out bytes.Buffer
+	ui := &packersdk.BasicUi{
+		Reader:      new(bytes.Buffer),
+		Writer:      &out,
+		ErrorWriter: io.Discard,
+	}
+	mock := dryRunMock{t: t}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", ui)
+	state.Put("config", c)
+	state.Put("proxmoxClient", mock)
+
+	step := stepDryRun{vmCreator: mock}
+	action := step.Run(context.TODO(), state)
+	if action != multistep.ActionContinue {
+		t.Fatalf("Expected action continue, got %s: %v", action, state.Get("error"))
+	}
+
+	for _, expected := range []string{
+		"VM 123 would be created on node pve",
+		`"vmid": 123`,
+		`"ide2": "local:iso/test.iso,media=cdrom"`,
+		`"ide3": "local:iso/<uploaded during build>,media=cdrom"`,
+		`"sata4": "local:iso/virtio-win.iso,media=cdrom"`,
+		`"scsi0": "local-lvm:20,`,
+		`"scsihw": "virtio-scsi-single"`,
+		`"net0": "virtio=`,
+		`"tpmstate0": "local-lvm:1,version=v2.0"`,
+		// the address of the VM ID the build would get
+		`"net1": "virtio=` + deterministicMACAddress(defaultMACAddressPrefix, "", 123, 1) + `,bridge=vmbr1`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected dry run output to contain %s, got:\n%s", expected, out.String())
+		}
+	}
+}
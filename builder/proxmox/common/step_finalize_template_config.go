@@ -42,6 +42,9 @@ func (s *stepFinalizeTemplateConfig) Run(ctx context.Context, state multistep.St
 	// During build, the description is "Packer ephemeral build VM", so if no description is
 	// set, we need to clear it
 	changes["description"] = c.TemplateDescription
+	if lineage, ok := state.Get("lineage").([]map[string]string); ok && c.RecordLineage {
+		changes["description"] = formatLineage(c.TemplateDescription, lineage)
+	}
 
 	vmParams, err := client.GetVmConfig(vmRef)
 	if err != nil {
@@ -111,7 +114,15 @@ func (s *stepFinalizeTemplateConfig) Run(ctx context.Context, state multistep.St
 			unusedDisks = append(unusedDisks, unusedDisk)
 		}
 	}
-	changes["delete"] = strings.Join(unusedDisks, ",")
+	deleteOptions := unusedDisks
+	if (c.Hookscript.File != "" || c.Hookscript.Volume != "") && !c.Hookscript.KeepOnTemplate {
+		deleteOptions = append(deleteOptions, "hookscript")
+	}
+	changes["delete"] = strings.Join(deleteOptions, ",")
+
+	for key, value := range c.TemplateExtraConfig {
+		changes[key] = value
+	}
 
 	if len(changes) > 0 {
 		_, err := client.SetVmConfig(vmRef, changes)
@@ -33,6 +33,7 @@ func TestTemplateFinalize(t *testing.T) {
 	cs := []struct {
 		name                string
 		builderConfig       *Config
+		lineage             []map[string]string
 		initialVMConfig     map[string]interface{}
 		getConfigErr        error
 		expectCallSetConfig bool
@@ -103,6 +104,64 @@ func TestTemplateFinalize(t *testing.T) {
 			},
 			expectedAction: multistep.ActionContinue,
 		},
+		{
+			name: "template_extra_config is added to the changes",
+			builderConfig: &Config{
+				TemplateName: "my-template",
+				TemplateExtraConfig: map[string]string{
+					"tags":    "golden;linux",
+					"ciuser":  "admin",
+					"startup": "order=2",
+				},
+			},
+			initialVMConfig: map[string]interface{}{
+				"name": "dummy",
+			},
+			expectCallSetConfig: true,
+			expectedVMConfig: map[string]interface{}{
+				"name":    "my-template",
+				"tags":    "golden;linux",
+				"ciuser":  "admin",
+				"startup": "order=2",
+			},
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name: "hookscript is detached unless kept on the template",
+			builderConfig: &Config{
+				TemplateName: "my-template",
+				Hookscript: hookscriptConfig{
+					Volume: "local:snippets/hook.sh",
+				},
+			},
+			initialVMConfig: map[string]interface{}{
+				"name":       "dummy",
+				"hookscript": "local:snippets/hook.sh",
+			},
+			expectCallSetConfig: true,
+			expectedVMConfig: map[string]interface{}{
+				"name": "my-template",
+			},
+			expectedDelete: []string{"hookscript"},
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name: "lineage is appended to the description",
+			builderConfig: &Config{
+				TemplateDescription: "some-description",
+				RecordLineage:       true,
+			},
+			lineage: []map[string]string{{"template_id": "1"}},
+			initialVMConfig: map[string]interface{}{
+				"name":        "dummy",
+				"description": "Packer ephemeral build VM",
+			},
+			expectCallSetConfig: true,
+			expectedVMConfig: map[string]interface{}{
+				"description": "some-description\n\n```packer-lineage\n[\n  {\n    \"template_id\": \"1\"\n  }\n]\n```",
+			},
+			expectedAction: multistep.ActionContinue,
+		},
 		{
 			name: "all options with cloud-init",
 			builderConfig: &Config{
@@ -214,6 +273,9 @@ func TestTemplateFinalize(t *testing.T) {
 			state.Put("config", c.builderConfig)
 			state.Put("vmRef", proxmox.NewVmRef(1))
 			state.Put("proxmoxClient", finalizer)
+			if c.lineage != nil {
+				state.Put("lineage", c.lineage)
+			}
 
 			step := stepFinalizeTemplateConfig{}
 			action := step.Run(context.TODO(), state)
@@ -0,0 +1,119 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+// Keys of the generated data of all builders, usable as build.<key> in
+// provisioners and post-processors. The builders add their own keys to the
+// ones returned by Config.Prepare.
+var generatedDataKeys = []string{
+	"Lineage",
+	"ProxmoxNode",
+	"VMID",
+	"VMName",
+	"TemplateName",
+	"IPAddresses",
+	"MACAddresses",
+}
+
+type generatedDataReader interface {
+	GetVmConfig(*proxmox.VmRef) (map[string]interface{}, error)
+	agentNetworkReader
+}
+
+var _ generatedDataReader = &proxmox.Client{}
+
+var netDeviceRe = regexp.MustCompile(`^net(\d+)$`)
+
+// stepGeneratedData publishes the details of the build VM as generated
+// data once the communicator is connected, before provisioning. The IP
+// addresses are the ones the guest agent reports, without loopback and
+// link-local addresses. The MAC addresses are the ones of the network
+// adapters in the order of their index. Both are lists separated by commas,
+// empty if they can't be read.
+type stepGeneratedData struct{}
+
+func (s *stepGeneratedData) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	client := state.Get("proxmoxClient").(generatedDataReader)
+	c := state.Get("config").(*Config)
+	vmRef := state.Get("vmRef").(*proxmox.VmRef)
+
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("ProxmoxNode", c.Node)
+	generatedData.Put("VMID", strconv.Itoa(vmRef.VmId()))
+	generatedData.Put("VMName", c.VMName)
+	templateName := c.VMName
+	if c.TemplateName != "" {
+		templateName = c.TemplateName
+	}
+	generatedData.Put("TemplateName", templateName)
+
+	var macs []string
+	vmConfig, err := client.GetVmConfig(vmRef)
+	if err != nil {
+		log.Printf("Error reading the network adapters of the VM: %s", err)
+	} else {
+		macs = vmMACAddresses(vmConfig)
+	}
+	generatedData.Put("MACAddresses", strings.Join(macs, ","))
+
+	var ips []string
+	if c.Agent.True() {
+		ifs, err := client.GetVmAgentNetworkInterfaces(vmRef)
+		if err != nil {
+			log.Printf("Error reading the IP addresses of the VM: %s", err)
+		}
+		for _, iface := range ifs {
+			for _, addr := range iface.IPAddresses {
+				if !addr.IsLoopback() && !addr.IsLinkLocalUnicast() {
+					ips = append(ips, addr.String())
+				}
+			}
+		}
+	}
+	generatedData.Put("IPAddresses", strings.Join(ips, ","))
+
+	return multistep.ActionContinue
+}
+
+func (s *stepGeneratedData) Cleanup(state multistep.StateBag) {}
+
+// vmMACAddresses returns the MAC addresses of the network adapters in a VM
+// config, like virtio=BC:24:11:2A:86:33,bridge=vmbr0, ordered by index.
+func vmMACAddresses(vmConfig map[string]interface{}) []string {
+	indexes := []int{}
+	macs := map[int]string{}
+	for key, value := range vmConfig {
+		match := netDeviceRe.FindStringSubmatch(key)
+		device, ok := value.(string)
+		if match == nil || !ok {
+			continue
+		}
+		index, _ := strconv.Atoi(match[1])
+		model, _, _ := strings.Cut(device, ",")
+		if _, mac, ok := strings.Cut(model, "="); ok {
+			indexes = append(indexes, index)
+			macs[index] = mac
+		}
+	}
+	sort.Ints(indexes)
+
+	result := make([]string, len(indexes))
+	for idx, index := range indexes {
+		result[idx] = macs[index]
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type generatedDataReaderMock struct {
+	vmConfig   map[string]interface{}
+	interfaces []proxmox.AgentNetworkInterface
+	agentFail  bool
+}
+
+func (m *generatedDataReaderMock) GetVmConfig(*proxmox.VmRef) (map[string]interface{}, error) {
+	return m.vmConfig, nil
+}
+
+func (m *generatedDataReaderMock) GetVmAgentNetworkInterfaces(*proxmox.VmRef) ([]proxmox.AgentNetworkInterface, error) {
+	if m.agentFail {
+		return nil, fmt.Errorf("QEMU guest agent is not running")
+	}
+	return m.interfaces, nil
+}
+
+var _ generatedDataReader = &generatedDataReaderMock{}
+
+func TestGeneratedData(t *testing.T) {
+	interfaces := []proxmox.AgentNetworkInterface{
+		{Name: "lo", IPAddresses: []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")}},
+		{Name: "eth0", IPAddresses: []net.IP{net.ParseIP("192.0.2.10"), net.ParseIP("fe80::1")}},
+		{Name: "eth1", IPAddresses: []net.IP{net.ParseIP("2001:db8::10")}},
+	}
+	vmConfig := map[string]interface{}{
+		"net10": "e1000=BC:24:11:00:00:03,bridge=vmbr2",
+		"net0":  "virtio=BC:24:11:00:00:01,bridge=vmbr0",
+		"net2":  "virtio=BC:24:11:00:00:02,bridge=vmbr1,firewall=1",
+		"scsi0": "local-lvm:vm-100-disk-0,size=10G",
+	}
+
+	cs := []struct {
+		name        string
+		config      *Config
+		mock        *generatedDataReaderMock
+		expectedIPs string
+	}{
+		{
+			name:        "addresses from the guest agent",
+			config:      &Config{Node: "pve", VMName: "build", TemplateName: "debian-12", Agent: config.TriTrue},
+			mock:        &generatedDataReaderMock{vmConfig: vmConfig, interfaces: interfaces},
+			expectedIPs: "192.0.2.10,2001:db8::10",
+		},
+		{
+			name:   "no guest agent, no IP addresses",
+			config: &Config{Node: "pve", VMName: "build", TemplateName: "debian-12", Agent: config.TriFalse},
+			mock:   &generatedDataReaderMock{vmConfig: vmConfig, interfaces: interfaces},
+		},
+		{
+			name:   "failing guest agent, no IP addresses",
+			config: &Config{Node: "pve", VMName: "build", TemplateName: "debian-12", Agent: config.TriTrue},
+			mock:   &generatedDataReaderMock{vmConfig: vmConfig, agentFail: true},
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			vmRef := proxmox.NewVmRef(100)
+			vmRef.SetNode("pve")
+
+			state := new(multistep.BasicStateBag)
+			state.Put("config", c.config)
+			state.Put("proxmoxClient", c.mock)
+			state.Put("vmRef", vmRef)
+
+			step := &stepGeneratedData{}
+			action := step.Run(context.TODO(), state)
+			assert.Equal(t, multistep.ActionContinue, action)
+
+			assert.Equal(t, map[string]interface{}{
+				"ProxmoxNode":  "pve",
+				"VMID":         "100",
+				"VMName":       "build",
+				"TemplateName": "debian-12",
+				"IPAddresses":  c.expectedIPs,
+				"MACAddresses": "BC:24:11:00:00:01,BC:24:11:00:00:02,BC:24:11:00:00:03",
+			}, state.Get("generated_data"))
+		})
+	}
+}
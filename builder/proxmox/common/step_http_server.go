@@ -0,0 +1,220 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	"github.com/hashicorp/packer-plugin-sdk/net"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// httpTemplateData is passed to the http_content and http_templates
+// templates.
+type httpTemplateData struct {
+	HTTPIP       string
+	HTTPPort     int
+	VMID         int
+	VMName       string
+	Node         string
+	SSHPublicKey string
+	buildNetworkTemplateData
+}
+
+// stepHTTPServer serves http_directory, or http_content and http_templates,
+// like commonsteps.StepHTTPServer, unless boot_files puts them on an ISO.
+// The templates are rendered once the port is known, so they can refer to
+// the VM and the HTTP server. The rendered content is only kept in memory,
+// so secrets in the templates are never written to disk.
+type stepHTTPServer struct {
+	l      *net.Listener
+	tunnel *httpTunnel
+}
+
+func (s *stepHTTPServer) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+
+	// With boot_files, the files are on an ISO instead
+	if c.bootFilesEnabled() || (c.HTTPDir == "" && len(c.HTTPContent) == 0 && len(c.HTTPTemplates) == 0) {
+		state.Put("http_port", 0)
+		return multistep.ActionContinue
+	}
+
+	if c.HTTPDir != "" {
+		if _, err := os.Stat(c.HTTPDir); err != nil {
+			err := fmt.Errorf("Error finding %q: %s", c.HTTPDir, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	var err error
+	s.l, err = net.ListenRangeConfig{
+		Min:     c.HTTPPortMin,
+		Max:     c.HTTPPortMax,
+		Addr:    c.HTTPAddress,
+		Network: "tcp",
+	}.Listen(ctx)
+	if err != nil {
+		err := fmt.Errorf("Error finding port: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	httpPort := s.l.Port
+	var httpIP string
+	if c.HTTPTunnel {
+		httpIP, err = s.openTunnel(c, state)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		httpPort = s.tunnel.Port()
+		// The boot command uses the address of the tunnel as well
+		state.Put("http_ip", httpIP)
+	} else {
+		httpIP, err = httpServerIP(c)
+		if err != nil {
+			// The boot command fails on this if it needs the address
+			log.Printf("Failed to determine host IP: %s", err)
+		}
+	}
+
+	var handler http.Handler
+	if c.HTTPDir != "" {
+		handler = http.FileServer(http.Dir(c.HTTPDir))
+	} else {
+		vmRef := state.Get("vmRef").(*proxmox.VmRef)
+		content, err := renderHTTPContent(c, c.httpTemplateData(vmRef.VmId(), httpIP, httpPort))
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		handler = commonsteps.MapServer(content)
+	}
+
+	ui.Say(fmt.Sprintf("Starting HTTP server on port %d", s.l.Port))
+	server := &http.Server{Addr: "", Handler: handler}
+	go server.Serve(s.l)
+
+	state.Put("http_port", httpPort)
+	return multistep.ActionContinue
+}
+
+// openTunnel forwards a port on the address of the Proxmox node on
+// http_tunnel_bridge to the HTTP server, and returns the address.
+func (s *stepHTTPServer) openTunnel(c *Config, state multistep.StateBag) (string, error) {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(nodeNetworkReader)
+
+	remoteIP, err := bridgeAddress(client, c.Node, c.HTTPTunnelBridge)
+	if err != nil {
+		return "", err
+	}
+	localIP := c.HTTPAddress
+	if localIP == "0.0.0.0" {
+		localIP = "127.0.0.1"
+	}
+	s.tunnel, err = openHTTPTunnel(c.resolveNodeSSH(client), remoteIP, localIP, s.l.Port)
+	if err != nil {
+		return "", err
+	}
+	ui.Say(fmt.Sprintf("Forwarding port %d of the Proxmox node on %s to the HTTP server", s.tunnel.Port(), remoteIP))
+	return remoteIP, nil
+}
+
+func (s *stepHTTPServer) Cleanup(state multistep.StateBag) {
+	if s.tunnel != nil {
+		if err := s.tunnel.Close(); err != nil {
+			log.Printf("Failed closing the tunnel to the HTTP server: %s", err)
+		}
+	}
+	if s.l == nil {
+		return
+	}
+	ui := state.Get("ui").(packersdk.Ui)
+	if err := s.l.Close(); err != nil {
+		ui.Error(fmt.Sprintf("Failed closing http server on port %d: %s", s.l.Port, err))
+	}
+}
+
+// httpTemplateData returns the data of the http_content and http_templates
+// templates.
+func (c *Config) httpTemplateData(vmID int, httpIP string, httpPort int) *httpTemplateData {
+	return &httpTemplateData{
+		HTTPIP:       httpIP,
+		HTTPPort:     httpPort,
+		VMID:         vmID,
+		VMName:       c.VMName,
+		Node:         c.Node,
+		SSHPublicKey: strings.TrimSpace(string(c.Comm.SSHPublicKey)),
+
+		buildNetworkTemplateData: c.buildNetworkData(),
+	}
+}
+
+// renderHTTPContent renders http_content and the files of http_templates.
+func renderHTTPContent(c *Config, data *httpTemplateData) (map[string]string, error) {
+	ctx := c.Ctx
+	ctx.Data = data
+
+	content := map[string]string{}
+	for path, template := range c.HTTPContent {
+		rendered, err := interpolate.Render(template, &ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Error rendering http_content %s: %s", path, err)
+		}
+		content[path] = rendered
+	}
+	for path, file := range c.HTTPTemplates {
+		template, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading http_templates %s: %s", path, err)
+		}
+		rendered, err := interpolate.Render(string(template), &ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Error rendering http_templates %s: %s", path, err)
+		}
+		content[path] = rendered
+	}
+	return content, nil
+}
+
+// prepareHTTPTemplates validates http_templates. The files are read again
+// when the HTTP server starts, and only rendered then.
+func (c *Config) prepareHTTPTemplates() []error {
+	var errs []error
+	if len(c.HTTPTemplates) > 0 && c.HTTPDir != "" {
+		errs = append(errs, errors.New("http_templates can't be used with http_directory, use http_content for the other files"))
+	}
+	for path, file := range c.HTTPTemplates {
+		if _, ok := c.HTTPContent[path]; ok {
+			errs = append(errs, fmt.Errorf("http_templates %s is set in http_content as well", path))
+		}
+		template, err := os.ReadFile(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("http_templates %s: %s", path, err))
+			continue
+		}
+		if err := interpolate.Validate(string(template), &c.Ctx); err != nil {
+			errs = append(errs, fmt.Errorf("http_templates %s: %s", path, err))
+		}
+	}
+	return errs
+}
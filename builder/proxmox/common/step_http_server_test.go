@@ -0,0 +1,155 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestHTTPServerTemplates(t *testing.T) {
+	templateFile := filepath.Join(t.TempDir(), "ks.cfg.pkrtpl")
+	err := os.WriteFile(templateFile, []byte("rootpw --iscrypted {{ user `root_password_hash` }}\nsshkey --username=root \"{{ .SSHPublicKey }}\"\nnetwork --hostname={{ .VMName }}-{{ .VMID }}\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := mandatoryConfig(t)
+	cfg["http_bind_address"] = "127.0.0.1"
+	cfg["build_network"] = map[string]interface{}{
+		"ip":  "192.0.2.50/24",
+		"dns": []string{"192.0.2.53", "192.0.2.54"},
+	}
+	cfg["http_content"] = map[string]string{
+		"/network.cfg": "network --ip={{ .IP }} --netmask={{ .Netmask }} --nameserver={{ .DNS }} --node={{ .Node }}",
+		"/post.sh":     "curl http://{{ .HTTPIP }}:{{ .HTTPPort }}/done",
+	}
+	cfg["http_templates"] = map[string]string{
+		"/ks.cfg": templateFile,
+	}
+	cfg["vm_name"] = "rocky"
+	cfg["packer_user_variables"] = map[string]string{
+		"root_password_hash": "$6$salt$hash",
+	}
+
+	var c Config
+	_, _, err = c.Prepare(&c, cfg)
+	if err != nil {
+		t.Fatalf("unexpected failure to prepare config: %s", err)
+	}
+	c.Comm.SSHPublicKey = []byte("ssh-ed25519 AAAAC3Nza packer\n")
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("config", &c)
+	state.Put("vmRef", proxmox.NewVmRef(110))
+
+	step := &stepHTTPServer{}
+	action := step.Run(context.TODO(), state)
+	defer step.Cleanup(state)
+	if action != multistep.ActionContinue {
+		t.Fatalf("expected action continue, got %v: %v", action, state.Get("error"))
+	}
+	port := state.Get("http_port").(int)
+
+	expected := map[string]string{
+		"/network.cfg": "network --ip=192.0.2.50 --netmask=255.255.255.0 --nameserver=192.0.2.53,192.0.2.54 --node=my-proxmox",
+		"/post.sh":     fmt.Sprintf("curl http://127.0.0.1:%d/done", port),
+		"/ks.cfg":      "rootpw --iscrypted $6$salt$hash\nsshkey --username=root \"ssh-ed25519 AAAAC3Nza packer\"\nnetwork --hostname=rocky-110\n",
+	}
+	for path, content := range expected {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != content {
+			t.Errorf("expected %s to be %q, got %q", path, content, string(body))
+		}
+	}
+}
+
+func TestHTTPTemplatesConfig(t *testing.T) {
+	dir := t.TempDir()
+	templateFile := filepath.Join(dir, "ks.cfg")
+	if err := os.WriteFile(templateFile, []byte("network --ip={{ .IP }}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	invalidFile := filepath.Join(dir, "invalid.cfg")
+	if err := os.WriteFile(invalidFile, []byte("network --ip={{ .IP "), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	httpTemplatesTest := []struct {
+		name          string
+		config        map[string]interface{}
+		expectFailure bool
+	}{
+		{
+			name:   "template file, no error",
+			config: map[string]interface{}{"http_templates": map[string]string{"/ks.cfg": templateFile}},
+		},
+		{
+			name: "templates and content, no error",
+			config: map[string]interface{}{
+				"http_templates": map[string]string{"/ks.cfg": templateFile},
+				"http_content":   map[string]string{"/post.sh": "exit 0"},
+			},
+		},
+		{
+			name:          "missing template file, fail",
+			config:        map[string]interface{}{"http_templates": map[string]string{"/ks.cfg": filepath.Join(dir, "missing.cfg")}},
+			expectFailure: true,
+		},
+		{
+			name:          "invalid template, fail",
+			config:        map[string]interface{}{"http_templates": map[string]string{"/ks.cfg": invalidFile}},
+			expectFailure: true,
+		},
+		{
+			name: "same path in content, fail",
+			config: map[string]interface{}{
+				"http_templates": map[string]string{"/ks.cfg": templateFile},
+				"http_content":   map[string]string{"/ks.cfg": "exit 0"},
+			},
+			expectFailure: true,
+		},
+		{
+			name: "templates with http_directory, fail",
+			config: map[string]interface{}{
+				"http_templates": map[string]string{"/ks.cfg": templateFile},
+				"http_directory": dir,
+			},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range httpTemplatesTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for key, value := range tt.config {
+				cfg[key] = value
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+		})
+	}
+}
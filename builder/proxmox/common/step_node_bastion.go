@@ -0,0 +1,38 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepNodeBastion makes the Proxmox node the SSH bastion host of the
+// communicator, for VMs on bridges only the node can reach. The bastion
+// settings are read by the communicator when it connects, after this step.
+type stepNodeBastion struct{}
+
+func (s *stepNodeBastion) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	c := state.Get("config").(*Config)
+
+	if !c.SSHBastionNode {
+		return multistep.ActionContinue
+	}
+
+	client := state.Get("proxmoxClient").(nodeNetworkReader)
+	ns := c.resolveNodeSSH(client)
+	c.Comm.SSHBastionHost = ns.Host
+	c.Comm.SSHBastionPort = ns.Port
+	c.Comm.SSHBastionUsername = ns.Username
+	c.Comm.SSHBastionPassword = ns.Password
+	c.Comm.SSHBastionPrivateKeyFile = ns.PrivateKeyFile
+	ui.Say(fmt.Sprintf("Using the Proxmox node %s as SSH bastion host", ns.Host))
+	return multistep.ActionContinue
+}
+
+func (s *stepNodeBastion) Cleanup(state multistep.StateBag) {}
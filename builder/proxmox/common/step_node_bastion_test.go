@@ -0,0 +1,52 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestNodeBastion(t *testing.T) {
+	cfg := mandatoryConfig(t)
+	cfg["ssh_bastion_node"] = true
+	cfg["node_ssh"] = map[string]interface{}{"username": "packer", "port": 2222, "password": "secret"}
+
+	var c Config
+	_, _, err := c.Prepare(&c, cfg)
+	if err != nil {
+		t.Fatalf("unexpected failure to prepare config: %s", err)
+	}
+
+	client := nodeNetworkReaderMock{
+		getItemList: func(url string) (map[string]interface{}, error) {
+			return map[string]interface{}{"data": []interface{}{
+				map[string]interface{}{"type": "node", "name": "my-proxmox", "ip": "192.0.2.3"},
+			}}, nil
+		},
+	}
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("config", &c)
+	state.Put("proxmoxClient", client)
+
+	step := &stepNodeBastion{}
+	action := step.Run(context.TODO(), state)
+	if action != multistep.ActionContinue {
+		t.Fatalf("expected action continue, got %v", action)
+	}
+
+	if c.Comm.SSHBastionHost != "192.0.2.3" {
+		t.Errorf("expected bastion host 192.0.2.3, got %q", c.Comm.SSHBastionHost)
+	}
+	if c.Comm.SSHBastionPort != 2222 {
+		t.Errorf("expected bastion port 2222, got %d", c.Comm.SSHBastionPort)
+	}
+	if c.Comm.SSHBastionUsername != "packer" || c.Comm.SSHBastionPassword != "secret" {
+		t.Errorf("expected bastion credentials of node_ssh, got %q and %q", c.Comm.SSHBastionUsername, c.Comm.SSHBastionPassword)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// stepRecordFailure wraps a step and puts its name into the "failed_step"
+// state key when it halts the build, so the VM kept with keep_vm_on_failure
+// can tell where the build failed.
+type stepRecordFailure struct {
+	multistep.Step
+}
+
+func (s *stepRecordFailure) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	action := s.Step.Run(ctx, state)
+	if action == multistep.ActionHalt {
+		if _, ok := state.GetOk("failed_step"); !ok {
+			state.Put("failed_step", s.InnerStepName())
+		}
+	}
+	return action
+}
+
+// InnerStepName is used by the debug runner to name the wrapped step.
+func (s *stepRecordFailure) InnerStepName() string {
+	return reflect.Indirect(reflect.ValueOf(s.Step)).Type().Name()
+}
+
+// cleansUpOnError reports whether the steps are cleaned up as usual when the
+// build fails. The other -on-error modes wrap the steps themselves and
+// identify them by their type, so they can't be wrapped again.
+func (c *Config) cleansUpOnError() bool {
+	return c.PackerOnError == "" || c.PackerOnError == "cleanup"
+}
@@ -9,6 +9,7 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Telmate/proxmox-api-go/proxmox"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -36,6 +37,15 @@ type vmStarter interface {
 	StartVm(*proxmox.VmRef) (string, error)
 }
 
+type vmResumer interface {
+	CheckVmRef(vmr *proxmox.VmRef) (err error)
+	GetVmConfig(vmr *proxmox.VmRef) (vmConfig map[string]interface{}, err error)
+	ListQemuSnapshot(vmr *proxmox.VmRef) (map[string]interface{}, string, error)
+	RollbackQemuVm(vmr *proxmox.VmRef, snapshot string) (string, error)
+}
+
+var _ vmResumer = &proxmox.Client{}
+
 var (
 	maxDuplicateIDRetries = 3
 )
@@ -99,47 +109,19 @@ func (s *stepStartVM) Run(ctx context.Context, state multistep.StateBag) multist
 	client := state.Get("proxmoxClient").(vmStarter)
 	c := state.Get("config").(*Config)
 
-	agent := 1
-	if c.Agent.False() {
-		agent = 0
-	}
-
-	kvm := true
-	if c.DisableKVM {
-		kvm = false
-	}
-
-	config := proxmox.ConfigQemu{
-		Name:           c.VMName,
-		Agent:          agent,
-		QemuKVM:        &kvm,
-		Boot:           c.Boot, // Boot priority, example: "order=virtio0;ide2;net0", virtio0:Disk0 -> ide0:CDROM -> net0:Network
-		QemuCpu:        c.CPUType,
-		Description:    "Packer ephemeral build VM",
-		Memory:         c.Memory,
-		QemuCores:      c.Cores,
-		QemuSockets:    c.Sockets,
-		QemuNuma:       &c.Numa,
-		QemuOs:         c.OS,
-		Bios:           c.BIOS,
-		EFIDisk:        generateProxmoxEfi(c.EFIConfig),
-		Machine:        c.Machine,
-		RNGDrive:       generateProxmoxRng0(c.Rng0),
-		QemuVga:        generateProxmoxVga(c.VGA),
-		QemuNetworks:   generateProxmoxNetworkAdapters(c.NICs),
-		QemuDisks:      generateProxmoxDisks(c.Disks),
-		QemuPCIDevices: generateProxmoxPCIDeviceMap(c.PCIDevices),
-		QemuSerials:    generateProxmoxSerials(c.Serials),
-		Scsihw:         c.SCSIController,
-		Onboot:         &c.Onboot,
+	if c.ResumeFromSnapshot != "" {
+		ui.Say(fmt.Sprintf("Resuming VM %d from snapshot %s", c.VMID, c.ResumeFromSnapshot))
+		vmRef, err := resumeVM(c, state.Get("proxmoxClient").(vmResumer), state)
+		if err != nil {
+			err := fmt.Errorf("Error resuming VM: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		return startVM(vmRef, client, state)
 	}
 
-	// 0 disables the ballooning device, which is useful for all VMs
-	// and should be kept enabled by default.
-	// See https://github.com/hashicorp/packer-plugin-proxmox/issues/127#issuecomment-1464030102
-	if c.BalloonMinimum > 0 {
-		config.Balloon = c.BalloonMinimum
-	}
+	config := generateConfigQemu(c)
 
 	if c.PackerForce {
 		ui.Say("Force set, checking for existing artifact on PVE cluster")
@@ -165,6 +147,7 @@ func (s *stepStartVM) Run(ctx context.Context, state multistep.StateBag) multist
 
 	ui.Say("Creating VM")
 	var vmRef *proxmox.VmRef
+	var nics []NICConfig
 	for i := 1; ; i++ {
 		id := c.VMID
 		if id == 0 {
@@ -178,6 +161,10 @@ func (s *stepStartVM) Run(ctx context.Context, state multistep.StateBag) multist
 			id = genID
 			config.VmID = genID
 		}
+		// Deterministic MAC addresses depend on the VM ID
+		nics = resolveMACAddresses(c, id)
+		config.QemuNetworks = generateProxmoxNetworkAdapters(nics)
+
 		vmRef = proxmox.NewVmRef(id)
 		vmRef.SetNode(c.Node)
 		if c.Pool != "" {
@@ -202,15 +189,14 @@ func (s *stepStartVM) Run(ctx context.Context, state multistep.StateBag) multist
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
+	// The VM exists from here on, so it's cleaned up if updating its config
+	// fails
+	state.Put("vmRef", vmRef)
 
 	// proxmox-api-go assumes all QemuDisks are actually hard disks, not cd
 	// drives, so we need to add them via a config update
 	if len(c.AdditionalISOFiles) > 0 {
-		addISOConfig := make(map[string]interface{})
-		for _, iso := range c.AdditionalISOFiles {
-			addISOConfig[iso.Device] = fmt.Sprintf("%s,media=cdrom", iso.ISOFile)
-		}
-		_, err := client.SetVmConfig(vmRef, addISOConfig)
+		_, err := client.SetVmConfig(vmRef, generateProxmoxAdditionalISOs(c.AdditionalISOFiles))
 		if err != nil {
 			err := fmt.Errorf("Error updating template: %s", err)
 			state.Put("error", err)
@@ -219,6 +205,20 @@ func (s *stepStartVM) Run(ctx context.Context, state multistep.StateBag) multist
 		}
 	}
 
+	// proxmox-api-go has no support for TPM state devices, so the device is
+	// added via a config update before the VM is started for the first time
+	if c.TPMConfig.TPMStoragePool != "" {
+		_, err := client.SetVmConfig(vmRef, map[string]interface{}{
+			"tpmstate0": generateProxmoxTpm(c.TPMConfig),
+		})
+		if err != nil {
+			err := fmt.Errorf("Error adding TPM state device: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	// The EFI disk doesn't get created reliably when using the clone builder,
 	// so let's make sure it's there.
 	if c.EFIConfig != (efiConfig{}) && c.Ctx.BuildType == "proxmox-clone" {
@@ -233,6 +233,56 @@ func (s *stepStartVM) Run(ctx context.Context, state multistep.StateBag) multist
 		}
 	}
 
+	// Options proxmox-api-go doesn't know about are passed through as they
+	// are, after all devices have been added
+	if len(c.ExtraConfig) > 0 {
+		_, err := client.SetVmConfig(vmRef, extraConfigParams(c.ExtraConfig))
+		if err != nil {
+			err := fmt.Errorf("Error applying extra_config: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	// The hookscript has to be attached before the VM is started, so it runs
+	// for the pre-start phase
+	hookscript := c.Hookscript.Volume
+	if volume, ok := state.GetOk("hookscript_volume"); ok {
+		hookscript = volume.(string)
+	}
+	if hookscript != "" {
+		_, err := client.SetVmConfig(vmRef, map[string]interface{}{
+			"hookscript": hookscript,
+		})
+		if err != nil {
+			err := fmt.Errorf("Error attaching hookscript: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	// The DHCP server has to know the MAC addresses before the VM boots.
+	// Resumed VMs keep theirs, the builds they are resumed from were
+	// aborted without deregistering them.
+	if len(c.MACAddressHook) > 0 {
+		err := registerMACAddresses(c, nics, state)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return startVM(vmRef, client, state)
+}
+
+// startVM starts the build VM and stores the reference to it for the later
+// steps.
+func startVM(vmRef *proxmox.VmRef, client vmStarter, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
 	// Store the vm id for later
 	state.Put("vmRef", vmRef)
 	// instance_id is the generic term used so that users can have access to the
@@ -253,12 +303,112 @@ func (s *stepStartVM) Run(ctx context.Context, state multistep.StateBag) multist
 	return multistep.ActionContinue
 }
 
+// resumeVM rolls the VM of an aborted build back to the snapshot set with
+// resume_from_snapshot, instead of creating a new VM. The checkpoints the VM
+// still has are put into the "checkpoints" state key.
+func resumeVM(c *Config, client vmResumer, state multistep.StateBag) (*proxmox.VmRef, error) {
+	vmRef := proxmox.NewVmRef(c.VMID)
+	err := client.CheckVmRef(vmRef)
+	if err != nil {
+		return nil, err
+	}
+	if vmRef.Node() != c.Node {
+		return nil, fmt.Errorf("VM %d is on node %s, not on %s", c.VMID, vmRef.Node(), c.Node)
+	}
+	vmConfig, err := client.GetVmConfig(vmRef)
+	if err != nil {
+		return nil, err
+	}
+	if vmConfig["template"] != nil {
+		return nil, fmt.Errorf("VM %d is a template, not the VM of an aborted build", c.VMID)
+	}
+
+	snapshots, _, err := client.ListQemuSnapshot(vmRef)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	var checkpoints []string
+	list, _ := snapshots["data"].([]interface{})
+	for _, item := range list {
+		snapshot, _ := item.(map[string]interface{})
+		name, _ := snapshot["name"].(string)
+		if name == c.ResumeFromSnapshot {
+			found = true
+		}
+		if containsString(checkpointNames, name) {
+			checkpoints = append(checkpoints, name)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("VM %d has no snapshot %s", c.VMID, c.ResumeFromSnapshot)
+	}
+
+	_, err = client.RollbackQemuVm(vmRef, c.ResumeFromSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	state.Put("checkpoints", checkpoints)
+	state.Put("resumed_from_snapshot", c.ResumeFromSnapshot)
+	return vmRef, nil
+}
+
+// generateConfigQemu returns the config of the VM to create for the build,
+// before any builder specific changes are made by the ProxmoxVMCreator.
+func generateConfigQemu(c *Config) proxmox.ConfigQemu {
+	agent := 1
+	if c.Agent.False() {
+		agent = 0
+	}
+
+	kvm := true
+	if c.DisableKVM {
+		kvm = false
+	}
+
+	config := proxmox.ConfigQemu{
+		Name:           c.VMName,
+		Agent:          agent,
+		QemuKVM:        &kvm,
+		Boot:           c.Boot, // Boot priority, example: "order=virtio0;ide2;net0", virtio0:Disk0 -> ide0:CDROM -> net0:Network
+		QemuCpu:        c.CPUType,
+		Description:    "Packer ephemeral build VM",
+		Memory:         c.Memory,
+		QemuCores:      c.Cores,
+		QemuSockets:    c.Sockets,
+		QemuNuma:       &c.Numa,
+		QemuOs:         c.OS,
+		Bios:           c.BIOS,
+		EFIDisk:        generateProxmoxEfi(c.EFIConfig),
+		Machine:        c.Machine,
+		RNGDrive:       generateProxmoxRng0(c.Rng0),
+		QemuVga:        generateProxmoxVga(c.VGA),
+		QemuNetworks:   generateProxmoxNetworkAdapters(c.NICs),
+		QemuDisks:      generateProxmoxDisks(c.Disks),
+		QemuPCIDevices: generateProxmoxPCIDeviceMap(c.PCIDevices),
+		QemuSerials:    generateProxmoxSerials(c.Serials),
+		Scsihw:         c.SCSIController,
+		Onboot:         &c.Onboot,
+	}
+
+	// 0 disables the ballooning device, which is useful for all VMs
+	// and should be kept enabled by default.
+	// See https://github.com/hashicorp/packer-plugin-proxmox/issues/127#issuecomment-1464030102
+	if c.BalloonMinimum > 0 {
+		config.Balloon = c.BalloonMinimum
+	}
+	return config
+}
+
 func generateProxmoxNetworkAdapters(nics []NICConfig) proxmox.QemuDevices {
 	devs := make(proxmox.QemuDevices)
 	for idx := range nics {
 		devs[idx] = make(proxmox.QemuDevice)
 		setDeviceParamIfDefined(devs[idx], "model", nics[idx].Model)
-		setDeviceParamIfDefined(devs[idx], "macaddr", nics[idx].MACAddress)
+		// Resolved by resolveMACAddresses once the VM ID is known
+		if nics[idx].MACAddress != macAddressAutoDeterministic {
+			setDeviceParamIfDefined(devs[idx], "macaddr", nics[idx].MACAddress)
+		}
 		setDeviceParamIfDefined(devs[idx], "bridge", nics[idx].Bridge)
 		setDeviceParamIfDefined(devs[idx], "tag", nics[idx].VLANTag)
 		setDeviceParamIfDefined(devs[idx], "firewall", strconv.FormatBool(nics[idx].Firewall))
@@ -365,6 +515,20 @@ func generateProxmoxEfi(efi efiConfig) proxmox.QemuDevice {
 	return dev
 }
 
+func generateProxmoxAdditionalISOs(isos []additionalISOsConfig) map[string]interface{} {
+	addISOConfig := make(map[string]interface{})
+	for _, iso := range isos {
+		addISOConfig[iso.Device] = fmt.Sprintf("%s,media=cdrom", iso.ISOFile)
+	}
+	return addISOConfig
+}
+
+func generateProxmoxTpm(tpm tpmConfig) string {
+	// size 1 lets Proxmox allocate the volume with the size required by the
+	// TPM state, the same way it does for EFI disks
+	return fmt.Sprintf("%s:1,version=%s", tpm.TPMStoragePool, tpm.Version)
+}
+
 func setDeviceParamIfDefined(dev proxmox.QemuDevice, key, value string) {
 	if value != "" {
 		dev[key] = value
@@ -389,10 +553,21 @@ func (s *stepStartVM) Cleanup(state multistep.StateBag) {
 		return
 	}
 	vmRef := vmRefUntyped.(*proxmox.VmRef)
+	c := state.Get("config").(*Config)
+	_, success := state.GetOk("success")
+
+	if !success && c.KeepVMOnFailure && c.cleansUpOnError() {
+		keepFailedVM(c, vmRef, state)
+		return
+	}
+
+	// Clones of the template get MAC addresses of their own, so the
+	// addresses are deregistered after successful builds too
+	deregisterMACAddresses(c, state)
 
 	// The vmRef will actually refer to the created template if everything
 	// finished successfully, so in that case we shouldn't cleanup
-	if _, ok := state.GetOk("success"); ok {
+	if success {
 		return
 	}
 
@@ -414,3 +589,75 @@ func (s *stepStartVM) Cleanup(state multistep.StateBag) {
 		return
 	}
 }
+
+type failedVMKeeper interface {
+	GetVmConfig(vmr *proxmox.VmRef) (vmConfig map[string]interface{}, err error)
+	SetVmConfig(*proxmox.VmRef, map[string]interface{}) (interface{}, error)
+}
+
+var _ failedVMKeeper = &proxmox.Client{}
+
+// keepFailedVM leaves the VM of a failed build running for debugging. It is
+// renamed, so the next build doesn't run into it, tagged and described, and
+// the details needed to connect to it are printed.
+func keepFailedVM(c *Config, vmRef *proxmox.VmRef, state multistep.StateBag) {
+	client := state.Get("proxmoxClient").(failedVMKeeper)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	now := time.Now().UTC()
+	name := fmt.Sprintf("%s-failed-%s", c.VMName, now.Format("20060102-150405"))
+
+	failedStep := "an unknown step"
+	if step, ok := state.GetOk("failed_step"); ok {
+		failedStep = step.(string)
+	}
+	description := fmt.Sprintf("Packer build of %s failed in %s at %s", c.VMName, failedStep, now.Format(time.RFC3339))
+	if err, ok := state.GetOk("error"); ok {
+		description += fmt.Sprintf(":\n\n%s", err)
+	}
+
+	tags := "packer-failed"
+	vmConfig, err := client.GetVmConfig(vmRef)
+	if err == nil {
+		if existing, ok := vmConfig["tags"].(string); ok && existing != "" {
+			tags = existing + ";" + tags
+		}
+	}
+
+	changes := map[string]interface{}{
+		"name":        name,
+		"description": description,
+		"tags":        tags,
+	}
+	// The uploaded hookscript and the generated ISOs, like the boot_files
+	// and Autounattend ISOs, are deleted in cleanup, the VM wouldn't start
+	// again if they were still attached
+	if _, ok := state.GetOk("hookscript_volume"); ok {
+		changes["delete"] = "hookscript"
+	}
+	for _, iso := range c.AdditionalISOFiles {
+		if iso.isGenerated() {
+			changes[iso.Device] = "none,media=cdrom"
+		}
+	}
+	ui.Say("Keeping the VM of the failed build")
+	_, err = client.SetVmConfig(vmRef, changes)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error renaming the VM of the failed build: %s", err))
+		name = c.VMName
+	}
+
+	ip := c.Comm.Host()
+	if ip == "" {
+		ip, err = getVMIP(state)
+		if err != nil {
+			ip = fmt.Sprintf("unknown (%s)", err)
+		}
+	}
+
+	ui.Message(fmt.Sprintf("VM ID: %d", vmRef.VmId()))
+	ui.Message(fmt.Sprintf("Node: %s", vmRef.Node()))
+	ui.Message(fmt.Sprintf("Name: %s", name))
+	ui.Message(fmt.Sprintf("IP: %s", ip))
+	ui.Message("Delete the VM when you are done debugging")
+}
@@ -10,14 +10,17 @@ import (
 
 	"github.com/Telmate/proxmox-api-go/proxmox"
 	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/stretchr/testify/assert"
 )
 
 type startedVMCleanerMock struct {
-	stopVm   func() (string, error)
-	deleteVm func() (string, error)
+	stopVm      func() (string, error)
+	deleteVm    func() (string, error)
+	setVmConfig func(map[string]interface{}) (interface{}, error)
 }
 
 func (m startedVMCleanerMock) StopVm(*proxmox.VmRef) (string, error) {
@@ -26,18 +29,27 @@ func (m startedVMCleanerMock) StopVm(*proxmox.VmRef) (string, error) {
 func (m startedVMCleanerMock) DeleteVm(*proxmox.VmRef) (string, error) {
 	return m.deleteVm()
 }
+func (m startedVMCleanerMock) GetVmConfig(*proxmox.VmRef) (map[string]interface{}, error) {
+	return map[string]interface{}{"name": "packer-build", "tags": "linux"}, nil
+}
+func (m startedVMCleanerMock) SetVmConfig(vmRef *proxmox.VmRef, config map[string]interface{}) (interface{}, error) {
+	return m.setVmConfig(config)
+}
 
 var _ startedVMCleaner = &startedVMCleanerMock{}
+var _ failedVMKeeper = &startedVMCleanerMock{}
 
 func TestCleanupStartVM(t *testing.T) {
 	cs := []struct {
-		name               string
-		setVmRef           bool
-		setSuccess         bool
-		stopVMErr          error
-		expectCallStopVM   bool
-		deleteVMErr        error
-		expectCallDeleteVM bool
+		name                  string
+		setVmRef              bool
+		setSuccess            bool
+		keepVMOnFailure       bool
+		stopVMErr             error
+		expectCallStopVM      bool
+		deleteVMErr           error
+		expectCallDeleteVM    bool
+		expectCallSetVmConfig bool
 	}{
 		{
 			name:             "when vmRef state is not set, nothing should happen",
@@ -65,11 +77,23 @@ func TestCleanupStartVM(t *testing.T) {
 			stopVMErr:          fmt.Errorf("some error"),
 			expectCallDeleteVM: false,
 		},
+		{
+			name:                  "when keep_vm_on_failure is set, vm should be renamed and kept",
+			setVmRef:              true,
+			keepVMOnFailure:       true,
+			expectCallSetVmConfig: true,
+		},
+		{
+			name:            "when keep_vm_on_failure is set, a successful build is not changed",
+			setVmRef:        true,
+			setSuccess:      true,
+			keepVMOnFailure: true,
+		},
 	}
 
 	for _, c := range cs {
 		t.Run(c.name, func(t *testing.T) {
-			var stopWasCalled, deleteWasCalled bool
+			var stopWasCalled, deleteWasCalled, setVmConfigWasCalled bool
 
 			cleaner := startedVMCleanerMock{
 				stopVm: func() (string, error) {
@@ -88,11 +112,41 @@ func TestCleanupStartVM(t *testing.T) {
 					deleteWasCalled = true
 					return "", c.deleteVMErr
 				},
+				setVmConfig: func(config map[string]interface{}) (interface{}, error) {
+					if !c.expectCallSetVmConfig {
+						t.Error("Did not expect SetVmConfig to be called")
+					}
+
+					setVmConfigWasCalled = true
+					assert.Regexp(t, `^packer-build-failed-\d{8}-\d{6}$`, config["name"])
+					assert.Equal(t, "linux;packer-failed", config["tags"])
+					assert.Contains(t, config["description"], "failed in stepProvision")
+					assert.Contains(t, config["description"], "Timeout waiting for SSH")
+					assert.Equal(t, "none,media=cdrom", config["ide3"], "generated ISO should be detached")
+					assert.NotContains(t, config, "ide2", "existing ISO should stay attached")
+					return nil, nil
+				},
 			}
 
 			state := new(multistep.BasicStateBag)
 			state.Put("ui", packersdk.TestUi(t))
+			state.Put("config", &Config{
+				VMName:          "packer-build",
+				KeepVMOnFailure: c.keepVMOnFailure,
+				Comm:            communicator.Config{Type: "ssh", SSH: communicator.SSH{SSHHost: "192.0.2.10"}},
+				AdditionalISOFiles: []additionalISOsConfig{
+					{Device: "ide2", ISOFile: "local:iso/virtio-win.iso"},
+					{
+						Device:          "ide3",
+						ISOFile:         "local:iso/packer-boot-files.iso",
+						DownloadPathKey: "downloaded_additional_iso_path_1",
+						CDConfig:        commonsteps.CDConfig{CDContent: map[string]string{"ks.cfg": ""}},
+					},
+				},
+			})
 			state.Put("proxmoxClient", cleaner)
+			state.Put("failed_step", "stepProvision")
+			state.Put("error", fmt.Errorf("Timeout waiting for SSH."))
 			if c.setVmRef {
 				state.Put("vmRef", proxmox.NewVmRef(1))
 			}
@@ -109,6 +163,9 @@ func TestCleanupStartVM(t *testing.T) {
 			if c.expectCallDeleteVM && !deleteWasCalled {
 				t.Error("Expected DeleteVm to be called, but it wasn't")
 			}
+			if c.expectCallSetVmConfig && !setVmConfigWasCalled {
+				t.Error("Expected SetVmConfig to be called, but it wasn't")
+			}
 		})
 	}
 }
@@ -122,6 +179,7 @@ type startVMMock struct {
 	checkVmRef  func(vmr *proxmox.VmRef) (err error)
 	getVmByName func(vmName string) (vmrs []*proxmox.VmRef, err error)
 	deleteVm    func(vmr *proxmox.VmRef) (exitStatus string, err error)
+	stopVm      func(vmr *proxmox.VmRef) (exitStatus string, err error)
 }
 
 func (m *startVMMock) Create(vmRef *proxmox.VmRef, config proxmox.ConfigQemu, state multistep.StateBag) error {
@@ -148,6 +206,9 @@ func (m *startVMMock) GetVmRefsByName(vmName string) (vmrs []*proxmox.VmRef, err
 func (m *startVMMock) DeleteVm(vmr *proxmox.VmRef) (exitStatus string, err error) {
 	return m.deleteVm(vmr)
 }
+func (m *startVMMock) StopVm(vmr *proxmox.VmRef) (exitStatus string, err error) {
+	return m.stopVm(vmr)
+}
 
 func TestStartVM(t *testing.T) {
 	// TODO: proxmox-api-go does a lot of manipulation on the input and does not
@@ -210,6 +271,185 @@ func TestStartVM(t *testing.T) {
 	}
 }
 
+func TestStartVMWithTPM(t *testing.T) {
+	c := &Config{
+		BIOS: "ovmf",
+		TPMConfig: tpmConfig{
+			TPMStoragePool: "local-lvm",
+			Version:        "v2.0",
+		},
+	}
+
+	var tpmState interface{}
+	mock := &startVMMock{
+		create: func(vmRef *proxmox.VmRef, config proxmox.ConfigQemu, state multistep.StateBag) error {
+			return nil
+		},
+		startVm: func(*proxmox.VmRef) (string, error) {
+			if tpmState == nil {
+				t.Error("VM started before the TPM state device was added")
+			}
+			return "", nil
+		},
+		setVmConfig: func(_ *proxmox.VmRef, config map[string]interface{}) (interface{}, error) {
+			if v, ok := config["tpmstate0"]; ok {
+				tpmState = v
+			}
+			return nil, nil
+		},
+		getNextID: func(id int) (int, error) {
+			return 1, nil
+		},
+	}
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("config", c)
+	state.Put("proxmoxClient", mock)
+	s := stepStartVM{vmCreator: mock}
+
+	action := s.Run(context.TODO(), state)
+	if action != multistep.ActionContinue {
+		t.Fatalf("Expected action continue, got %s", action)
+	}
+	if tpmState != "local-lvm:1,version=v2.0" {
+		t.Errorf("Expected tpmstate0 to be local-lvm:1,version=v2.0, got %v", tpmState)
+	}
+}
+
+func TestStartVMWithTPMFailureDeletesVM(t *testing.T) {
+	c := &Config{
+		BIOS: "ovmf",
+		TPMConfig: tpmConfig{
+			TPMStoragePool: "local-lvm",
+			Version:        "v2.0",
+		},
+	}
+
+	deleted := false
+	mock := &startVMMock{
+		create: func(vmRef *proxmox.VmRef, config proxmox.ConfigQemu, state multistep.StateBag) error {
+			return nil
+		},
+		setVmConfig: func(_ *proxmox.VmRef, config map[string]interface{}) (interface{}, error) {
+			return nil, fmt.Errorf("storage 'local-lvm' does not exist")
+		},
+		getNextID: func(id int) (int, error) {
+			return 100, nil
+		},
+		stopVm: func(*proxmox.VmRef) (string, error) {
+			return "", nil
+		},
+		deleteVm: func(*proxmox.VmRef) (string, error) {
+			deleted = true
+			return "", nil
+		},
+	}
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("config", c)
+	state.Put("proxmoxClient", mock)
+	s := stepStartVM{vmCreator: mock}
+
+	action := s.Run(context.TODO(), state)
+	if action != multistep.ActionHalt {
+		t.Fatalf("Expected action halt, got %s", action)
+	}
+	s.Cleanup(state)
+	if !deleted {
+		t.Error("Expected the VM to be deleted after adding the TPM state device failed")
+	}
+}
+
+func TestStartVMWithExtraConfig(t *testing.T) {
+	c := &Config{
+		ExtraConfig: map[string]string{
+			"hookscript": "local:snippets/hook.sh",
+			"watchdog":   "model=i6300esb,action=reset",
+		},
+	}
+
+	var extraConfig map[string]interface{}
+	mock := &startVMMock{
+		create: func(vmRef *proxmox.VmRef, config proxmox.ConfigQemu, state multistep.StateBag) error {
+			return nil
+		},
+		startVm: func(*proxmox.VmRef) (string, error) {
+			if extraConfig == nil {
+				t.Error("VM started before extra_config was applied")
+			}
+			return "", nil
+		},
+		setVmConfig: func(_ *proxmox.VmRef, config map[string]interface{}) (interface{}, error) {
+			extraConfig = config
+			return nil, nil
+		},
+		getNextID: func(id int) (int, error) {
+			return 1, nil
+		},
+	}
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("config", c)
+	state.Put("proxmoxClient", mock)
+	s := stepStartVM{vmCreator: mock}
+
+	action := s.Run(context.TODO(), state)
+	if action != multistep.ActionContinue {
+		t.Fatalf("Expected action continue, got %s", action)
+	}
+	expected := map[string]interface{}{
+		"hookscript": "local:snippets/hook.sh",
+		"watchdog":   "model=i6300esb,action=reset",
+	}
+	assert.Equal(t, expected, extraConfig)
+}
+
+func TestStartVMWithExtraConfigFailureDeletesVM(t *testing.T) {
+	c := &Config{
+		ExtraConfig: map[string]string{
+			"watchdog": "model=unknown",
+		},
+	}
+
+	deleted := false
+	mock := &startVMMock{
+		create: func(vmRef *proxmox.VmRef, config proxmox.ConfigQemu, state multistep.StateBag) error {
+			return nil
+		},
+		startVm: func(*proxmox.VmRef) (string, error) {
+			t.Error("VM started although extra_config failed")
+			return "", nil
+		},
+		setVmConfig: func(_ *proxmox.VmRef, config map[string]interface{}) (interface{}, error) {
+			return nil, fmt.Errorf("400 Parameter verification failed.")
+		},
+		getNextID: func(id int) (int, error) {
+			return 100, nil
+		},
+		stopVm: func(*proxmox.VmRef) (string, error) {
+			return "", nil
+		},
+		deleteVm: func(*proxmox.VmRef) (string, error) {
+			deleted = true
+			return "", nil
+		},
+	}
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("config", c)
+	state.Put("proxmoxClient", mock)
+	s := stepStartVM{vmCreator: mock}
+
+	action := s.Run(context.TODO(), state)
+	if action != multistep.ActionHalt {
+		t.Fatalf("Expected action halt, got %s", action)
+	}
+	s.Cleanup(state)
+	if !deleted {
+		t.Error("Expected the VM to be deleted after applying extra_config failed")
+	}
+}
+
 func TestStartVMRetryOnDuplicateID(t *testing.T) {
 	newDuplicateError := func(id int) error {
 		return fmt.Errorf("unable to create VM %d - VM %d already exists on node 'test'", id, id)
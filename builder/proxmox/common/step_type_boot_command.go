@@ -5,10 +5,8 @@ package proxmox
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
-	"net"
 	"time"
 
 	"github.com/Telmate/proxmox-api-go/proxmox"
@@ -26,8 +24,10 @@ type stepTypeBootCommand struct {
 }
 
 type bootCommandTemplateData struct {
-	HTTPIP   string
-	HTTPPort int
+	HTTPIP         string
+	HTTPPort       int
+	BootFilesLabel string
+	buildNetworkTemplateData
 }
 
 type commandTyper interface {
@@ -46,6 +46,10 @@ func (s *stepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag)
 		log.Println("No boot command given, skipping")
 		return multistep.ActionContinue
 	}
+	if _, ok := state.GetOk("resumed_from_snapshot"); ok {
+		ui.Say("VM was resumed from a snapshot, skipping boot command")
+		return multistep.ActionContinue
+	}
 
 	if int64(s.BootWait) > 0 {
 		ui.Say(fmt.Sprintf("Waiting %s for boot", s.BootWait))
@@ -56,25 +60,29 @@ func (s *stepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag)
 			return multistep.ActionHalt
 		}
 	}
-	var httpIP string
-	var err error
-	if c.HTTPAddress != "0.0.0.0" {
-		httpIP = c.HTTPAddress
+	data := &bootCommandTemplateData{
+		HTTPPort: state.Get("http_port").(int),
+
+		buildNetworkTemplateData: c.buildNetworkData(),
+	}
+	if c.bootFilesEnabled() {
+		// There is no HTTP server, the files are on the boot_files ISO
+		data.BootFilesLabel = c.BootFiles.Label
+	} else if httpIP, ok := state.Get("http_ip").(string); ok {
+		// The HTTP server is reached through http_tunnel
+		data.HTTPIP = httpIP
 	} else {
-		httpIP, err = hostIP(c.HTTPInterface)
+		httpIP, err := httpServerIP(c)
 		if err != nil {
 			err := fmt.Errorf("Failed to determine host IP: %s", err)
 			state.Put("error", err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
+		state.Put("http_ip", httpIP)
+		data.HTTPIP = httpIP
 	}
-
-	state.Put("http_ip", httpIP)
-	s.Ctx.Data = &bootCommandTemplateData{
-		HTTPIP:   httpIP,
-		HTTPPort: state.Get("http_port").(int),
-	}
+	s.Ctx.Data = data
 
 	ui.Say("Typing the boot command")
 	d := NewProxmoxDriver(client, vmRef, c.BootKeyInterval)
@@ -105,32 +113,3 @@ func (s *stepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag)
 }
 
 func (*stepTypeBootCommand) Cleanup(multistep.StateBag) {}
-
-func hostIP(ifname string) (string, error) {
-	var addrs []net.Addr
-	var err error
-
-	if ifname != "" {
-		iface, err := net.InterfaceByName(ifname)
-		if err != nil {
-			return "", err
-		}
-		addrs, err = iface.Addrs()
-		if err != nil {
-			return "", err
-		}
-	} else {
-		addrs, err = net.InterfaceAddrs()
-		if err != nil {
-			return "", err
-		}
-	}
-	for _, addr := range addrs {
-		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-			if ipnet.IP.To4() != nil {
-				return ipnet.IP.String(), nil
-			}
-		}
-	}
-	return "", errors.New("No host IP found")
-}
@@ -83,6 +83,26 @@ func TestTypeBootCommand(t *testing.T) {
 			expectedKeysSent:  "shift-h",
 			expectedAction:    multistep.ActionContinue,
 		},
+		{
+			name: "build network in boot command",
+			builderConfig: &Config{
+				BootConfig:   bootcommand.BootConfig{BootCommand: []string{"{{ .IP }} {{ .PrefixLength }}"}},
+				BuildNetwork: buildNetworkConfig{IP: "192.0.2.50", Netmask: "255.255.255.0", prefixLength: 24},
+			},
+			expectCallSendkey: true,
+			expectedKeysSent:  "192dot0dot2dot50spc24",
+			expectedAction:    multistep.ActionContinue,
+		},
+		{
+			name: "boot files label in boot command",
+			builderConfig: &Config{
+				BootConfig: bootcommand.BootConfig{BootCommand: []string{"{{ .BootFilesLabel }}"}},
+				BootFiles:  bootFilesConfig{ISOStoragePool: "local", Label: "OEMDRV"},
+			},
+			expectCallSendkey: true,
+			expectedKeysSent:  "shift-oshift-eshift-mshift-dshift-rshift-v",
+			expectedAction:    multistep.ActionContinue,
+		},
 		{
 			name:              "without boot command sendkey should not be called",
 			builderConfig:     &Config{BootConfig: bootcommand.BootConfig{BootCommand: []string{}}},
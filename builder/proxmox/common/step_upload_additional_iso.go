@@ -86,12 +86,21 @@ func (s *stepUploadAdditionalISO) Run(ctx context.Context, state multistep.State
 	return multistep.ActionContinue
 }
 
+// isGenerated returns whether the ISO is created from cd_files or cd_content
+// and uploaded during the build, and deleted again in cleanup.
+func (iso additionalISOsConfig) isGenerated() bool {
+	return (len(iso.CDFiles) > 0 || len(iso.CDContent) > 0) && iso.DownloadPathKey != ""
+}
+
 func (s *stepUploadAdditionalISO) Cleanup(state multistep.StateBag) {
 	c := state.Get("config").(*Config)
 	ui := state.Get("ui").(packersdk.Ui)
 	client := state.Get("proxmoxClient").(uploader)
 
-	if (len(s.ISO.CDFiles) > 0 || len(s.ISO.CDContent) > 0) && s.ISO.DownloadPathKey != "" {
+	// Generated ISOs, like the boot_files ISO with the rendered templates,
+	// are deleted whether the build succeeded or not, they are detached from
+	// the template if unmount is set
+	if s.ISO.isGenerated() && s.ISO.ISOFile != "" {
 		// Fake a VM reference, DeleteVolume just needs the node to be valid
 		vmRef := &proxmoxapi.VmRef{}
 		vmRef.SetNode(c.Node)
@@ -50,6 +50,7 @@ func TestUploadAdditionalISO(t *testing.T) {
 		generatedISOPath   string
 		failUpload         bool
 		failDelete         bool
+		succeeded          bool
 		expectError        bool
 		expectUploadCalled bool
 		expectDeleteCalled bool
@@ -148,6 +149,49 @@ func TestUploadAdditionalISO(t *testing.T) {
 			expectedISOPath:    "local:iso/test.iso",
 			expectDeleteCalled: true,
 		},
+		{
+			name:          "unmounted generated ISO should be deleted after a successful build",
+			builderConfig: &Config{},
+			step: &stepUploadAdditionalISO{
+				ISO: &additionalISOsConfig{
+					ShouldUploadISO: true,
+					ISOStoragePool:  "local",
+					Unmount:         true,
+					CDConfig: commonsteps.CDConfig{
+						CDContent: map[string]string{"ks.cfg": "rootpw secret"},
+					},
+					DownloadPathKey: "../iso/testdata/test.iso",
+				},
+			},
+			generatedISOPath: "../iso/testdata/test.iso",
+			succeeded:        true,
+
+			expectError:        false,
+			expectedAction:     multistep.ActionContinue,
+			expectUploadCalled: true,
+			expectedISOPath:    "local:iso/test.iso",
+			expectDeleteCalled: true,
+		},
+		{
+			name:          "generated ISO failing to upload should not be deleted",
+			builderConfig: &Config{},
+			step: &stepUploadAdditionalISO{
+				ISO: &additionalISOsConfig{
+					ShouldUploadISO: true,
+					ISOStoragePool:  "local",
+					CDConfig: commonsteps.CDConfig{
+						CDFiles: []string{"testfile"},
+					},
+					DownloadPathKey: "../iso/testdata/test.iso",
+				},
+			},
+			generatedISOPath:   "../iso/testdata/test.iso",
+			failUpload:         true,
+			expectError:        true,
+			expectedAction:     multistep.ActionHalt,
+			expectUploadCalled: true,
+			expectDeleteCalled: false,
+		},
 		{
 			name:          "downloaded ISO should be uploaded",
 			builderConfig: &Config{},
@@ -198,6 +242,9 @@ func TestUploadAdditionalISO(t *testing.T) {
 
 			step := c.step
 			action := step.Run(context.TODO(), state)
+			if c.succeeded {
+				state.Put("success", true)
+			}
 			step.Cleanup(state)
 
 			if action != c.expectedAction {
@@ -0,0 +1,153 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+	"github.com/pkg/sftp"
+)
+
+// stepUploadHookscript copies hookscript.file to the snippets directory of
+// its storage, so stepStartVM can attach it to the VM before it is started.
+// The upload API of Proxmox doesn't accept snippets, so the file is copied
+// over SFTP with the node_ssh credentials. The snippet is removed again in
+// cleanup, unless it is kept on the template.
+type stepUploadHookscript struct {
+	ns     nodeSSHConfig
+	path   string
+	volume string
+}
+
+type storageConfigReader interface {
+	nodeNetworkReader
+	GetStorageConfig(id string) (map[string]interface{}, error)
+}
+
+var _ storageConfigReader = &proxmoxapi.Client{}
+
+func (s *stepUploadHookscript) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(storageConfigReader)
+	c := state.Get("config").(*Config)
+
+	if c.Hookscript.File == "" {
+		return multistep.ActionContinue
+	}
+
+	dir, err := snippetsDir(client, c.Hookscript.StoragePool)
+	if err != nil {
+		err := fmt.Errorf("error uploading hookscript: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Snippets of concurrent builds must not overwrite each other
+	filename := fmt.Sprintf("packer-%s-%s", uuid.TimeOrderedUUID()[:8], filepath.Base(c.Hookscript.File))
+	s.ns = c.resolveNodeSSH(client)
+	ui.Say(fmt.Sprintf("Uploading hookscript to %s on %s", c.Hookscript.StoragePool, s.ns.Host))
+	err = withNodeSFTP(s.ns, func(sc *sftp.Client) error {
+		return uploadSnippet(sc, c.Hookscript.File, dir, filename)
+	})
+	if err != nil {
+		err := fmt.Errorf("error uploading hookscript: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.path = path.Join(dir, filename)
+	s.volume = fmt.Sprintf("%s:snippets/%s", c.Hookscript.StoragePool, filename)
+	state.Put("hookscript_volume", s.volume)
+	ui.Message(fmt.Sprintf("Uploaded hookscript to %s", s.volume))
+	return multistep.ActionContinue
+}
+
+func (s *stepUploadHookscript) Cleanup(state multistep.StateBag) {
+	if s.path == "" {
+		return
+	}
+	c := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	_, succeeded := state.GetOk("success")
+	if succeeded && c.Hookscript.KeepOnTemplate {
+		return
+	}
+
+	err := withNodeSFTP(s.ns, func(sc *sftp.Client) error {
+		return sc.Remove(s.path)
+	})
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error deleting hookscript %s: %s", s.volume, err))
+		return
+	}
+	ui.Message(fmt.Sprintf("Deleted hookscript %s", s.volume))
+}
+
+// snippetsDir returns the directory snippets of storage are stored in on the
+// node. Only file based storages like dir or nfs have one.
+func snippetsDir(client storageConfigReader, storage string) (string, error) {
+	config, err := client.GetStorageConfig(storage)
+	if err != nil {
+		return "", fmt.Errorf("error reading storage %s: %s", storage, err)
+	}
+	dir, _ := config["path"].(string)
+	if dir == "" {
+		return "", fmt.Errorf("storage %s has no path, snippets need a file based storage", storage)
+	}
+	return path.Join(dir, "snippets"), nil
+}
+
+// withNodeSFTP runs f with an SFTP session on the Proxmox node.
+func withNodeSFTP(ns nodeSSHConfig, f func(*sftp.Client) error) error {
+	conn, err := dialNode(ns)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	sc, err := sftp.NewClient(conn)
+	if err != nil {
+		return fmt.Errorf("error starting SFTP session on the Proxmox node: %s", err)
+	}
+	defer sc.Close()
+	return f(sc)
+}
+
+// uploadSnippet copies the local file src to dir/filename. Proxmox only runs
+// hookscripts that are executable.
+func uploadSnippet(sc *sftp.Client, src string, dir string, filename string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := sc.MkdirAll(dir); err != nil {
+		return err
+	}
+	dst := path.Join(dir, filename)
+	w, err := sc.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return sc.Chmod(dst, 0755)
+}
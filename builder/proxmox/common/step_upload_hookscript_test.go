@@ -0,0 +1,213 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+type storageConfigReaderMock struct {
+	nodeNetworkReaderMock
+	storageConfig map[string]interface{}
+}
+
+func (m storageConfigReaderMock) GetStorageConfig(id string) (map[string]interface{}, error) {
+	if m.storageConfig == nil {
+		return nil, fmt.Errorf("storage '%s' does not exist", id)
+	}
+	return m.storageConfig, nil
+}
+
+var _ storageConfigReader = storageConfigReaderMock{}
+
+// startSFTPServer starts an SSH server accepting the password "secret",
+// which serves the local file system over SFTP, and returns its port.
+func startSFTPServer(t *testing.T) int {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != "secret" {
+				return nil, fmt.Errorf("wrong password")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTPConn(conn, config)
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func serveSFTPConn(conn net.Conn, config *ssh.ServerConfig) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "only sessions")
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range chReqs {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+				server, err := sftp.NewServer(ch)
+				if err != nil {
+					ch.Close()
+					return
+				}
+				go func() {
+					server.Serve()
+					ch.Close()
+				}()
+			}
+		}()
+	}
+}
+
+func TestStepUploadHookscript(t *testing.T) {
+	port := startSFTPServer(t)
+	script := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nexit 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cs := []struct {
+		name           string
+		noStoragePath  bool
+		keepOnTemplate bool
+		succeeded      bool
+		expectedAction multistep.StepAction
+		expectKept     bool
+	}{
+		{
+			name:           "deleted when the build fails",
+			keepOnTemplate: true,
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "deleted after the build unless kept on the template",
+			succeeded:      true,
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "kept on the template",
+			keepOnTemplate: true,
+			succeeded:      true,
+			expectedAction: multistep.ActionContinue,
+			expectKept:     true,
+		},
+		{
+			name:           "storage without path",
+			noStoragePath:  true,
+			expectedAction: multistep.ActionHalt,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			storagePath := t.TempDir()
+			mock := storageConfigReaderMock{storageConfig: map[string]interface{}{"path": storagePath}}
+			if c.noStoragePath {
+				mock.storageConfig = map[string]interface{}{"type": "lvmthin"}
+			}
+			config := &Config{
+				Node: "pve",
+				Hookscript: hookscriptConfig{
+					File:           script,
+					StoragePool:    "local",
+					KeepOnTemplate: c.keepOnTemplate,
+				},
+				NodeSSH: nodeSSHConfig{
+					Host:     "127.0.0.1",
+					Port:     port,
+					Username: "root",
+					Password: "secret",
+					Timeout:  5 * time.Second,
+				},
+			}
+
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("config", config)
+			state.Put("proxmoxClient", mock)
+
+			step := stepUploadHookscript{}
+			action := step.Run(context.TODO(), state)
+			if action != c.expectedAction {
+				t.Fatalf("Expected action %s, got %s", c.expectedAction, action)
+			}
+			if action == multistep.ActionHalt {
+				return
+			}
+
+			volume := state.Get("hookscript_volume").(string)
+			if !strings.HasPrefix(volume, "local:snippets/packer-") || !strings.HasSuffix(volume, "-hook.sh") {
+				t.Fatalf("unexpected hookscript volume %s", volume)
+			}
+			uploaded := filepath.Join(storagePath, "snippets", strings.TrimPrefix(volume, "local:snippets/"))
+			info, err := os.Stat(uploaded)
+			if err != nil {
+				t.Fatalf("hookscript wasn't uploaded: %s", err)
+			}
+			if info.Mode().Perm()&0100 == 0 {
+				t.Errorf("hookscript isn't executable: %s", info.Mode())
+			}
+
+			if c.succeeded {
+				state.Put("success", true)
+			}
+			step.Cleanup(state)
+			_, err = os.Stat(uploaded)
+			if kept := err == nil; kept != c.expectKept {
+				t.Errorf("Expected hookscript kept: %t, got %t", c.expectKept, kept)
+			}
+		})
+	}
+}
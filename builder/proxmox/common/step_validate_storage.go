@@ -0,0 +1,218 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StorageRequirement describes a storage pool used by the build, the content
+// type it needs to support and, if known, the space that will be allocated
+// on it in GB.
+//
+// Builders can add their own requirements to the ones of the common config by
+// putting a []StorageRequirement in the "storage_requirements" state key.
+type StorageRequirement struct {
+	Option  string
+	Pool    string
+	Content string
+	SizeGB  float64
+}
+
+// stepValidateStorage checks all storage pools used by the build against the
+// storages available on the node, before anything is downloaded, uploaded or
+// created.
+type stepValidateStorage struct{}
+
+type storageLister interface {
+	GetItemList(url string) (map[string]interface{}, error)
+}
+
+var _ storageLister = &proxmox.Client{}
+
+type nodeStorage struct {
+	content map[string]bool
+	active  bool
+	availGB float64
+}
+
+func (s *stepValidateStorage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(storageLister)
+	c := state.Get("config").(*Config)
+
+	requirements := c.storageRequirements()
+	if extra, ok := state.GetOk("storage_requirements"); ok {
+		requirements = append(requirements, extra.([]StorageRequirement)...)
+	}
+	if len(requirements) == 0 {
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Validating storage pools")
+	if err := ValidateStorage(client, c.Node, requirements); err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+func (s *stepValidateStorage) Cleanup(state multistep.StateBag) {}
+
+// ValidateStorage checks requirements against the storages of node. Builders
+// use it for requirements that are only known later in the build, like the
+// sizes of the disks of a downloaded appliance.
+func ValidateStorage(client storageLister, node string, requirements []StorageRequirement) error {
+	storages, err := listNodeStorage(client, node)
+	if err != nil {
+		return fmt.Errorf("error listing storage of node %s: %s", node, err)
+	}
+	if errs := validateStorageRequirements(requirements, storages); errs != nil {
+		return errs
+	}
+	return nil
+}
+
+func listNodeStorage(client storageLister, node string) (map[string]nodeStorage, error) {
+	list, err := client.GetItemList(fmt.Sprintf("/nodes/%s/storage", node))
+	if err != nil {
+		return nil, err
+	}
+	items, ok := list["data"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected response listing storage: %v", list)
+	}
+
+	storages := map[string]nodeStorage{}
+	for _, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := entry["storage"].(string)
+		content, _ := entry["content"].(string)
+		active, _ := entry["active"].(float64)
+		avail, _ := entry["avail"].(float64)
+
+		storage := nodeStorage{
+			content: map[string]bool{},
+			active:  active == 1,
+			availGB: avail / (1 << 30),
+		}
+		for _, ct := range strings.Split(content, ",") {
+			storage.content[ct] = true
+		}
+		storages[name] = storage
+	}
+	return storages, nil
+}
+
+// validateStorageRequirements returns a MultiError with every requirement not
+// met by the storages of the node, or nil if all are met. Sizes of
+// requirements on the same pool are added up.
+func validateStorageRequirements(requirements []StorageRequirement, storages map[string]nodeStorage) *packersdk.MultiError {
+	var errs *packersdk.MultiError
+
+	requiredGB := map[string]float64{}
+	pools := []string{}
+	for _, req := range requirements {
+		storage, ok := storages[req.Pool]
+		if !ok {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("%s: storage pool %q does not exist on the node", req.Option, req.Pool))
+			continue
+		}
+		if !storage.active {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("%s: storage pool %q is not active on the node", req.Option, req.Pool))
+			continue
+		}
+		if !storage.content[req.Content] {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("%s: storage pool %q does not support content type %q", req.Option, req.Pool, req.Content))
+			continue
+		}
+		if req.SizeGB > 0 {
+			if _, ok := requiredGB[req.Pool]; !ok {
+				pools = append(pools, req.Pool)
+			}
+			requiredGB[req.Pool] += req.SizeGB
+		}
+	}
+
+	sort.Strings(pools)
+	for _, pool := range pools {
+		if requiredGB[pool] > storages[pool].availGB {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("storage pool %q has %.2fG available, but %.2fG are required", pool, storages[pool].availGB, requiredGB[pool]))
+		}
+	}
+	return errs
+}
+
+// storageRequirements returns the storage pools used by the common config.
+func (c *Config) storageRequirements() []StorageRequirement {
+	var requirements []StorageRequirement
+	for idx, disk := range c.Disks {
+		if disk.StoragePool == "" {
+			continue
+		}
+		req := StorageRequirement{
+			Option:  fmt.Sprintf("disks[%d].storage_pool", idx),
+			Pool:    disk.StoragePool,
+			Content: "images",
+		}
+		if disk.Size != "" {
+			req.SizeGB = proxmox.DiskSizeGB(disk.Size)
+		}
+		requirements = append(requirements, req)
+	}
+	if c.EFIConfig.EFIStoragePool != "" {
+		requirements = append(requirements, StorageRequirement{
+			Option:  "efi_config.efi_storage_pool",
+			Pool:    c.EFIConfig.EFIStoragePool,
+			Content: "images",
+		})
+	}
+	if c.TPMConfig.TPMStoragePool != "" {
+		requirements = append(requirements, StorageRequirement{
+			Option:  "tpm_config.tpm_storage_pool",
+			Pool:    c.TPMConfig.TPMStoragePool,
+			Content: "images",
+		})
+	}
+	if c.CloudInit && c.CloudInitStoragePool != "" {
+		requirements = append(requirements, StorageRequirement{
+			Option:  "cloud_init_storage_pool",
+			Pool:    c.CloudInitStoragePool,
+			Content: "images",
+		})
+	}
+	if pool := c.Hookscript.storagePool(); pool != "" {
+		option := "hookscript.volume"
+		if c.Hookscript.File != "" {
+			option = "hookscript.storage_pool"
+		}
+		requirements = append(requirements, StorageRequirement{
+			Option:  option,
+			Pool:    pool,
+			Content: "snippets",
+		})
+	}
+	for _, iso := range c.AdditionalISOFiles {
+		if !iso.ShouldUploadISO {
+			continue
+		}
+		requirements = append(requirements, StorageRequirement{
+			Option:  fmt.Sprintf("additional_iso_files %s iso_storage_pool", iso.Device),
+			Pool:    iso.ISOStoragePool,
+			Content: "iso",
+		})
+	}
+	return requirements
+}
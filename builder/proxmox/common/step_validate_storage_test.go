@@ -0,0 +1,142 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type storageListerMock struct {
+	getItemList func(url string) (map[string]interface{}, error)
+}
+
+func (m storageListerMock) GetItemList(url string) (map[string]interface{}, error) {
+	return m.getItemList(url)
+}
+
+var _ storageLister = storageListerMock{}
+
+func nodeStorageList() map[string]interface{} {
+	return map[string]interface{}{
+		"data": []interface{}{
+			map[string]interface{}{
+				"storage": "local",
+				"content": "iso,vztmpl,backup,snippets",
+				"active":  float64(1),
+				"avail":   float64(50 << 30),
+			},
+			map[string]interface{}{
+				"storage": "local-lvm",
+				"content": "images,rootdir",
+				"active":  float64(1),
+				"avail":   float64(30 << 30),
+			},
+			map[string]interface{}{
+				"storage": "nfs-offline",
+				"content": "images,iso",
+				"active":  float64(0),
+				"avail":   float64(0),
+			},
+		},
+	}
+}
+
+func TestStepValidateStorage(t *testing.T) {
+	cs := []struct {
+		name           string
+		config         *Config
+		extra          []StorageRequirement
+		expectedAction multistep.StepAction
+		expectedErrors []string
+	}{
+		{
+			name: "all pools valid",
+			config: &Config{
+				Disks:     []diskConfig{{StoragePool: "local-lvm", Size: "20G"}},
+				EFIConfig: efiConfig{EFIStoragePool: "local-lvm"},
+			},
+			extra: []StorageRequirement{
+				{Option: "iso_storage_pool", Pool: "local", Content: "iso"},
+			},
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name:           "nothing to validate",
+			config:         &Config{},
+			expectedAction: multistep.ActionContinue,
+		},
+		{
+			name: "all problems reported together",
+			config: &Config{
+				Disks: []diskConfig{
+					{StoragePool: "local-lvm", Size: "20G"},
+					{StoragePool: "local-lvm", Size: "20480M"},
+					{StoragePool: "lcoal-lvm", Size: "10G"},
+					{StoragePool: "local", Size: "10G"},
+				},
+				TPMConfig: tpmConfig{TPMStoragePool: "nfs-offline"},
+			},
+			extra: []StorageRequirement{
+				{Option: "iso_storage_pool", Pool: "local-lvm", Content: "iso"},
+			},
+			expectedAction: multistep.ActionHalt,
+			expectedErrors: []string{
+				`disks[2].storage_pool: storage pool "lcoal-lvm" does not exist`,
+				`disks[3].storage_pool: storage pool "local" does not support content type "images"`,
+				`tpm_config.tpm_storage_pool: storage pool "nfs-offline" is not active`,
+				`iso_storage_pool: storage pool "local-lvm" does not support content type "iso"`,
+				`storage pool "local-lvm" has 30.00G available, but 40.00G are required`,
+			},
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			c.config.Node = "pve"
+			mock := storageListerMock{
+				getItemList: func(url string) (map[string]interface{}, error) {
+					if url != "/nodes/pve/storage" {
+						return nil, fmt.Errorf("unexpected url %s", url)
+					}
+					return nodeStorageList(), nil
+				},
+			}
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("config", c.config)
+			state.Put("proxmoxClient", mock)
+			if c.extra != nil {
+				state.Put("storage_requirements", c.extra)
+			}
+
+			step := stepValidateStorage{}
+			action := step.Run(context.TODO(), state)
+			if action != c.expectedAction {
+				t.Fatalf("Expected action %s, got %s", c.expectedAction, action)
+			}
+			if c.expectedAction != multistep.ActionHalt {
+				return
+			}
+
+			errs, ok := state.Get("error").(*packersdk.MultiError)
+			if !ok {
+				t.Fatalf("Expected error to be a packersdk.MultiError, got %T", state.Get("error"))
+			}
+			if len(errs.Errors) != len(c.expectedErrors) {
+				t.Errorf("Expected %d errors, got %d: %s", len(c.expectedErrors), len(errs.Errors), errs)
+			}
+			for _, expected := range c.expectedErrors {
+				if !strings.Contains(errs.Error(), expected) {
+					t.Errorf("Expected error containing %q, got %s", expected, errs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,397 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type windowsUnattendConfig struct {
+	ProductKey     string `mapstructure:"product_key"`
+	Locale         string `mapstructure:"locale"`
+	AdminPassword  string `mapstructure:"admin_password"`
+	ImageName      string `mapstructure:"image_name"`
+	Template       string `mapstructure:"template"`
+	Device         string `mapstructure:"device"`
+	ISOStoragePool string `mapstructure:"iso_storage_pool"`
+	VirtIOISOFile  string `mapstructure:"virtio_iso_file"`
+	VirtIODevice   string `mapstructure:"virtio_device"`
+	VirtIOOSDir    string `mapstructure:"virtio_os_dir"`
+}
+
+// windowsUnattendData is passed to the Autounattend.xml template.
+type windowsUnattendData struct {
+	ProductKey    string
+	Locale        string
+	AdminPassword string
+	ImageName     string
+	UEFI          bool
+	WinRM         bool
+	WinRMUser     string
+	WinRMPassword string
+	DriverPaths   []string
+}
+
+// virtioDriveLetters are the drive letters searched for VirtIO drivers.
+// Windows Setup assigns letters to CD drives in bus order, so the exact
+// letter of the VirtIO ISO is not known in advance. Paths that don't exist
+// are skipped by Setup.
+var virtioDriveLetters = []string{"D", "E", "F", "G"}
+
+// virtioOSDirs maps Proxmox OS types to the directory names used on the
+// VirtIO driver ISO.
+var virtioOSDirs = map[string]string{
+	"win11": "w11",
+	"win10": "w10",
+	"win8":  "w8",
+	"win7":  "w7",
+	"w2k8":  "2k8",
+}
+
+// prepareWindowsUnattend validates the windows_unattend block and adds the
+// generated Autounattend ISO and the VirtIO driver ISO to the additional ISO
+// files, so they're created, uploaded and attached like any other additional
+// ISO. It must run after the disk and network adapter defaults are set.
+func (c *Config) prepareWindowsUnattend() ([]string, []error) {
+	wu := &c.WindowsUnattend
+	if *wu == (windowsUnattendConfig{}) {
+		return nil, nil
+	}
+
+	var warnings []string
+	var errs []error
+
+	if wu.Locale == "" {
+		log.Printf("windows_unattend locale not set, using default 'en-US'")
+		wu.Locale = "en-US"
+	}
+	if wu.Device == "" {
+		log.Printf("windows_unattend device not set, using default 'sata5'")
+		wu.Device = "sata5"
+	}
+	if wu.VirtIODevice == "" {
+		log.Printf("windows_unattend virtio_device not set, using default 'sata4'")
+		wu.VirtIODevice = "sata4"
+	}
+	if wu.ISOStoragePool == "" {
+		errs = append(errs, errors.New("iso_storage_pool must be set for windows_unattend"))
+	}
+
+	winRM := c.Comm.Type == "winrm"
+	if wu.AdminPassword == "" && winRM {
+		wu.AdminPassword = c.Comm.WinRMPassword
+	}
+	if wu.AdminPassword == "" {
+		errs = append(errs, errors.New("admin_password must be set for windows_unattend"))
+	}
+	// The communicator logs in as Administrator with winrm_password
+	if winRM && c.Comm.WinRMUser == "Administrator" && wu.AdminPassword != c.Comm.WinRMPassword {
+		errs = append(errs, errors.New("admin_password must match winrm_password if winrm_username is Administrator"))
+	}
+	packersdk.LogSecretFilter.Set(wu.AdminPassword)
+
+	drivers := c.virtioDrivers()
+	if len(drivers) > 0 && wu.VirtIOISOFile == "" {
+		errs = append(errs, fmt.Errorf("virtio_iso_file must be set for windows_unattend, VirtIO drivers are required for: %s", strings.Join(drivers, ", ")))
+	}
+	if wu.VirtIOISOFile != "" && wu.VirtIOOSDir == "" {
+		osDir, ok := virtioOSDirs[c.OS]
+		if !ok {
+			errs = append(errs, fmt.Errorf("virtio_os_dir must be set for windows_unattend, os %q has no known VirtIO driver directory", c.OS))
+		}
+		wu.VirtIOOSDir = osDir
+	}
+
+	tmpl := defaultAutounattendTemplate
+	if wu.Template != "" {
+		b, err := os.ReadFile(wu.Template)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read windows_unattend template: %s", err))
+		}
+		tmpl = string(b)
+	}
+	if len(errs) > 0 {
+		return warnings, errs
+	}
+
+	data := windowsUnattendData{
+		ProductKey:    wu.ProductKey,
+		Locale:        wu.Locale,
+		AdminPassword: wu.AdminPassword,
+		ImageName:     wu.ImageName,
+		UEFI:          c.BIOS == "ovmf",
+		WinRM:         winRM,
+		WinRMUser:     c.Comm.WinRMUser,
+		WinRMPassword: c.Comm.WinRMPassword,
+	}
+	if wu.VirtIOISOFile != "" {
+		for _, letter := range virtioDriveLetters {
+			for _, driver := range drivers {
+				data.DriverPaths = append(data.DriverPaths, fmt.Sprintf(`%s:\%s\%s\amd64`, letter, driver, wu.VirtIOOSDir))
+			}
+		}
+	}
+	autounattend, err := renderAutounattend(tmpl, data)
+	if err != nil {
+		return warnings, []error{err}
+	}
+
+	c.AdditionalISOFiles = append(c.AdditionalISOFiles, additionalISOsConfig{
+		Device:         wu.Device,
+		ISOStoragePool: wu.ISOStoragePool,
+		Unmount:        true,
+		CDConfig: commonsteps.CDConfig{
+			CDContent: map[string]string{"Autounattend.xml": autounattend},
+			CDLabel:   "UNATTEND",
+		},
+	})
+	if wu.VirtIOISOFile != "" {
+		c.AdditionalISOFiles = append(c.AdditionalISOFiles, additionalISOsConfig{
+			Device:  wu.VirtIODevice,
+			ISOFile: wu.VirtIOISOFile,
+			Unmount: true,
+		})
+	}
+	return warnings, nil
+}
+
+// virtioDrivers returns the directories of the VirtIO drivers Windows Setup
+// needs for the configured disks and network adapters.
+func (c *Config) virtioDrivers() []string {
+	var drivers []string
+	add := func(driver string) {
+		for _, d := range drivers {
+			if d == driver {
+				return
+			}
+		}
+		drivers = append(drivers, driver)
+	}
+	for _, disk := range c.Disks {
+		switch {
+		case disk.Type == "virtio":
+			add("viostor")
+		case disk.Type == "scsi" && strings.HasPrefix(c.SCSIController, "virtio-scsi"):
+			add("vioscsi")
+		}
+	}
+	for _, nic := range c.NICs {
+		if nic.Model == "virtio" {
+			add("NetKVM")
+		}
+	}
+	return drivers
+}
+
+func renderAutounattend(tmpl string, data windowsUnattendData) (string, error) {
+	t, err := template.New("Autounattend.xml").Funcs(template.FuncMap{
+		"xml": func(s string) (string, error) {
+			var buf bytes.Buffer
+			err := xml.EscapeText(&buf, []byte(s))
+			return buf.String(), err
+		},
+	}).Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse windows_unattend template: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render windows_unattend template: %s", err)
+	}
+	return buf.String(), nil
+}
+
+const defaultAutounattendTemplate = `<?xml version="1.0" encoding="utf-8"?>
+<unattend xmlns="urn:schemas-microsoft-com:unattend" xmlns:wcm="http://schemas.microsoft.com/WMIConfig/2002/State">
+  <settings pass="windowsPE">
+    <component name="Microsoft-Windows-International-Core-WinPE" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+      <SetupUILanguage>
+        <UILanguage>{{ xml .Locale }}</UILanguage>
+      </SetupUILanguage>
+      <InputLocale>{{ xml .Locale }}</InputLocale>
+      <SystemLocale>{{ xml .Locale }}</SystemLocale>
+      <UILanguage>{{ xml .Locale }}</UILanguage>
+      <UserLocale>{{ xml .Locale }}</UserLocale>
+    </component>
+{{- if .DriverPaths }}
+    <component name="Microsoft-Windows-PnpCustomizationsWinPE" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+      <DriverPaths>
+{{- range $i, $path := .DriverPaths }}
+        <PathAndCredentials wcm:action="add" wcm:keyValue="{{ $i }}">
+          <Path>{{ xml $path }}</Path>
+        </PathAndCredentials>
+{{- end }}
+      </DriverPaths>
+    </component>
+{{- end }}
+    <component name="Microsoft-Windows-Setup" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+      <DiskConfiguration>
+        <Disk wcm:action="add">
+          <DiskID>0</DiskID>
+          <WillWipeDisk>true</WillWipeDisk>
+          <CreatePartitions>
+{{- if .UEFI }}
+            <CreatePartition wcm:action="add">
+              <Order>1</Order>
+              <Type>EFI</Type>
+              <Size>260</Size>
+            </CreatePartition>
+            <CreatePartition wcm:action="add">
+              <Order>2</Order>
+              <Type>MSR</Type>
+              <Size>16</Size>
+            </CreatePartition>
+            <CreatePartition wcm:action="add">
+              <Order>3</Order>
+              <Type>Primary</Type>
+              <Extend>true</Extend>
+            </CreatePartition>
+{{- else }}
+            <CreatePartition wcm:action="add">
+              <Order>1</Order>
+              <Type>Primary</Type>
+              <Extend>true</Extend>
+            </CreatePartition>
+{{- end }}
+          </CreatePartitions>
+          <ModifyPartitions>
+{{- if .UEFI }}
+            <ModifyPartition wcm:action="add">
+              <Order>1</Order>
+              <PartitionID>1</PartitionID>
+              <Format>FAT32</Format>
+              <Label>System</Label>
+            </ModifyPartition>
+            <ModifyPartition wcm:action="add">
+              <Order>2</Order>
+              <PartitionID>3</PartitionID>
+              <Format>NTFS</Format>
+              <Label>Windows</Label>
+              <Letter>C</Letter>
+            </ModifyPartition>
+{{- else }}
+            <ModifyPartition wcm:action="add">
+              <Order>1</Order>
+              <PartitionID>1</PartitionID>
+              <Active>true</Active>
+              <Format>NTFS</Format>
+              <Label>Windows</Label>
+              <Letter>C</Letter>
+            </ModifyPartition>
+{{- end }}
+          </ModifyPartitions>
+        </Disk>
+      </DiskConfiguration>
+      <ImageInstall>
+        <OSImage>
+{{- if .ImageName }}
+          <InstallFrom>
+            <MetaData wcm:action="add">
+              <Key>/IMAGE/NAME</Key>
+              <Value>{{ xml .ImageName }}</Value>
+            </MetaData>
+          </InstallFrom>
+{{- end }}
+          <InstallTo>
+            <DiskID>0</DiskID>
+            <PartitionID>{{ if .UEFI }}3{{ else }}1{{ end }}</PartitionID>
+          </InstallTo>
+        </OSImage>
+      </ImageInstall>
+      <UserData>
+        <AcceptEula>true</AcceptEula>
+{{- if .ProductKey }}
+        <ProductKey>
+          <Key>{{ xml .ProductKey }}</Key>
+          <WillShowUI>OnError</WillShowUI>
+        </ProductKey>
+{{- end }}
+      </UserData>
+    </component>
+  </settings>
+  <settings pass="oobeSystem">
+    <component name="Microsoft-Windows-International-Core" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+      <InputLocale>{{ xml .Locale }}</InputLocale>
+      <SystemLocale>{{ xml .Locale }}</SystemLocale>
+      <UILanguage>{{ xml .Locale }}</UILanguage>
+      <UserLocale>{{ xml .Locale }}</UserLocale>
+    </component>
+    <component name="Microsoft-Windows-Shell-Setup" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+      <OOBE>
+        <HideEULAPage>true</HideEULAPage>
+        <HideLocalAccountScreen>true</HideLocalAccountScreen>
+        <HideOnlineAccountScreens>true</HideOnlineAccountScreens>
+        <HideWirelessSetupInOOBE>true</HideWirelessSetupInOOBE>
+        <ProtectYourPC>3</ProtectYourPC>
+      </OOBE>
+      <UserAccounts>
+        <AdministratorPassword>
+          <Value>{{ xml .AdminPassword }}</Value>
+          <PlainText>true</PlainText>
+        </AdministratorPassword>
+{{- if and .WinRM (ne .WinRMUser "Administrator") }}
+        <LocalAccounts>
+          <LocalAccount wcm:action="add">
+            <Name>{{ xml .WinRMUser }}</Name>
+            <Group>Administrators</Group>
+            <Password>
+              <Value>{{ xml .WinRMPassword }}</Value>
+              <PlainText>true</PlainText>
+            </Password>
+          </LocalAccount>
+        </LocalAccounts>
+{{- end }}
+      </UserAccounts>
+      <AutoLogon>
+        <Enabled>true</Enabled>
+        <LogonCount>1</LogonCount>
+        <Username>Administrator</Username>
+        <Password>
+          <Value>{{ xml .AdminPassword }}</Value>
+          <PlainText>true</PlainText>
+        </Password>
+      </AutoLogon>
+{{- if .WinRM }}
+      <FirstLogonCommands>
+        <SynchronousCommand wcm:action="add">
+          <Order>1</Order>
+          <CommandLine>powershell -NoProfile -Command "Get-NetConnectionProfile | Set-NetConnectionProfile -NetworkCategory Private"</CommandLine>
+          <Description>Set network profile to private</Description>
+        </SynchronousCommand>
+        <SynchronousCommand wcm:action="add">
+          <Order>2</Order>
+          <CommandLine>cmd.exe /c winrm quickconfig -q</CommandLine>
+          <Description>Enable WinRM</Description>
+        </SynchronousCommand>
+        <SynchronousCommand wcm:action="add">
+          <Order>3</Order>
+          <CommandLine>cmd.exe /c winrm set winrm/config/service @{AllowUnencrypted="true"}</CommandLine>
+          <Description>Allow unencrypted WinRM</Description>
+        </SynchronousCommand>
+        <SynchronousCommand wcm:action="add">
+          <Order>4</Order>
+          <CommandLine>cmd.exe /c winrm set winrm/config/service/auth @{Basic="true"}</CommandLine>
+          <Description>Allow basic WinRM authentication</Description>
+        </SynchronousCommand>
+        <SynchronousCommand wcm:action="add">
+          <Order>5</Order>
+          <CommandLine>cmd.exe /c netsh advfirewall firewall add rule name="WinRM-HTTP" dir=in localport=5985 protocol=TCP action=allow</CommandLine>
+          <Description>Open WinRM firewall port</Description>
+        </SynchronousCommand>
+      </FirstLogonCommands>
+{{- end }}
+    </component>
+  </settings>
+</unattend>
+`
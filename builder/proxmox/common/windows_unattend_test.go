@@ -0,0 +1,188 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmox
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestWindowsUnattend(t *testing.T) {
+	wuTest := []struct {
+		name              string
+		config            map[string]interface{}
+		expectFailure     bool
+		expectISOs        int
+		expectContains    []string
+		expectNotContains []string
+	}{
+		{
+			name: "sata disk and e1000, no drivers needed",
+			config: map[string]interface{}{
+				"disks":            []map[string]interface{}{{"type": "sata", "storage_pool": "local-lvm"}},
+				"network_adapters": []map[string]interface{}{{"bridge": "vmbr0", "model": "e1000"}},
+				"windows_unattend": map[string]interface{}{
+					"admin_password":   "p<ss>&word",
+					"iso_storage_pool": "local",
+				},
+			},
+			expectISOs:        1,
+			expectContains:    []string{"<Value>p&lt;ss&gt;&amp;word</Value>", "<UILanguage>en-US</UILanguage>"},
+			expectNotContains: []string{"DriverPaths", "winrm"},
+		},
+		{
+			name: "virtio-scsi disk and virtio NIC on windows-11 profile",
+			config: map[string]interface{}{
+				"hardware_profile": "windows-11",
+				"disks":            []map[string]interface{}{{"storage_pool": "local-lvm"}},
+				"network_adapters": []map[string]interface{}{{"bridge": "vmbr0"}},
+				"windows_unattend": map[string]interface{}{
+					"admin_password":   "secret",
+					"iso_storage_pool": "local",
+					"virtio_iso_file":  "local:iso/virtio-win.iso",
+					"product_key":      "AAAAA-BBBBB-CCCCC-DDDDD-EEEEE",
+					"locale":           "de-DE",
+				},
+			},
+			expectISOs: 2,
+			expectContains: []string{
+				`<Path>E:\vioscsi\w11\amd64</Path>`,
+				`<Path>E:\NetKVM\w11\amd64</Path>`,
+				"<Key>AAAAA-BBBBB-CCCCC-DDDDD-EEEEE</Key>",
+				"<Type>EFI</Type>",
+				"<UILanguage>de-DE</UILanguage>",
+			},
+			expectNotContains: []string{"viostor"},
+		},
+		{
+			name: "WinRM communicator bootstraps WinRM with its password",
+			config: map[string]interface{}{
+				"communicator":     "winrm",
+				"winrm_username":   "packer",
+				"winrm_password":   "winrmsecret",
+				"disks":            []map[string]interface{}{{"type": "ide", "storage_pool": "local-lvm"}},
+				"network_adapters": []map[string]interface{}{{"bridge": "vmbr0", "model": "e1000"}},
+				"windows_unattend": map[string]interface{}{
+					"iso_storage_pool": "local",
+				},
+			},
+			expectISOs:     1,
+			expectContains: []string{"winrm quickconfig", "<Name>packer</Name>", "<Value>winrmsecret</Value>"},
+		},
+		{
+			name: "WinRM user gets winrm_password if admin_password differs",
+			config: map[string]interface{}{
+				"communicator":     "winrm",
+				"winrm_username":   "packer",
+				"winrm_password":   "winrmsecret",
+				"disks":            []map[string]interface{}{{"type": "ide", "storage_pool": "local-lvm"}},
+				"network_adapters": []map[string]interface{}{{"bridge": "vmbr0", "model": "e1000"}},
+				"windows_unattend": map[string]interface{}{
+					"admin_password":   "adminsecret",
+					"iso_storage_pool": "local",
+				},
+			},
+			expectISOs: 1,
+			expectContains: []string{
+				"<Name>packer</Name>\n            <Group>Administrators</Group>\n            <Password>\n              <Value>winrmsecret</Value>",
+				"<Username>Administrator</Username>\n        <Password>\n          <Value>adminsecret</Value>",
+			},
+		},
+		{
+			name: "WinRM as Administrator with a different admin_password, fail",
+			config: map[string]interface{}{
+				"communicator":     "winrm",
+				"winrm_username":   "Administrator",
+				"winrm_password":   "winrmsecret",
+				"disks":            []map[string]interface{}{{"type": "ide", "storage_pool": "local-lvm"}},
+				"network_adapters": []map[string]interface{}{{"bridge": "vmbr0", "model": "e1000"}},
+				"windows_unattend": map[string]interface{}{
+					"admin_password":   "adminsecret",
+					"iso_storage_pool": "local",
+				},
+			},
+			expectFailure: true,
+		},
+		{
+			name: "virtio disk without virtio_iso_file, fail",
+			config: map[string]interface{}{
+				"disks":            []map[string]interface{}{{"type": "virtio", "storage_pool": "local-lvm"}},
+				"network_adapters": []map[string]interface{}{{"bridge": "vmbr0"}},
+				"windows_unattend": map[string]interface{}{
+					"admin_password":   "secret",
+					"iso_storage_pool": "local",
+				},
+			},
+			expectFailure: true,
+		},
+		{
+			name: "virtio_iso_file with unknown os, fail",
+			config: map[string]interface{}{
+				"os":               "l26",
+				"disks":            []map[string]interface{}{{"type": "virtio", "storage_pool": "local-lvm"}},
+				"network_adapters": []map[string]interface{}{{"bridge": "vmbr0"}},
+				"windows_unattend": map[string]interface{}{
+					"admin_password":   "secret",
+					"iso_storage_pool": "local",
+					"virtio_iso_file":  "local:iso/virtio-win.iso",
+				},
+			},
+			expectFailure: true,
+		},
+		{
+			name: "missing admin_password and iso_storage_pool, fail",
+			config: map[string]interface{}{
+				"windows_unattend": map[string]interface{}{
+					"locale": "en-GB",
+				},
+			},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range wuTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.config {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil {
+				if !tt.expectFailure {
+					t.Fatalf("unexpected failure to prepare config: %s", err)
+				}
+				t.Logf("got expected failure: %s", err)
+				return
+			}
+			if tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+
+			if len(c.AdditionalISOFiles) != tt.expectISOs {
+				t.Fatalf("expected %d additional ISO files, got %d", tt.expectISOs, len(c.AdditionalISOFiles))
+			}
+			unattendISO := c.AdditionalISOFiles[0]
+			if !unattendISO.ShouldUploadISO || !unattendISO.Unmount || unattendISO.Device != "sata5" {
+				t.Errorf("unexpected Autounattend ISO config: %+v", unattendISO)
+			}
+			autounattend := unattendISO.CDContent["Autounattend.xml"]
+			if err := xml.Unmarshal([]byte(autounattend), new(interface{})); err != nil {
+				t.Errorf("rendered Autounattend.xml is not valid XML: %s", err)
+			}
+			for _, s := range tt.expectContains {
+				if !strings.Contains(autounattend, s) {
+					t.Errorf("expected Autounattend.xml to contain %q", s)
+				}
+			}
+			for _, s := range tt.expectNotContains {
+				if strings.Contains(autounattend, s) {
+					t.Errorf("expected Autounattend.xml not to contain %q", s)
+				}
+			}
+		})
+	}
+}
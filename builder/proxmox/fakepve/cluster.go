@@ -0,0 +1,251 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package fakepve provides an in-process fake of the Proxmox VE API for
+// tests. It keeps a stateful in-memory cluster of nodes, storages, VMs and
+// tasks, so whole builds can run against it without a real cluster.
+package fakepve
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// Cluster is the state of the fake cluster. It is loaded from JSON fixtures
+// and can be modified before it is passed to NewServer.
+type Cluster struct {
+	// Name is the name of the cluster, nodes that are not part of a cluster
+	// have none
+	Name  string `json:"name"`
+	Nodes []Node `json:"nodes"`
+	VMs   []VM   `json:"vms"`
+	// AgentInterfaces are reported by the guest agent of VMs that don't have
+	// interfaces of their own, like the VMs created during a build.
+	AgentInterfaces []AgentInterface `json:"agent_interfaces"`
+	// Permissions maps ACL paths to the privileges granted on them.
+	// Privileges propagate to all paths below.
+	Permissions map[string][]string `json:"permissions"`
+}
+
+type Node struct {
+	Name    string    `json:"node"`
+	IP      string    `json:"ip"`
+	Storage []Storage `json:"storage"`
+}
+
+type Storage struct {
+	Name    string `json:"storage"`
+	Type    string `json:"type"`
+	Content string `json:"content"`
+	Active  bool   `json:"active"`
+	Total   int64  `json:"total"`
+	Avail   int64  `json:"avail"`
+	// Volumes are the volume IDs on the storage, like local:iso/debian.iso
+	Volumes []string `json:"volumes"`
+}
+
+type VM struct {
+	ID       int    `json:"vmid"`
+	Node     string `json:"node"`
+	Name     string `json:"name"`
+	Pool     string `json:"pool"`
+	Template bool   `json:"template"`
+	// Status is either running or stopped, defaults to stopped
+	Status string                 `json:"status"`
+	Config map[string]interface{} `json:"config"`
+	// AgentInterfaces override the interfaces of the cluster for this VM
+	AgentInterfaces []AgentInterface `json:"agent_interfaces"`
+	// Snapshots are in the order they were taken. The parent option of
+	// Config names the snapshot the current state is based on.
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+// Snapshot is a snapshot of the config of a VM. The disks aren't modeled.
+type Snapshot struct {
+	Name     string                 `json:"name"`
+	Parent   string                 `json:"parent"`
+	SnapTime int64                  `json:"snaptime"`
+	Config   map[string]interface{} `json:"config"`
+}
+
+// AgentInterface is a network interface as reported by the
+// network-get-interfaces command of the guest agent.
+type AgentInterface struct {
+	Name            string           `json:"name"`
+	HardwareAddress string           `json:"hardware-address"`
+	IPAddresses     []AgentIPAddress `json:"ip-addresses"`
+}
+
+type AgentIPAddress struct {
+	Address string `json:"ip-address"`
+	Type    string `json:"ip-address-type"`
+	Prefix  int    `json:"prefix"`
+}
+
+// Fixture returns the cluster of one of the fixtures shipped with this
+// package, for example "single-node".
+func Fixture(name string) (Cluster, error) {
+	data, err := fixtures.ReadFile("fixtures/" + name + ".json")
+	if err != nil {
+		return Cluster{}, fmt.Errorf("unknown fixture %q", name)
+	}
+	return parseCluster(data)
+}
+
+// LoadFixture returns the cluster described by the JSON file at path.
+func LoadFixture(path string) (Cluster, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Cluster{}, err
+	}
+	return parseCluster(data)
+}
+
+func parseCluster(data []byte) (Cluster, error) {
+	var c Cluster
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Cluster{}, fmt.Errorf("error parsing fixture: %s", err)
+	}
+	for idx := range c.VMs {
+		vm := &c.VMs[idx]
+		if vm.Status == "" {
+			vm.Status = "stopped"
+		}
+		if vm.Config == nil {
+			vm.Config = map[string]interface{}{}
+		}
+		if vm.Name != "" {
+			vm.Config["name"] = vm.Name
+		}
+	}
+	return c, nil
+}
+
+func (c *Cluster) node(name string) *Node {
+	for idx := range c.Nodes {
+		if c.Nodes[idx].Name == name {
+			return &c.Nodes[idx]
+		}
+	}
+	return nil
+}
+
+func (n *Node) storage(name string) *Storage {
+	for idx := range n.Storage {
+		if n.Storage[idx].Name == name {
+			return &n.Storage[idx]
+		}
+	}
+	return nil
+}
+
+func (s *Storage) supports(content string) bool {
+	for _, c := range strings.Split(s.Content, ",") {
+		if c == content {
+			return true
+		}
+	}
+	return false
+}
+
+// canClone reports if the storage can make linked clones of volume.
+// Directory based storages need qcow2 images for that.
+func (s *Storage) canClone(volume string) bool {
+	switch s.Type {
+	case "lvmthin", "zfspool", "rbd", "btrfs":
+		return true
+	case "dir", "nfs", "cifs", "glusterfs":
+		return strings.HasSuffix(volume, ".qcow2")
+	}
+	return false
+}
+
+func (s *Storage) removeVolume(volid string) bool {
+	for idx, v := range s.Volumes {
+		if v == volid {
+			s.Volumes = append(s.Volumes[:idx], s.Volumes[idx+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cluster) vm(id int) *VM {
+	for idx := range c.VMs {
+		if c.VMs[idx].ID == id {
+			return &c.VMs[idx]
+		}
+	}
+	return nil
+}
+
+func (vm *VM) snapshot(name string) *Snapshot {
+	for idx := range vm.Snapshots {
+		if vm.Snapshots[idx].Name == name {
+			return &vm.Snapshots[idx]
+		}
+	}
+	return nil
+}
+
+// removeSnapshot removes the snapshot name, its children and the current
+// state are moved to its parent.
+func (vm *VM) removeSnapshot(name string) {
+	snap := vm.snapshot(name)
+	parent := snap.Parent
+	for idx := range vm.Snapshots {
+		if vm.Snapshots[idx].Parent == name {
+			vm.Snapshots[idx].Parent = parent
+		}
+	}
+	if vm.Config["parent"] == name {
+		if parent == "" {
+			delete(vm.Config, "parent")
+		} else {
+			vm.Config["parent"] = parent
+		}
+	}
+	for idx := range vm.Snapshots {
+		if vm.Snapshots[idx].Name == name {
+			vm.Snapshots = append(vm.Snapshots[:idx], vm.Snapshots[idx+1:]...)
+			return
+		}
+	}
+}
+
+func (c *Cluster) removeVM(id int) {
+	for idx := range c.VMs {
+		if c.VMs[idx].ID == id {
+			c.VMs = append(c.VMs[:idx], c.VMs[idx+1:]...)
+			return
+		}
+	}
+}
+
+// privileges returns the privileges granted on path, including the ones
+// propagated from parent paths.
+func (c *Cluster) privileges(path string) map[string]int {
+	privileges := map[string]int{}
+	for aclPath, privs := range c.Permissions {
+		if aclPath != "/" && path != aclPath && !strings.HasPrefix(path, aclPath+"/") {
+			continue
+		}
+		for _, p := range privs {
+			privileges[p] = 1
+		}
+	}
+	return privileges
+}
+
+func (c *Cluster) copy() Cluster {
+	data, _ := json.Marshal(c)
+	var cp Cluster
+	_ = json.Unmarshal(data, &cp)
+	return cp
+}
@@ -0,0 +1,853 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package fakepve
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const apiPath = "/api2/json"
+
+// Server is a fake Proxmox VE API serving a Cluster. All requests are
+// handled synchronously: tasks have finished by the time their UPID is
+// returned, so clients never wait for task completion.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	cluster  Cluster
+	tasks    map[string]string
+	taskSeq  int
+	failures map[string]string
+	requests []string
+}
+
+// NewServer starts a fake API serving a copy of cluster. Close it when done.
+func NewServer(cluster Cluster) *Server {
+	s := &Server{
+		cluster:  cluster.copy(),
+		tasks:    map[string]string{},
+		failures: map[string]string{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// APIURL returns the URL to use as proxmox_url.
+func (s *Server) APIURL() string {
+	return s.URL + apiPath
+}
+
+// Cluster returns a copy of the current state of the cluster.
+func (s *Server) Cluster() Cluster {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cluster.copy()
+}
+
+// VM returns a copy of the VM with the given ID.
+func (s *Server) VM(id int) (VM, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vm := s.cluster.vm(id)
+	if vm == nil {
+		return VM{}, false
+	}
+	cp := Cluster{VMs: []VM{*vm}}
+	return cp.copy().VMs[0], true
+}
+
+// Requests returns the requests served so far as "METHOD /path", with the
+// path relative to the API URL.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// FailTask makes the next task started by a request to method and path fail
+// with exitStatus, like "POST /nodes/pve/qemu/100/status/start". The failed
+// task doesn't change the cluster.
+func (s *Server) FailTask(method string, path string, exitStatus string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method+" "+path] = exitStatus
+}
+
+// apiError is returned by handlers for requests Proxmox would reject. The
+// message is sent as the reason phrase of the status line, which is what
+// clients show, like the real API does.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func errorf(status int, format string, args ...interface{}) error {
+	return &apiError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, apiPath)
+	request := r.Method + " " + path
+	s.requests = append(s.requests, request)
+
+	if !strings.HasPrefix(r.URL.Path, apiPath+"/") {
+		writeError(w, errorf(http.StatusNotFound, "Not Found"))
+		return
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			writeError(w, errorf(http.StatusBadRequest, "invalid multipart body: %s", err))
+			return
+		}
+	} else if err := r.ParseForm(); err != nil {
+		writeError(w, errorf(http.StatusBadRequest, "invalid form body: %s", err))
+		return
+	}
+
+	if request == "POST /access/ticket" {
+		writeData(w, map[string]interface{}{
+			"username":            r.PostForm.Get("username"),
+			"ticket":              "PVE:fake-ticket",
+			"CSRFPreventionToken": "fake-csrf-token",
+		})
+		return
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "PVEAPIToken=") && !strings.HasPrefix(auth, "PVEAuthCookie=") {
+		writeError(w, errorf(http.StatusUnauthorized, "authentication failure"))
+		return
+	}
+
+	exitStatus, fail := s.failures[request]
+	var before Cluster
+	if fail {
+		before = s.cluster.copy()
+	}
+	data, err := s.route(r.Method, strings.Split(strings.Trim(path, "/"), "/"), r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if upid, ok := data.(task); ok {
+		data = string(upid)
+		if fail {
+			delete(s.failures, request)
+			s.tasks[string(upid)] = exitStatus
+			// failed tasks leave the cluster as it was
+			s.cluster = before
+		}
+	}
+	writeData(w, data)
+}
+
+// task is returned by handlers starting a worker task, the UPID is sent to
+// the client.
+type task string
+
+func (s *Server) startTask(node string, kind string, id string) task {
+	s.taskSeq++
+	upid := fmt.Sprintf("UPID:%s:%08X:00000000:00000000:%s:%s:root@pam:", node, s.taskSeq, kind, id)
+	s.tasks[upid] = "OK"
+	return task(upid)
+}
+
+func (s *Server) route(method string, parts []string, r *http.Request) (interface{}, error) {
+	route := method + " " + strings.Join(parts, "/")
+	switch {
+	case route == "GET version":
+		return map[string]interface{}{"version": "8.0.3", "release": "8.0", "repoid": "fakepve"}, nil
+	case route == "GET access/permissions":
+		path := r.Form.Get("path")
+		return map[string]interface{}{path: s.cluster.privileges(path)}, nil
+	case route == "GET cluster/nextid":
+		return s.nextID(r.Form.Get("vmid"))
+	case route == "GET cluster/resources":
+		return s.resources(r.Form.Get("type")), nil
+	case route == "GET cluster/status":
+		return s.status(), nil
+	case route == "GET nodes":
+		nodes := []interface{}{}
+		for _, n := range s.cluster.Nodes {
+			nodes = append(nodes, map[string]interface{}{"node": n.Name, "status": "online", "type": "node"})
+		}
+		return nodes, nil
+	}
+
+	if len(parts) < 3 || parts[0] != "nodes" {
+		return nil, notImplemented(method, parts)
+	}
+	node := s.cluster.node(parts[1])
+	if node == nil {
+		return nil, errorf(http.StatusInternalServerError, "hostname lookup '%s' failed - failed to get address info for: %s: Name or service not known", parts[1], parts[1])
+	}
+
+	switch parts[2] {
+	case "tasks":
+		if len(parts) == 5 && parts[4] == "status" && method == http.MethodGet {
+			exitStatus, ok := s.tasks[parts[3]]
+			if !ok {
+				return nil, errorf(http.StatusInternalServerError, "no such task")
+			}
+			return map[string]interface{}{
+				"upid":       parts[3],
+				"node":       node.Name,
+				"status":     "stopped",
+				"exitstatus": exitStatus,
+			}, nil
+		}
+	case "storage":
+		return s.routeStorage(method, node, parts[3:], r)
+	case "qemu":
+		return s.routeQemu(method, node, parts[3:], r)
+	}
+	return nil, notImplemented(method, parts)
+}
+
+func notImplemented(method string, parts []string) error {
+	return errorf(http.StatusNotImplemented, "Method '%s /%s' not implemented", method, strings.Join(parts, "/"))
+}
+
+func (s *Server) nextID(requested string) (interface{}, error) {
+	if requested != "" {
+		id, err := strconv.Atoi(requested)
+		if err != nil {
+			return nil, errorf(http.StatusBadRequest, "Parameter verification failed.")
+		}
+		if s.cluster.vm(id) != nil {
+			return nil, errorf(http.StatusBadRequest, "VM %d already exists", id)
+		}
+		return strconv.Itoa(id), nil
+	}
+	for id := 100; ; id++ {
+		if s.cluster.vm(id) == nil {
+			return strconv.Itoa(id), nil
+		}
+	}
+}
+
+func (s *Server) resources(kind string) []interface{} {
+	resources := []interface{}{}
+	if kind == "" || kind == "vm" {
+		for _, vm := range s.cluster.VMs {
+			template := 0
+			if vm.Template {
+				template = 1
+			}
+			res := map[string]interface{}{
+				"id":       fmt.Sprintf("qemu/%d", vm.ID),
+				"type":     "qemu",
+				"vmid":     vm.ID,
+				"node":     vm.Node,
+				"name":     vm.Name,
+				"status":   vm.Status,
+				"template": template,
+			}
+			if vm.Pool != "" {
+				res["pool"] = vm.Pool
+			}
+			resources = append(resources, res)
+		}
+	}
+	if kind == "" || kind == "storage" {
+		for _, n := range s.cluster.Nodes {
+			for _, st := range n.Storage {
+				resources = append(resources, map[string]interface{}{
+					"id":      fmt.Sprintf("storage/%s/%s", n.Name, st.Name),
+					"type":    "storage",
+					"node":    n.Name,
+					"storage": st.Name,
+					"content": st.Content,
+				})
+			}
+		}
+	}
+	return resources
+}
+
+// status lists the cluster, if the nodes are in one, and the nodes. The
+// first node is the one the API is served from.
+func (s *Server) status() []interface{} {
+	status := []interface{}{}
+	if s.cluster.Name != "" {
+		status = append(status, map[string]interface{}{
+			"id":      "cluster",
+			"type":    "cluster",
+			"name":    s.cluster.Name,
+			"nodes":   len(s.cluster.Nodes),
+			"quorate": 1,
+			"version": 1,
+		})
+	}
+	for idx, n := range s.cluster.Nodes {
+		local := 0
+		if idx == 0 {
+			local = 1
+		}
+		status = append(status, map[string]interface{}{
+			"id":     "node/" + n.Name,
+			"type":   "node",
+			"name":   n.Name,
+			"nodeid": idx + 1,
+			"ip":     n.IP,
+			"online": 1,
+			"local":  local,
+		})
+	}
+	return status
+}
+
+func (s *Server) routeStorage(method string, node *Node, parts []string, r *http.Request) (interface{}, error) {
+	if len(parts) == 0 {
+		if method != http.MethodGet {
+			return nil, notImplemented(method, parts)
+		}
+		storages := []interface{}{}
+		for _, st := range node.Storage {
+			active := 0
+			if st.Active {
+				active = 1
+			}
+			storages = append(storages, map[string]interface{}{
+				"storage": st.Name,
+				"type":    st.Type,
+				"content": st.Content,
+				"active":  active,
+				"enabled": 1,
+				"total":   st.Total,
+				"avail":   st.Avail,
+				"used":    st.Total - st.Avail,
+			})
+		}
+		return storages, nil
+	}
+
+	storage := node.storage(parts[0])
+	if storage == nil {
+		return nil, errorf(http.StatusInternalServerError, "storage '%s' does not exist", parts[0])
+	}
+	route := method + " " + strings.Join(parts[1:], "/")
+	switch {
+	case route == "GET content":
+		volumes := []interface{}{}
+		for _, volid := range storage.Volumes {
+			volumes = append(volumes, map[string]interface{}{"volid": volid, "content": volumeContent(volid)})
+		}
+		return volumes, nil
+	case route == "POST content":
+		// allocation of disk images
+		if !storage.supports("images") {
+			return nil, errorf(http.StatusInternalServerError, "storage '%s' does not support content-type 'images'", storage.Name)
+		}
+		volid := storage.Name + ":" + r.PostForm.Get("filename")
+		storage.Volumes = append(storage.Volumes, volid)
+		return volid, nil
+	case route == "POST upload":
+		content := r.PostForm.Get("content")
+		if !uploadContent[content] {
+			return nil, errorf(http.StatusBadRequest, "Parameter verification failed. content: upload content type '%s' not allowed", content)
+		}
+		if !storage.supports(content) {
+			return nil, errorf(http.StatusInternalServerError, "storage '%s' does not support content-type '%s'", storage.Name, content)
+		}
+		if r.MultipartForm == nil || len(r.MultipartForm.File["filename"]) == 0 {
+			return nil, errorf(http.StatusBadRequest, "missing file")
+		}
+		filename := r.MultipartForm.File["filename"][0].Filename
+		volid := fmt.Sprintf("%s:%s/%s", storage.Name, content, filename)
+		storage.removeVolume(volid)
+		storage.Volumes = append(storage.Volumes, volid)
+		return s.startTask(node.Name, "imgcopy", ""), nil
+	case method == http.MethodDelete && len(parts) > 2 && parts[1] == "content":
+		volid := strings.Join(parts[2:], "/")
+		if !strings.Contains(volid, ":") {
+			volid = storage.Name + ":" + volid
+		}
+		if !storage.removeVolume(volid) {
+			return nil, errorf(http.StatusInternalServerError, "volume '%s' does not exist", volid)
+		}
+		return s.startTask(node.Name, "imgdel", ""), nil
+	}
+	return nil, notImplemented(method, append([]string{"nodes", node.Name, "storage"}, parts...))
+}
+
+// uploadContent lists the content types the upload API accepts, snippets
+// have to be put on the storage some other way.
+var uploadContent = map[string]bool{"iso": true, "vztmpl": true, "import": true}
+
+// volumeContent returns the content type of a volume ID like local:iso/x.iso
+func volumeContent(volid string) string {
+	_, volume, _ := strings.Cut(volid, ":")
+	if content, _, ok := strings.Cut(volume, "/"); ok {
+		return content
+	}
+	return "images"
+}
+
+func (s *Server) routeQemu(method string, node *Node, parts []string, r *http.Request) (interface{}, error) {
+	if len(parts) == 0 {
+		switch method {
+		case http.MethodPost:
+			return s.createVM(node, r.PostForm)
+		case http.MethodGet:
+			vms := []interface{}{}
+			for _, vm := range s.cluster.VMs {
+				if vm.Node == node.Name {
+					vms = append(vms, map[string]interface{}{"vmid": vm.ID, "name": vm.Name, "status": vm.Status})
+				}
+			}
+			return vms, nil
+		}
+		return nil, notImplemented(method, parts)
+	}
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "Parameter verification failed.")
+	}
+	vm := s.cluster.vm(id)
+	if vm == nil || vm.Node != node.Name {
+		return nil, errorf(http.StatusInternalServerError, "Configuration file 'nodes/%s/qemu-server/%d.conf' does not exist", node.Name, id)
+	}
+
+	if len(parts) > 1 && parts[1] == "snapshot" {
+		return s.routeSnapshot(method, node, vm, parts[2:], r)
+	}
+
+	route := method + " " + strings.Join(parts[1:], "/")
+	switch route {
+	case "DELETE ":
+		if vm.Status == "running" {
+			return nil, errorf(http.StatusInternalServerError, "VM %d is running - destroy failed", id)
+		}
+		s.cluster.removeVM(id)
+		return s.startTask(node.Name, "qmdestroy", parts[0]), nil
+	case "GET config":
+		config := map[string]interface{}{"digest": "fakepve"}
+		for k, v := range vm.Config {
+			config[k] = v
+		}
+		if vm.Template {
+			config["template"] = 1
+		}
+		return config, nil
+	case "POST config", "PUT config":
+		if err := s.updateConfig(vm, r.PostForm); err != nil {
+			return nil, err
+		}
+		if method == http.MethodPut {
+			return nil, nil
+		}
+		return s.startTask(node.Name, "qmconfig", parts[0]), nil
+	case "GET status/current":
+		return map[string]interface{}{"vmid": id, "name": vm.Name, "status": vm.Status, "qmpstatus": vm.Status}, nil
+	case "POST status/start":
+		if vm.Template {
+			return nil, errorf(http.StatusInternalServerError, "you can't start a vm if it's a template")
+		}
+		if vm.Status == "running" {
+			return nil, errorf(http.StatusInternalServerError, "VM %d already running", id)
+		}
+		vm.Status = "running"
+		return s.startTask(node.Name, "qmstart", parts[0]), nil
+	case "POST status/stop", "POST status/shutdown":
+		vm.Status = "stopped"
+		return s.startTask(node.Name, "qm"+parts[2], parts[0]), nil
+	case "POST template":
+		if vm.Status == "running" {
+			return nil, errorf(http.StatusInternalServerError, "you can't convert a VM to template if VM is running")
+		}
+		vm.Template = true
+		return s.startTask(node.Name, "qmtemplate", parts[0]), nil
+	case "GET feature":
+		if r.Form.Get("feature") != "clone" {
+			return nil, notImplemented(method, append([]string{"nodes", node.Name, "qemu"}, parts...))
+		}
+		return s.cloneFeature(vm), nil
+	case "POST clone":
+		return s.cloneVM(node, vm, r.PostForm)
+	case "PUT resize":
+		disk := r.PostForm.Get("disk")
+		config, ok := vm.Config[disk].(string)
+		if !ok {
+			return nil, errorf(http.StatusInternalServerError, "disk '%s' does not exist", disk)
+		}
+		resized := resizeDisk(config, r.PostForm.Get("size"))
+		if sizeGB(diskSize(resized)) < sizeGB(diskSize(config)) {
+			return nil, errorf(http.StatusInternalServerError, "shrinking disks is not supported")
+		}
+		vm.Config[disk] = resized
+		return s.startTask(node.Name, "resize", parts[0]), nil
+	case "PUT sendkey":
+		if vm.Status != "running" {
+			return nil, errorf(http.StatusInternalServerError, "VM %d not running", id)
+		}
+		return nil, nil
+	case "POST monitor":
+		return "", nil
+	case "GET agent/network-get-interfaces":
+		if vm.Status != "running" {
+			return nil, errorf(http.StatusInternalServerError, "VM %d is not running", id)
+		}
+		interfaces := vm.AgentInterfaces
+		if interfaces == nil {
+			interfaces = s.cluster.AgentInterfaces
+		}
+		if len(interfaces) == 0 {
+			return nil, errorf(http.StatusInternalServerError, "QEMU guest agent is not running")
+		}
+		return map[string]interface{}{"result": interfaces}, nil
+	}
+	return nil, notImplemented(method, append([]string{"nodes", node.Name, "qemu"}, parts...))
+}
+
+var validSnapshotName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]{1,39}$`)
+
+// routeSnapshot handles the snapshots of vm. Snapshots are taken without
+// the RAM, so a rollback leaves the VM stopped.
+func (s *Server) routeSnapshot(method string, node *Node, vm *VM, parts []string, r *http.Request) (interface{}, error) {
+	id := strconv.Itoa(vm.ID)
+	switch {
+	case method == http.MethodGet && len(parts) == 0:
+		running := 0
+		if vm.Status == "running" {
+			running = 1
+		}
+		current := map[string]interface{}{"name": "current", "description": "You are here!", "running": running}
+		if parent, ok := vm.Config["parent"]; ok {
+			current["parent"] = parent
+		}
+		snapshots := []interface{}{}
+		for _, snap := range vm.Snapshots {
+			entry := map[string]interface{}{"name": snap.Name, "description": "", "snaptime": snap.SnapTime}
+			if snap.Parent != "" {
+				entry["parent"] = snap.Parent
+			}
+			snapshots = append(snapshots, entry)
+		}
+		return append(snapshots, current), nil
+	case method == http.MethodPost && len(parts) == 0:
+		name := r.PostForm.Get("snapname")
+		if !validSnapshotName.MatchString(name) || name == "current" {
+			return nil, errorf(http.StatusBadRequest, "Parameter verification failed.")
+		}
+		if vm.snapshot(name) != nil {
+			return nil, errorf(http.StatusInternalServerError, "snapshot name '%s' already used", name)
+		}
+		if vm.Template {
+			return nil, errorf(http.StatusInternalServerError, "you can't take a snapshot if it's a template")
+		}
+		snap := Snapshot{
+			Name:     name,
+			SnapTime: int64(len(vm.Snapshots) + 1),
+			Config:   map[string]interface{}{},
+		}
+		for k, v := range vm.Config {
+			if k == "parent" {
+				snap.Parent = v.(string)
+				continue
+			}
+			snap.Config[k] = v
+		}
+		vm.Snapshots = append(vm.Snapshots, snap)
+		vm.Config["parent"] = name
+		return s.startTask(node.Name, "qmsnapshot", id), nil
+	case method == http.MethodDelete && len(parts) == 1:
+		if vm.snapshot(parts[0]) == nil {
+			return nil, errorf(http.StatusInternalServerError, "snapshot '%s' does not exist", parts[0])
+		}
+		vm.removeSnapshot(parts[0])
+		return s.startTask(node.Name, "qmdelsnapshot", id), nil
+	case method == http.MethodPost && len(parts) == 2 && parts[1] == "rollback":
+		snap := vm.snapshot(parts[0])
+		if snap == nil {
+			return nil, errorf(http.StatusInternalServerError, "snapshot '%s' does not exist", parts[0])
+		}
+		vm.Config = map[string]interface{}{"parent": snap.Name}
+		for k, v := range snap.Config {
+			vm.Config[k] = v
+		}
+		vm.Status = "stopped"
+		return s.startTask(node.Name, "qmrollback", id), nil
+	}
+	return nil, notImplemented(method, append([]string{"nodes", node.Name, "qemu", id, "snapshot"}, parts...))
+}
+
+func (s *Server) createVM(node *Node, params url.Values) (interface{}, error) {
+	id, err := strconv.Atoi(params.Get("vmid"))
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "Parameter verification failed.")
+	}
+	if s.cluster.vm(id) != nil {
+		return nil, errorf(http.StatusInternalServerError, "unable to create VM %d - VM %d already exists on node '%s'", id, id, node.Name)
+	}
+	vm := VM{
+		ID:     id,
+		Node:   node.Name,
+		Pool:   params.Get("pool"),
+		Status: "stopped",
+		Config: map[string]interface{}{},
+	}
+	params.Del("vmid")
+	params.Del("pool")
+	if err := s.updateConfig(&vm, params); err != nil {
+		return nil, err
+	}
+	s.cluster.VMs = append(s.cluster.VMs, vm)
+	return s.startTask(node.Name, "qmcreate", strconv.Itoa(id)), nil
+}
+
+// cloneFeature reports if linked clones of vm are possible, and on which
+// nodes, like the feature API does for feature=clone. It needs a template
+// with all disks on storages that can clone volumes. A storage is available
+// on the nodes that have a storage of the same name.
+func (s *Server) cloneFeature(vm *VM) map[string]interface{} {
+	hasFeature := vm.Template
+	var volumes []string
+	for key, value := range vm.Config {
+		config, ok := value.(string)
+		if !ok || !diskKey.MatchString(key) || strings.Contains(config, "media=cdrom") {
+			continue
+		}
+		volumes = append(volumes, strings.Split(config, ",")[0])
+	}
+
+	nodes := []interface{}{}
+	for _, n := range s.cluster.Nodes {
+		available := true
+		for _, volid := range volumes {
+			storageName, volume, _ := strings.Cut(volid, ":")
+			st := n.storage(storageName)
+			if st == nil {
+				available = false
+				continue
+			}
+			if n.Name == vm.Node && !st.canClone(volume) {
+				hasFeature = false
+			}
+		}
+		if available {
+			nodes = append(nodes, n.Name)
+		}
+	}
+	feature := 0
+	if hasFeature {
+		feature = 1
+	}
+	return map[string]interface{}{"hasFeature": feature, "nodes": nodes}
+}
+
+var diskKey = regexp.MustCompile(`^((ide|sata|scsi|virtio)\d+|efidisk0|tpmstate0)$`)
+
+func (s *Server) cloneVM(node *Node, source *VM, params url.Values) (interface{}, error) {
+	id, err := strconv.Atoi(params.Get("newid"))
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "Parameter verification failed.")
+	}
+	if s.cluster.vm(id) != nil {
+		return nil, errorf(http.StatusInternalServerError, "unable to create VM %d: config file already exists", id)
+	}
+	if params.Get("full") == "0" && !source.Template {
+		return nil, errorf(http.StatusInternalServerError, "Linked clone feature is not supported for running images")
+	}
+	if params.Get("full") == "0" && s.cloneFeature(source)["hasFeature"] == 0 {
+		return nil, errorf(http.StatusInternalServerError, "Linked clone feature is not supported for the disks of VM %d", source.ID)
+	}
+	target := node.Name
+	if t := params.Get("target"); t != "" {
+		if s.cluster.node(t) == nil {
+			return nil, errorf(http.StatusInternalServerError, "no such cluster node '%s'", t)
+		}
+		target = t
+	}
+
+	vm := VM{
+		ID:     id,
+		Node:   target,
+		Name:   params.Get("name"),
+		Pool:   params.Get("pool"),
+		Status: "stopped",
+		Config: map[string]interface{}{},
+	}
+	for k, v := range source.Config {
+		vm.Config[k] = v
+	}
+	vm.Config["name"] = vm.Name
+	s.cluster.VMs = append(s.cluster.VMs, vm)
+	return s.startTask(node.Name, "qmclone", strconv.Itoa(source.ID)), nil
+}
+
+// updateConfig applies config parameters to vm, including the deletion of
+// options listed in the delete parameter. ISOs attached as CD-ROM have to
+// exist on a storage of the node.
+func (s *Server) updateConfig(vm *VM, params url.Values) error {
+	for _, key := range strings.Split(params.Get("delete"), ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if _, ok := vm.Config[key]; !ok {
+			return errorf(http.StatusInternalServerError, "unable to delete '%s' - option does not exist", key)
+		}
+		delete(vm.Config, key)
+	}
+
+	keys := []string{}
+	for key := range params {
+		if key != "delete" && key != "digest" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := params.Get(key)
+		if strings.Contains(value, "media=cdrom") {
+			if err := s.checkVolume(vm.Node, strings.Split(value, ",")[0]); err != nil {
+				return err
+			}
+		}
+		if err := s.checkImportFrom(vm.Node, value); err != nil {
+			return err
+		}
+		vm.Config[key] = value
+	}
+	if name, ok := vm.Config["name"].(string); ok {
+		vm.Name = name
+	}
+	return nil
+}
+
+func (s *Server) checkVolume(node string, volid string) error {
+	storageName, _, ok := strings.Cut(volid, ":")
+	if !ok {
+		// none or cdrom for a drive without media
+		return nil
+	}
+	n := s.cluster.node(node)
+	storage := n.storage(storageName)
+	if storage == nil {
+		return errorf(http.StatusInternalServerError, "storage '%s' does not exist", storageName)
+	}
+	for _, v := range storage.Volumes {
+		if v == volid {
+			return nil
+		}
+	}
+	return errorf(http.StatusInternalServerError, "volume '%s' does not exist", volid)
+}
+
+// checkImportFrom checks the import-from option of a disk config, which only
+// takes existing volumes of the images or import content types.
+func (s *Server) checkImportFrom(node string, config string) error {
+	for _, option := range strings.Split(config, ",") {
+		if !strings.HasPrefix(option, "import-from=") {
+			continue
+		}
+		volid := strings.TrimPrefix(option, "import-from=")
+		if content := volumeContent(volid); content != "images" && content != "import" {
+			return errorf(http.StatusInternalServerError, "'import-from' volume '%s' has wrong content type '%s'", volid, content)
+		}
+		return s.checkVolume(node, volid)
+	}
+	return nil
+}
+
+// resizeDisk returns the disk config with the size option set to size, which
+// may be relative if it starts with a +.
+func resizeDisk(config string, size string) string {
+	options := strings.Split(config, ",")
+	for idx, option := range options {
+		if !strings.HasPrefix(option, "size=") {
+			continue
+		}
+		if strings.HasPrefix(size, "+") {
+			current := strings.TrimPrefix(option, "size=")
+			size = fmt.Sprintf("%gG", sizeGB(current)+sizeGB(size[1:]))
+		}
+		options[idx] = "size=" + size
+		return strings.Join(options, ",")
+	}
+	return config + ",size=" + size
+}
+
+// diskSize returns the size option of a disk config, empty if it has none.
+func diskSize(config string) string {
+	for _, option := range strings.Split(config, ",") {
+		if strings.HasPrefix(option, "size=") {
+			return strings.TrimPrefix(option, "size=")
+		}
+	}
+	return ""
+}
+
+func sizeGB(size string) float64 {
+	if size == "" {
+		return 0
+	}
+	factors := map[byte]float64{'K': 1.0 / (1 << 20), 'M': 1.0 / (1 << 10), 'G': 1, 'T': 1 << 10}
+	factor, ok := factors[size[len(size)-1]]
+	if ok {
+		size = size[:len(size)-1]
+	} else {
+		factor = 1.0 / (1 << 30)
+	}
+	value, _ := strconv.ParseFloat(size, 64)
+	return value * factor
+}
+
+func writeData(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+// writeError sends err with its message as reason phrase of the status line.
+// net/http only sends the standard reason phrases, so the connection is
+// hijacked to write the response.
+func writeError(w http.ResponseWriter, err error) {
+	apiErr, ok := err.(*apiError)
+	if !ok {
+		apiErr = &apiError{status: http.StatusInternalServerError, message: err.Error()}
+	}
+	body, _ := json.Marshal(map[string]interface{}{"data": nil, "message": apiErr.message + "\n"})
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, string(body), apiErr.status)
+		return
+	}
+	conn, buf, hijackErr := hijacker.Hijack()
+	if hijackErr != nil {
+		http.Error(w, string(body), apiErr.status)
+		return
+	}
+	defer conn.Close()
+	writeRawResponse(buf.Writer, apiErr, body)
+	_ = buf.Flush()
+}
+
+func writeRawResponse(w *bufio.Writer, apiErr *apiError, body []byte) {
+	reason := strings.ReplaceAll(apiErr.message, "\n", " ")
+	fmt.Fprintf(w, "HTTP/1.1 %d %s\r\n", apiErr.status, reason)
+	fmt.Fprintf(w, "Content-Type: application/json;charset=UTF-8\r\n")
+	fmt.Fprintf(w, "Content-Length: %d\r\n", len(body))
+	fmt.Fprintf(w, "Connection: close\r\n\r\n")
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,297 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package fakepve
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/stretchr/testify/require"
+)
+
+func newClient(t *testing.T, srv *Server) *proxmox.Client {
+	client, err := proxmox.NewClient(srv.APIURL(), nil, "", nil, "", 10)
+	require.NoError(t, err)
+	client.SetAPIToken("root@pam!packer", "xxxx-xxxx-xxxx-xxxx")
+	return client
+}
+
+func newServer(t *testing.T) *Server {
+	cluster, err := Fixture("single-node")
+	require.NoError(t, err)
+	srv := NewServer(cluster)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestLogin(t *testing.T) {
+	srv := newServer(t)
+	client, err := proxmox.NewClient(srv.APIURL(), nil, "", nil, "", 10)
+	require.NoError(t, err)
+
+	_, err = client.GetNextID(0)
+	require.Error(t, err, "requests without authentication must fail")
+
+	require.NoError(t, client.Login("root@pam", "password", ""))
+	id, err := client.GetNextID(0)
+	require.NoError(t, err)
+	require.Equal(t, 100, id)
+}
+
+func TestVMLifecycle(t *testing.T) {
+	srv := newServer(t)
+	client := newClient(t, srv)
+
+	vmRef := proxmox.NewVmRef(100)
+	vmRef.SetNode("pve")
+	config := proxmox.ConfigQemu{
+		Name:     "build",
+		Memory:   1024,
+		QemuIso:  "local:iso/debian-12.iso",
+		QemuOs:   "l26",
+		QemuNuma: new(bool),
+	}
+	require.NoError(t, config.CreateVm(vmRef, client))
+
+	vm, ok := srv.VM(100)
+	require.True(t, ok)
+	require.Equal(t, "build", vm.Name)
+	require.Equal(t, "local:iso/debian-12.iso,media=cdrom", vm.Config["ide2"])
+
+	_, err := client.GetVmAgentNetworkInterfaces(vmRef)
+	require.ErrorContains(t, err, "VM 100 is not running")
+
+	_, err = client.StartVm(vmRef)
+	require.NoError(t, err)
+
+	ifs, err := client.GetVmAgentNetworkInterfaces(vmRef)
+	require.NoError(t, err)
+	require.Len(t, ifs, 2)
+	require.Equal(t, "192.0.2.10", ifs[1].IPAddresses[0].String())
+
+	require.ErrorContains(t, client.CreateTemplate(vmRef), "VM is running")
+	_, err = client.ShutdownVm(vmRef)
+	require.NoError(t, err)
+	require.NoError(t, client.CreateTemplate(vmRef))
+
+	vmConfig, err := client.GetVmConfig(vmRef)
+	require.NoError(t, err)
+	require.Equal(t, float64(1), vmConfig["template"])
+
+	_, err = client.SetVmConfig(vmRef, map[string]interface{}{"delete": "ide2"})
+	require.NoError(t, err)
+	vm, _ = srv.VM(100)
+	require.NotContains(t, vm.Config, "ide2")
+
+	_, err = client.DeleteVm(vmRef)
+	require.NoError(t, err)
+	_, ok = srv.VM(100)
+	require.False(t, ok)
+}
+
+func TestCreateVMWithMissingISO(t *testing.T) {
+	srv := newServer(t)
+	client := newClient(t, srv)
+
+	vmRef := proxmox.NewVmRef(100)
+	vmRef.SetNode("pve")
+	config := proxmox.ConfigQemu{Name: "build", QemuIso: "local:iso/missing.iso"}
+	err := config.CreateVm(vmRef, client)
+	require.ErrorContains(t, err, "volume 'local:iso/missing.iso' does not exist")
+}
+
+func TestCloneVM(t *testing.T) {
+	srv := newServer(t)
+	client := newClient(t, srv)
+
+	source := proxmox.NewVmRef(9000)
+	require.NoError(t, client.CheckVmRef(source))
+
+	linked := 0
+	config := proxmox.ConfigQemu{Name: "clone", FullClone: &linked, QemuDisks: proxmox.QemuDevices{0: {}}}
+	vmRef := proxmox.NewVmRef(101)
+	vmRef.SetNode("pve")
+	require.NoError(t, config.CloneVm(source, vmRef, client))
+
+	vm, ok := srv.VM(101)
+	require.True(t, ok)
+	require.Equal(t, "clone", vm.Name)
+	require.False(t, vm.Template)
+	require.Equal(t, "local-lvm:base-9000-disk-0,size=10G", vm.Config["scsi0"])
+
+	_, err := client.ResizeQemuDiskRaw(vmRef, "scsi0", "+5G")
+	require.NoError(t, err)
+	vm, _ = srv.VM(101)
+	require.Equal(t, "local-lvm:base-9000-disk-0,size=15G", vm.Config["scsi0"])
+
+	// linked clones need a template as source
+	config.Name = "clone-of-clone"
+	err = config.CloneVm(vmRef, proxmox.NewVmRef(102), client)
+	require.ErrorContains(t, err, "Linked clone feature is not supported")
+}
+
+func TestCloneFeature(t *testing.T) {
+	cluster, err := Fixture("single-node")
+	require.NoError(t, err)
+	srv := NewServer(cluster)
+	defer srv.Close()
+	client := newClient(t, srv)
+
+	list, err := client.GetItemList("/nodes/pve/qemu/9000/feature?feature=clone")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"hasFeature": float64(1), "nodes": []interface{}{"pve"}}, list["data"])
+
+	// plain LVM can't make linked clones
+	cluster.Nodes[0].Storage[1].Type = "lvm"
+	srv = NewServer(cluster)
+	defer srv.Close()
+	client = newClient(t, srv)
+
+	list, err = client.GetItemList("/nodes/pve/qemu/9000/feature?feature=clone")
+	require.NoError(t, err)
+	require.Equal(t, float64(0), list["data"].(map[string]interface{})["hasFeature"])
+
+	source := proxmox.NewVmRef(9000)
+	require.NoError(t, client.CheckVmRef(source))
+	linked := 0
+	config := proxmox.ConfigQemu{Name: "clone", FullClone: &linked, QemuDisks: proxmox.QemuDevices{0: {}}}
+	vmRef := proxmox.NewVmRef(101)
+	vmRef.SetNode("pve")
+	require.ErrorContains(t, config.CloneVm(source, vmRef, client), "Linked clone feature is not supported")
+}
+
+func TestClusterStatus(t *testing.T) {
+	cluster, err := Fixture("single-node")
+	require.NoError(t, err)
+	srv := NewServer(cluster)
+	defer srv.Close()
+	client := newClient(t, srv)
+
+	list, err := client.GetItemList("/cluster/status")
+	require.NoError(t, err)
+	status := list["data"].([]interface{})
+	require.Len(t, status, 1, "a standalone node has no cluster entry")
+	require.Equal(t, "node", status[0].(map[string]interface{})["type"])
+	require.Equal(t, "192.0.2.2", status[0].(map[string]interface{})["ip"])
+
+	cluster.Name = "lab"
+	srv = NewServer(cluster)
+	defer srv.Close()
+	client = newClient(t, srv)
+
+	list, err = client.GetItemList("/cluster/status")
+	require.NoError(t, err)
+	status = list["data"].([]interface{})
+	require.Len(t, status, 2)
+	require.Equal(t, "lab", status[0].(map[string]interface{})["name"])
+}
+
+func TestSnapshots(t *testing.T) {
+	srv := newServer(t)
+	client := newClient(t, srv)
+
+	linked := 0
+	source := proxmox.NewVmRef(9000)
+	require.NoError(t, client.CheckVmRef(source))
+	config := proxmox.ConfigQemu{Name: "build", FullClone: &linked, QemuDisks: proxmox.QemuDevices{0: {}}}
+	vmRef := proxmox.NewVmRef(101)
+	vmRef.SetNode("pve")
+	require.NoError(t, config.CloneVm(source, vmRef, client))
+
+	_, err := client.CreateQemuSnapshot(vmRef, "before")
+	require.NoError(t, err)
+	_, err = client.CreateQemuSnapshot(vmRef, "before")
+	require.ErrorContains(t, err, "already used")
+
+	_, err = client.SetVmConfig(vmRef, map[string]interface{}{"memory": 4096})
+	require.NoError(t, err)
+	_, err = client.CreateQemuSnapshot(vmRef, "after")
+	require.NoError(t, err)
+
+	snapshots, _, err := client.ListQemuSnapshot(vmRef)
+	require.NoError(t, err)
+	require.Len(t, snapshots["data"], 3, "two snapshots and the current state")
+
+	_, err = client.RollbackQemuVm(vmRef, "before")
+	require.NoError(t, err)
+	vm, _ := srv.VM(101)
+	require.Equal(t, "2048", fmt.Sprint(vm.Config["memory"]))
+	require.Equal(t, "before", vm.Config["parent"])
+
+	_, err = client.DeleteQemuSnapshot(vmRef, "before")
+	require.NoError(t, err)
+	vm, _ = srv.VM(101)
+	require.NotContains(t, vm.Config, "parent")
+	require.Len(t, vm.Snapshots, 1)
+	require.Empty(t, vm.Snapshots[0].Parent, "after should be moved to the parent of before")
+}
+
+func TestUploadAndDeleteVolume(t *testing.T) {
+	srv := newServer(t)
+	client := newClient(t, srv)
+
+	err := client.Upload("pve", "local", "iso", "cidata.iso", strings.NewReader("data"))
+	require.NoError(t, err)
+	err = client.Upload("pve", "local-lvm", "iso", "cidata.iso", strings.NewReader("data"))
+	require.ErrorContains(t, err, "does not support content-type 'iso'")
+
+	require.Contains(t, srv.Cluster().Nodes[0].Storage[0].Volumes, "local:iso/cidata.iso")
+
+	vmRef := &proxmox.VmRef{}
+	vmRef.SetNode("pve")
+	vmRef.SetVmType("qemu")
+	_, err = client.DeleteVolume(vmRef, "local", "local:iso/cidata.iso")
+	require.NoError(t, err)
+	require.NotContains(t, srv.Cluster().Nodes[0].Storage[0].Volumes, "local:iso/cidata.iso")
+}
+
+func TestFailTask(t *testing.T) {
+	srv := newServer(t)
+	client := newClient(t, srv)
+
+	srv.FailTask("POST", "/nodes/pve/qemu/9000/template", "can't lock file")
+	vmRef := proxmox.NewVmRef(9000)
+	require.ErrorContains(t, client.CreateTemplate(vmRef), "can't lock file")
+	require.NoError(t, client.CreateTemplate(vmRef), "failures only apply to the next task")
+
+	srv.FailTask("POST", "/nodes/pve/qemu/9000/config", "got timeout")
+	_, err := client.SetVmConfig(vmRef, map[string]interface{}{"memory": 4096})
+	require.ErrorContains(t, err, "got timeout")
+	vm, _ := srv.VM(9000)
+	require.Equal(t, "2048", fmt.Sprint(vm.Config["memory"]), "failed tasks don't change the VM")
+}
+
+func TestPermissions(t *testing.T) {
+	cluster, err := Fixture("single-node")
+	require.NoError(t, err)
+	cluster.Permissions = map[string][]string{
+		"/vms":         {"VM.Audit"},
+		"/storage/foo": {"Datastore.Audit"},
+	}
+	srv := NewServer(cluster)
+	t.Cleanup(srv.Close)
+	client := newClient(t, srv)
+
+	list, err := client.GetItemList("/access/permissions?path=/vms/100")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"/vms/100": map[string]interface{}{"VM.Audit": float64(1)},
+	}, list["data"])
+
+	list, err = client.GetItemList("/access/permissions?path=/storage/foobar")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"/storage/foobar": map[string]interface{}{}}, list["data"])
+}
+
+func TestNotImplemented(t *testing.T) {
+	srv := newServer(t)
+	client := newClient(t, srv)
+
+	vmRef := proxmox.NewVmRef(9000)
+	require.NoError(t, client.CheckVmRef(vmRef))
+	_, err := client.MigrateNode(vmRef, "pve2", false)
+	require.ErrorContains(t, err, "501 Method 'POST /nodes/pve/qemu/9000/migrate' not implemented")
+}
@@ -0,0 +1,185 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The unique id for the builder
+const BuilderID = "proxmox.import"
+
+type Builder struct {
+	config Config
+}
+
+// Builder implements packersdk.Builder
+var _ packersdk.Builder = &Builder{}
+
+func (b *Builder) ConfigSpec() hcldec.ObjectSpec { return b.config.FlatMapstructure().HCL2Spec() }
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
+	return b.config.Prepare(raws...)
+}
+
+const downloadPathKey = "downloaded_disk_image_path"
+
+func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	state := new(multistep.BasicStateBag)
+	state.Put("import-config", &b.config)
+	state.Put("lineage_origin", b.config.lineageOrigin())
+	if b.config.shouldUploadImage {
+		state.Put("source_image", b.config.DiskImageURLs[0])
+	} else {
+		state.Put("source_image", b.config.DiskImageFile)
+	}
+	state.Put("permission_requirements", []proxmox.PermissionRequirement{
+		{Path: b.config.VMACLPath(), Privilege: "VM.Config.Cloudinit"},
+	})
+	if b.config.shouldUploadImage {
+		state.Put("storage_requirements", []proxmox.StorageRequirement{
+			{Option: "disk_image_storage_pool", Pool: b.config.DiskImageStoragePool, Content: "import"},
+		})
+	}
+
+	if b.config.DryRun {
+		state.Put("disk_image_file", b.config.imageFile())
+	}
+
+	preSteps := []multistep.Step{
+		&proxmox.StepSshKeyPair{
+			Debug:        b.config.PackerDebug,
+			DebugKeyPath: fmt.Sprintf("%s.pem", b.config.PackerBuildName),
+		},
+	}
+	// A resumed VM already has its disk imported
+	if b.config.ResumeFromSnapshot == "" {
+		if b.config.shouldUploadImage {
+			preSteps = append(preSteps,
+				&commonsteps.StepDownload{
+					Checksum:    b.config.DiskImageChecksum,
+					Description: "disk image",
+					ResultKey:   downloadPathKey,
+					Url:         b.config.DiskImageURLs,
+				},
+			)
+		}
+		preSteps = append(preSteps, &stepUploadDiskImage{})
+	}
+	postSteps := []multistep.Step{}
+
+	sb := proxmox.NewSharedBuilder(BuilderID, b.config.Config, preSteps, postSteps, &importVMCreator{})
+	return sb.Run(ctx, ui, hook, state)
+}
+
+type importVMCreator struct{}
+
+var _ proxmox.ProxmoxVMConfigurer = &importVMCreator{}
+
+func (*importVMCreator) Configure(config *proxmoxapi.ConfigQemu, state multistep.StateBag) error {
+	c := state.Get("import-config").(*Config)
+	imageFile := state.Get("disk_image_file").(string)
+
+	// The first disk is created from the image instead of being allocated
+	// empty. A size of 0 tells Proxmox to use the size of the source image.
+	config.QemuDisks[0]["import-from"] = imageFile
+	config.QemuDisks[0]["size"] = "0"
+	if config.Boot == "" {
+		config.Boot = fmt.Sprintf("order=%s0", c.Disks[0].Type)
+	}
+	return nil
+}
+
+func (ic *importVMCreator) Create(vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu, state multistep.StateBag) error {
+	client := state.Get("proxmoxClient").(*proxmoxapi.Client)
+	c := state.Get("import-config").(*Config)
+	comm := state.Get("config").(*proxmox.Config).Comm
+
+	err := ic.Configure(&config, state)
+	if err != nil {
+		return err
+	}
+	bootDisk := fmt.Sprintf("%s0", c.Disks[0].Type)
+
+	err = config.CreateVm(vmRef, client)
+	if err != nil {
+		return err
+	}
+	// The VM is deleted by stepStartVM's cleanup if anything below fails
+	state.Put("vmRef", vmRef)
+	err = proxmox.GrowDisk(client, vmRef, bootDisk, c.Disks[0].Size)
+	if err != nil {
+		return err
+	}
+
+	// Cloud images expect their credentials from cloud-init, so attach a
+	// drive for the build. It is removed again before the VM is converted.
+	changes := cloudInitParams(c, comm.SSHUsername, comm.SSHPassword, string(comm.SSHPublicKey))
+	if changes == nil {
+		log.Printf("no free IDE controller for a cloud-init drive, skipping")
+		return nil
+	}
+	_, err = client.SetVmConfig(vmRef, changes)
+	return err
+}
+
+// cloudInitParams returns the VM config parameters attaching a cloud-init
+// drive on the first IDE controller not used by a disk or an additional ISO.
+// It returns nil if all controllers are in use.
+func cloudInitParams(c *Config, user string, password string, publicKey string) map[string]interface{} {
+	used := map[string]bool{}
+	for idx, disk := range c.Disks {
+		used[fmt.Sprintf("%s%d", disk.Type, idx)] = true
+	}
+	for _, iso := range c.AdditionalISOFiles {
+		used[iso.Device] = true
+	}
+
+	storagePool := c.CloudInitStoragePool
+	if storagePool == "" {
+		storagePool = c.Disks[0].StoragePool
+	}
+
+	for _, controller := range []string{"ide0", "ide1", "ide2", "ide3"} {
+		if used[controller] {
+			continue
+		}
+		params := map[string]interface{}{
+			controller: storagePool + ":cloudinit",
+		}
+		if user != "" {
+			params["ciuser"] = user
+		}
+		if password != "" {
+			params["cipassword"] = password
+		}
+		if publicKey != "" {
+			params["sshkeys"] = sshKeyURLEncode(publicKey)
+		}
+		return params
+	}
+	return nil
+}
+
+// sshKeyURLEncode encodes keys the way the Proxmox API expects for sshkeys,
+// matching what proxmox-api-go does for clones.
+func sshKeyURLEncode(keys string) string {
+	encodedKeys := url.PathEscape(strings.TrimSpace(keys) + "\n")
+	encodedKeys = strings.ReplaceAll(encodedKeys, "+", "%2B")
+	encodedKeys = strings.ReplaceAll(encodedKeys, "@", "%40")
+	encodedKeys = strings.ReplaceAll(encodedKeys, "=", "%3D")
+	encodedKeys = strings.ReplaceAll(encodedKeys, ":", "%3A")
+	return encodedKeys
+}
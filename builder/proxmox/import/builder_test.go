@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/fakepve"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloudInitParams(t *testing.T) {
+	cfg := mandatoryConfig(t)
+	cfg["disk_image_file"] = "local:import/debian-12-genericcloud-amd64.qcow2"
+	cfg["disks"] = []map[string]interface{}{
+		{"type": "ide", "storage_pool": "local-lvm"},
+	}
+	cfg["additional_iso_files"] = []map[string]interface{}{
+		{"device": "ide1", "iso_file": "local:iso/drivers.iso"},
+	}
+
+	var c Config
+	_, _, err := c.Prepare(&c, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := cloudInitParams(&c, "debian", "", "ssh-ed25519 AAAA packer")
+	if params["ide3"] != nil {
+		t.Errorf("expected first free controller to be used, got %v", params)
+	}
+	if params["ide2"] != "local-lvm:cloudinit" {
+		t.Errorf("expected cloud-init drive on ide2 in disk storage pool, got %v", params["ide2"])
+	}
+	if params["ciuser"] != "debian" {
+		t.Errorf("expected ciuser to be set, got %v", params["ciuser"])
+	}
+	if _, ok := params["cipassword"]; ok {
+		t.Errorf("expected cipassword not to be set without a password")
+	}
+	if params["sshkeys"] != "ssh-ed25519%20AAAA%20packer%0A" {
+		t.Errorf("expected encoded sshkeys, got %v", params["sshkeys"])
+	}
+}
+
+func fakeClusterConfig(srv *fakepve.Server) map[string]interface{} {
+	return map[string]interface{}{
+		"proxmox_url":     srv.APIURL(),
+		"username":        "root@pam!packer",
+		"token":           "xxxx-xxxx-xxxx-xxxx",
+		"node":            "pve",
+		"disk_image_file": "local:import/debian-12-genericcloud-amd64.qcow2",
+		"communicator":    "none",
+		"template_name":   "debian-12-cloud",
+		"disks": []map[string]interface{}{
+			{
+				"type":         "scsi",
+				"disk_size":    "20G",
+				"storage_pool": "local-lvm",
+			},
+		},
+	}
+}
+
+func fakeCluster(t *testing.T) *fakepve.Server {
+	cluster, err := fakepve.Fixture("single-node")
+	require.NoError(t, err)
+	local := &cluster.Nodes[0].Storage[0]
+	local.Volumes = append(local.Volumes, "local:import/debian-12-genericcloud-amd64.qcow2")
+	return fakepve.NewServer(cluster)
+}
+
+func TestBuilderRun(t *testing.T) {
+	srv := fakeCluster(t)
+	defer srv.Close()
+
+	var b Builder
+	_, _, err := b.Prepare(fakeClusterConfig(srv))
+	require.NoError(t, err)
+
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	artifact, err := b.Run(context.Background(), ui, &packersdk.MockHook{})
+	require.NoError(t, err)
+	require.Equal(t, "100", artifact.Id())
+
+	vm, ok := srv.VM(100)
+	require.True(t, ok)
+	require.True(t, vm.Template)
+	require.Contains(t, vm.Config["scsi0"], "import-from=local:import/debian-12-genericcloud-amd64.qcow2")
+}
+
+func TestBuilderRunDeletesVMOnResizeFailure(t *testing.T) {
+	srv := fakeCluster(t)
+	defer srv.Close()
+
+	var b Builder
+	_, _, err := b.Prepare(fakeClusterConfig(srv))
+	require.NoError(t, err)
+
+	srv.FailTask("PUT", "/nodes/pve/qemu/100/resize", "can't lock file")
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	_, err = b.Run(context.Background(), ui, &packersdk.MockHook{})
+	require.ErrorContains(t, err, "can't lock file")
+
+	_, ok := srv.VM(100)
+	require.False(t, ok, "VM of the failed build should be deleted")
+}
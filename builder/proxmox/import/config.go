@@ -0,0 +1,120 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package proxmoximport
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	proxmoxcommon "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type Config struct {
+	proxmoxcommon.Config `mapstructure:",squash"`
+
+	DiskImageURLs         []string `mapstructure:"disk_image_urls"`
+	RawSingleDiskImageURL string   `mapstructure:"disk_image_url"`
+	DiskImageChecksum     string   `mapstructure:"disk_image_checksum"`
+	DiskImageFile         string   `mapstructure:"disk_image_file"`
+	DiskImageStoragePool  string   `mapstructure:"disk_image_storage_pool"`
+	DiskImageFormat       string   `mapstructure:"disk_image_format"`
+	shouldUploadImage     bool
+}
+
+func (c *Config) Prepare(raws ...interface{}) ([]string, []string, error) {
+	var errs *packersdk.MultiError
+	generatedData, warnings, merrs := c.Config.Prepare(c, raws...)
+	if merrs != nil {
+		errs = packersdk.MultiErrorAppend(errs, merrs)
+	}
+
+	if c.RawSingleDiskImageURL != "" {
+		c.DiskImageURLs = append([]string{c.RawSingleDiskImageURL}, c.DiskImageURLs...)
+	}
+
+	// Check disk image config
+	// Either a pre-uploaded image should be referenced in disk_image_file, OR
+	// a URL (possibly to a local file) to an image that will be downloaded and
+	// then uploaded to Proxmox.
+	if (c.DiskImageFile == "" && len(c.DiskImageURLs) == 0) || (c.DiskImageFile != "" && len(c.DiskImageURLs) != 0) {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("either disk_image_file or disk_image_url, but not both, must be specified"))
+	}
+	c.shouldUploadImage = len(c.DiskImageURLs) != 0
+	if c.shouldUploadImage {
+		if c.DiskImageStoragePool == "" {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("when specifying disk_image_url, disk_image_storage_pool must also be specified"))
+		}
+		if c.DiskImageChecksum == "" {
+			errs = packersdk.MultiErrorAppend(errs, errors.New("a checksum must be specified with disk_image_checksum, use \"none\" to skip verification"))
+		}
+		// Proxmox reads the format of import volumes from their extension
+		if c.DiskImageFormat == "" {
+			c.DiskImageFormat = strings.TrimPrefix(path.Ext(c.DiskImageURLs[0]), ".")
+			if !importFormats[c.DiskImageFormat] {
+				c.DiskImageFormat = ""
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("disk_image_format must be specified, the format of %s can't be told from its extension", path.Base(c.DiskImageURLs[0])))
+			}
+		} else if !importFormats[c.DiskImageFormat] {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("disk_image_format must be qcow2, raw or vmdk, not %q", c.DiskImageFormat))
+		}
+	} else if strings.Contains(c.DiskImageFile, ":iso/") {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("disk_image_file %s must be an import or disk image volume, Proxmox doesn't import from iso volumes", c.DiskImageFile))
+	}
+
+	// The image is imported into the first configured disk, which also
+	// defines the bus, storage pool and minimum size of the boot disk.
+	if len(c.Disks) == 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("at least one disk must be specified, the disk image is imported as the first disk"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, warnings, errs
+	}
+	return generatedData, warnings, nil
+}
+
+// importFormats are the disk image formats Proxmox accepts for volumes of
+// the import content type.
+var importFormats = map[string]bool{
+	"qcow2": true,
+	"raw":   true,
+	"vmdk":  true,
+}
+
+// imageFile returns the path of the disk image on Proxmox storage, matching
+// the name it gets when uploaded.
+func (c *Config) imageFile() string {
+	if !c.shouldUploadImage {
+		return c.DiskImageFile
+	}
+	return fmt.Sprintf("%s:import/%s", c.DiskImageStoragePool, c.imageFilename())
+}
+
+// imageFilename returns the name the downloaded image is stored as on the
+// Proxmox storage. Proxmox reads the format of import volumes from their
+// extension, so the one of disk_image_format is appended if it's missing.
+func (c *Config) imageFilename() string {
+	filename := path.Base(c.DiskImageURLs[0])
+	if path.Ext(filename) != "."+c.DiskImageFormat {
+		filename = filename + "." + c.DiskImageFormat
+	}
+	return filename
+}
+
+// lineageOrigin returns the disk image the template is imported from, for
+// its entry in the lineage.
+func (c *Config) lineageOrigin() map[string]string {
+	if !c.shouldUploadImage {
+		return map[string]string{"disk_image_file": c.DiskImageFile}
+	}
+	return map[string]string{
+		"disk_image_url":      c.DiskImageURLs[0],
+		"disk_image_checksum": c.DiskImageChecksum,
+	}
+}
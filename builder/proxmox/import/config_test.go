@@ -0,0 +1,173 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"testing"
+)
+
+func mandatoryConfig(t *testing.T) map[string]interface{} {
+	return map[string]interface{}{
+		"proxmox_url":  "https://my-proxmox.my-domain:8006/api2/json",
+		"username":     "apiuser@pve",
+		"token":        "xxxx-xxxx-xxxx-xxxx",
+		"node":         "my-proxmox",
+		"ssh_username": "debian",
+		"disks": []map[string]interface{}{
+			{
+				"type":         "virtio",
+				"storage_pool": "local-lvm",
+				"disk_size":    "20G",
+			},
+		},
+	}
+}
+
+func TestDiskImageSource(t *testing.T) {
+	cs := []struct {
+		name          string
+		config        map[string]interface{}
+		expectFailure bool
+		expectUpload  bool
+	}{
+		{
+			name: "pre-uploaded disk_image_file, no error",
+			config: map[string]interface{}{
+				"disk_image_file": "local:import/debian-12-genericcloud-amd64.qcow2",
+			},
+			expectFailure: false,
+			expectUpload:  false,
+		},
+		{
+			name: "disk_image_url with checksum and storage pool, no error",
+			config: map[string]interface{}{
+				"disk_image_url":          "https://cloud.debian.org/images/cloud/bookworm/latest/debian-12-genericcloud-amd64.qcow2",
+				"disk_image_checksum":     "file:https://cloud.debian.org/images/cloud/bookworm/latest/SHA512SUMS",
+				"disk_image_storage_pool": "local",
+			},
+			expectFailure: false,
+			expectUpload:  true,
+		},
+		{
+			name: "disk_image_url with unknown extension, fail",
+			config: map[string]interface{}{
+				"disk_image_url":          "https://cloud-images.ubuntu.com/jammy/current/jammy-server-cloudimg-amd64.img",
+				"disk_image_checksum":     "none",
+				"disk_image_storage_pool": "local",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "disk_image_url with unknown extension and disk_image_format, no error",
+			config: map[string]interface{}{
+				"disk_image_url":          "https://cloud-images.ubuntu.com/jammy/current/jammy-server-cloudimg-amd64.img",
+				"disk_image_checksum":     "none",
+				"disk_image_storage_pool": "local",
+				"disk_image_format":       "qcow2",
+			},
+			expectFailure: false,
+			expectUpload:  true,
+		},
+		{
+			name: "unsupported disk_image_format, fail",
+			config: map[string]interface{}{
+				"disk_image_url":          "https://example.com/appliance.vdi",
+				"disk_image_checksum":     "none",
+				"disk_image_storage_pool": "local",
+				"disk_image_format":       "vdi",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "disk_image_file on iso storage, fail",
+			config: map[string]interface{}{
+				"disk_image_file": "local:iso/debian-12-genericcloud-amd64.img",
+			},
+			expectFailure: true,
+		},
+		{
+			name:          "no disk image given, fail",
+			config:        map[string]interface{}{},
+			expectFailure: true,
+		},
+		{
+			name: "both disk_image_file and disk_image_url given, fail",
+			config: map[string]interface{}{
+				"disk_image_file":         "local:import/debian-12-genericcloud-amd64.qcow2",
+				"disk_image_url":          "https://cloud.debian.org/images/cloud/bookworm/latest/debian-12-genericcloud-amd64.qcow2",
+				"disk_image_checksum":     "none",
+				"disk_image_storage_pool": "local",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "disk_image_url without storage pool, fail",
+			config: map[string]interface{}{
+				"disk_image_url":      "https://cloud.debian.org/images/cloud/bookworm/latest/debian-12-genericcloud-amd64.qcow2",
+				"disk_image_checksum": "none",
+			},
+			expectFailure: true,
+		},
+		{
+			name: "disk_image_url without checksum, fail",
+			config: map[string]interface{}{
+				"disk_image_url":          "https://cloud.debian.org/images/cloud/bookworm/latest/debian-12-genericcloud-amd64.qcow2",
+				"disk_image_storage_pool": "local",
+			},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range cs {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.config {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Errorf("expected failure, but prepare succeeded")
+			}
+			if err == nil && c.shouldUploadImage != tt.expectUpload {
+				t.Errorf("expected shouldUploadImage to be %t, got %t", tt.expectUpload, c.shouldUploadImage)
+			}
+		})
+	}
+}
+
+func TestDisksRequired(t *testing.T) {
+	cfg := mandatoryConfig(t)
+	delete(cfg, "disks")
+	cfg["disk_image_file"] = "local:import/debian-12-genericcloud-amd64.qcow2"
+
+	var c Config
+	_, _, err := c.Prepare(&c, cfg)
+	if err == nil {
+		t.Fatal("expected configuration without disks to fail")
+	}
+}
+
+func TestImageFilename(t *testing.T) {
+	cs := []struct {
+		url      string
+		format   string
+		expected string
+	}{
+		{url: "https://example.com/images/jammy-server-cloudimg-amd64.img", format: "qcow2", expected: "jammy-server-cloudimg-amd64.img.qcow2"},
+		{url: "https://example.com/images/debian-12-genericcloud-amd64.qcow2", format: "qcow2", expected: "debian-12-genericcloud-amd64.qcow2"},
+		{url: "./appliance-disk1.vmdk", format: "vmdk", expected: "appliance-disk1.vmdk"},
+	}
+
+	for _, tt := range cs {
+		c := &Config{DiskImageURLs: []string{tt.url}, DiskImageFormat: tt.format}
+		if got := c.imageFilename(); got != tt.expected {
+			t.Errorf("expected filename for %s to be %q, got %q", tt.url, tt.expected, got)
+		}
+	}
+}
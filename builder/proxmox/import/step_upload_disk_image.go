@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepUploadDiskImage uploads a disk image to Proxmox so it can be imported
+// as the boot disk of the VM. The uploaded image is removed again in Cleanup.
+type stepUploadDiskImage struct {
+	uploaded string
+}
+
+type uploader interface {
+	Upload(node string, storage string, contentType string, filename string, file io.Reader) error
+	DeleteVolume(vmr *proxmox.VmRef, storageName string, volumeName string) (exitStatus interface{}, err error)
+}
+
+var _ uploader = &proxmox.Client{}
+
+func (s *stepUploadDiskImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(uploader)
+	c := state.Get("import-config").(*Config)
+
+	if !c.shouldUploadImage {
+		state.Put("disk_image_file", c.DiskImageFile)
+		return multistep.ActionContinue
+	}
+
+	p := state.Get(downloadPathKey).(string)
+	if p == "" {
+		err := fmt.Errorf("path to downloaded disk image was empty")
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// All failure cases in resolving the symlink are caught anyway in os.Open
+	imagePath, _ := filepath.EvalSymlinks(p)
+	r, err := os.Open(imagePath)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	defer r.Close()
+
+	filename := c.imageFilename()
+	ui.Say(fmt.Sprintf("Uploading disk image %s to %s", filename, c.DiskImageStoragePool))
+	err = client.Upload(c.Node, c.DiskImageStoragePool, "import", filename, r)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	imageStoragePath := c.imageFile()
+	s.uploaded = imageStoragePath
+	state.Put("disk_image_file", imageStoragePath)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepUploadDiskImage) Cleanup(state multistep.StateBag) {
+	if s.uploaded == "" {
+		return
+	}
+	c := state.Get("import-config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(uploader)
+
+	// Fake a VM reference, DeleteVolume just needs the node to be valid
+	vmRef := &proxmox.VmRef{}
+	vmRef.SetNode(c.Node)
+	vmRef.SetVmType("qemu")
+
+	_, err := client.DeleteVolume(vmRef, c.DiskImageStoragePool, s.uploaded)
+	if err != nil {
+		ui.Error(fmt.Sprintf("delete volume failed: %s", err.Error()))
+		return
+	}
+	ui.Message(fmt.Sprintf("Deleted uploaded disk image %s", s.uploaded))
+}
@@ -0,0 +1,155 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoximport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type uploaderMock struct {
+	fail      bool
+	wasCalled bool
+	deleted   []string
+}
+
+func (m *uploaderMock) Upload(node string, storage string, contentType string, filename string, file io.Reader) error {
+	m.wasCalled = true
+	if m.fail {
+		return fmt.Errorf("Testing induced failure")
+	}
+	return nil
+}
+
+func (m *uploaderMock) DeleteVolume(vmr *proxmox.VmRef, storageName string, volumeName string) (interface{}, error) {
+	m.deleted = append(m.deleted, volumeName)
+	return nil, nil
+}
+
+var _ uploader = &uploaderMock{}
+
+func TestUploadDiskImage(t *testing.T) {
+	cs := []struct {
+		name          string
+		builderConfig *Config
+		downloadPath  string
+		failUpload    bool
+
+		expectError        bool
+		expectUploadCalled bool
+		expectDeleted      []string
+		expectedImagePath  string
+		expectedAction     multistep.StepAction
+	}{
+		{
+			name:          "should not call upload unless configured to do so",
+			builderConfig: &Config{shouldUploadImage: false, DiskImageFile: "local:iso/some-file.img"},
+
+			expectUploadCalled: false,
+			expectedImagePath:  "local:iso/some-file.img",
+			expectedAction:     multistep.ActionContinue,
+		},
+		{
+			name: "success should continue",
+			builderConfig: &Config{
+				shouldUploadImage:    true,
+				DiskImageStoragePool: "local",
+				DiskImageURLs:        []string{"http://server.example/some-file.qcow2"},
+				DiskImageFormat:      "qcow2",
+			},
+			downloadPath: "testdata/test.img",
+
+			expectedImagePath:  "local:import/some-file.qcow2",
+			expectUploadCalled: true,
+			expectDeleted:      []string{"local:import/some-file.qcow2"},
+			expectedAction:     multistep.ActionContinue,
+		},
+		{
+			name: "failing upload should halt",
+			builderConfig: &Config{
+				shouldUploadImage:    true,
+				DiskImageStoragePool: "local",
+				DiskImageURLs:        []string{"http://server.example/some-file.qcow2"},
+				DiskImageFormat:      "qcow2",
+			},
+			downloadPath: "testdata/test.img",
+			failUpload:   true,
+
+			expectError:        true,
+			expectUploadCalled: true,
+			expectedAction:     multistep.ActionHalt,
+		},
+		{
+			name: "downloader: state misconfiguration should halt",
+			builderConfig: &Config{
+				shouldUploadImage:    true,
+				DiskImageStoragePool: "local",
+				DiskImageURLs:        []string{"http://server.example/some-file.qcow2"},
+				DiskImageFormat:      "qcow2",
+			},
+
+			expectError:        true,
+			expectUploadCalled: false,
+			expectedAction:     multistep.ActionHalt,
+		},
+		{
+			name: "downloader: file unreadable should halt",
+			builderConfig: &Config{
+				shouldUploadImage:    true,
+				DiskImageStoragePool: "local",
+				DiskImageURLs:        []string{"http://server.example/some-file.qcow2"},
+				DiskImageFormat:      "qcow2",
+			},
+			downloadPath: "testdata/non-existent.img",
+
+			expectError:        true,
+			expectUploadCalled: false,
+			expectedAction:     multistep.ActionHalt,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			m := &uploaderMock{fail: c.failUpload}
+
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("import-config", c.builderConfig)
+			state.Put(downloadPathKey, c.downloadPath)
+			state.Put("proxmoxClient", m)
+
+			step := stepUploadDiskImage{}
+			action := step.Run(context.TODO(), state)
+			step.Cleanup(state)
+
+			if action != c.expectedAction {
+				t.Errorf("Expected action to be %v, got %v", c.expectedAction, action)
+			}
+			if m.wasCalled != c.expectUploadCalled {
+				t.Errorf("Expected mock to be called: %v, got: %v", c.expectUploadCalled, m.wasCalled)
+			}
+			if fmt.Sprint(m.deleted) != fmt.Sprint(c.expectDeleted) {
+				t.Errorf("Expected deleted volumes %v, got %v", c.expectDeleted, m.deleted)
+			}
+			err, gotError := state.GetOk("error")
+			if gotError != c.expectError {
+				t.Errorf("Expected error state to be: %v, got: %v", c.expectError, gotError)
+			}
+			if err == nil {
+				if imagePath := state.Get("disk_image_file"); imagePath != c.expectedImagePath {
+					if _, ok := imagePath.(string); !ok {
+						imagePath = ""
+					}
+					t.Errorf("Expected state disk_image_file to be %q, got %q", c.expectedImagePath, imagePath)
+				}
+			}
+		})
+	}
+}
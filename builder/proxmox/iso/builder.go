@@ -5,6 +5,7 @@ package proxmoxiso
 
 import (
 	"context"
+	"fmt"
 
 	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
 	"github.com/hashicorp/hcl/v2/hcldec"
@@ -12,6 +13,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 // The unique id for the builder
@@ -35,25 +37,60 @@ const downloadPathKey = "downloaded_iso_path"
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
 	state := new(multistep.BasicStateBag)
 	state.Put("iso-config", &b.config)
+	state.Put("lineage_origin", b.config.lineageOrigin())
+	if b.config.ISOFile != "" {
+		state.Put("source_image", b.config.ISOFile)
+	} else {
+		state.Put("source_image", b.config.ISOUrls[0])
+	}
+	if b.config.shouldUploadISO || b.config.ISODownloadPVE {
+		state.Put("storage_requirements", []proxmox.StorageRequirement{
+			{Option: "iso_storage_pool", Pool: b.config.ISOStoragePool, Content: "iso"},
+		})
+	}
+	if b.config.ISODownloadPVE {
+		// downloading to the node allows probing the network of the node
+		state.Put("permission_requirements", []proxmox.PermissionRequirement{
+			{Path: "/", Privilege: "Sys.Modify"},
+		})
+	}
+
+	if b.config.DryRun {
+		state.Put("iso_file", b.config.isoFile())
+	}
 
 	preSteps := []multistep.Step{}
-	if b.config.ISODownloadPVE {
+	if b.config.Comm.Type == "ssh" && b.config.HTTPTemplatesUse(".SSHPublicKey") {
+		// The public key is available to http_content and http_templates,
+		// for installers to authorize it. Builds not using it connect with
+		// the credentials of the communicator config only.
 		preSteps = append(preSteps,
-			&stepDownloadISOOnPVE{},
-		)
-	} else {
-		preSteps = append(preSteps,
-			&commonsteps.StepDownload{
-				Checksum:    b.config.ISOChecksum,
-				Description: "ISO",
-				Extension:   b.config.TargetExtension,
-				ResultKey:   downloadPathKey,
-				TargetPath:  b.config.TargetPath,
-				Url:         b.config.ISOUrls,
+			&proxmox.StepSshKeyPair{
+				Debug:        b.config.PackerDebug,
+				DebugKeyPath: fmt.Sprintf("%s.pem", b.config.PackerBuildName),
 			},
-			&stepUploadISO{},
 		)
 	}
+	// A resumed VM already has the ISO attached
+	if b.config.ResumeFromSnapshot == "" {
+		if b.config.ISODownloadPVE {
+			preSteps = append(preSteps,
+				&stepDownloadISOOnPVE{},
+			)
+		} else {
+			preSteps = append(preSteps,
+				&commonsteps.StepDownload{
+					Checksum:    b.config.ISOChecksum,
+					Description: "ISO",
+					Extension:   b.config.TargetExtension,
+					ResultKey:   downloadPathKey,
+					TargetPath:  b.config.TargetPath,
+					Url:         b.config.ISOUrls,
+				},
+				&stepUploadISO{},
+			)
+		}
+	}
 
 	postSteps := []multistep.Step{
 		&stepFinalizeISOTemplate{},
@@ -65,9 +102,22 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 
 type isoVMCreator struct{}
 
-func (*isoVMCreator) Create(vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu, state multistep.StateBag) error {
+var _ proxmox.ProxmoxVMConfigurer = &isoVMCreator{}
+
+func (*isoVMCreator) Configure(config *proxmoxapi.ConfigQemu, state multistep.StateBag) error {
 	isoFile := state.Get("iso_file").(string)
 	config.QemuIso = isoFile
+	return nil
+}
+
+func (c *isoVMCreator) Create(vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu, state multistep.StateBag) error {
+	err := c.Configure(&config, state)
+	if err != nil {
+		return err
+	}
+
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("ISOFile", config.QemuIso)
 
 	client := state.Get("proxmoxClient").(*proxmoxapi.Client)
 	return config.CreateVm(vmRef, client)
@@ -0,0 +1,280 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxiso
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/fakepve"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	registryimage "github.com/hashicorp/packer-plugin-sdk/packer/registry/image"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeClusterConfig(srv *fakepve.Server) map[string]interface{} {
+	return map[string]interface{}{
+		"proxmox_url":   srv.APIURL(),
+		"username":      "root@pam!packer",
+		"token":         "xxxx-xxxx-xxxx-xxxx",
+		"node":          "pve",
+		"iso_file":      "local:iso/debian-12.iso",
+		"communicator":  "none",
+		"unmount_iso":   true,
+		"template_name": "debian-12",
+		"disks": []map[string]interface{}{
+			{
+				"type":         "scsi",
+				"disk_size":    "10G",
+				"storage_pool": "local-lvm",
+			},
+		},
+		"network_adapters": []map[string]interface{}{
+			{"bridge": "vmbr0"},
+		},
+	}
+}
+
+func TestBuilderRun(t *testing.T) {
+	cluster, err := fakepve.Fixture("single-node")
+	require.NoError(t, err)
+	srv := fakepve.NewServer(cluster)
+	defer srv.Close()
+
+	cfg := fakeClusterConfig(srv)
+	cfg["extra_config"] = map[string]string{"watchdog": "model=i6300esb"}
+	cfg["template_extra_config"] = map[string]string{"tags": "golden"}
+
+	var b Builder
+	generatedDataKeys, _, err := b.Prepare(cfg)
+	require.NoError(t, err)
+	require.Subset(t, generatedDataKeys, []string{"ProxmoxNode", "VMID", "VMName", "TemplateName", "ISOFile", "IPAddresses", "MACAddresses"})
+
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	artifact, err := b.Run(context.Background(), ui, &packersdk.MockHook{})
+	require.NoError(t, err)
+	require.Equal(t, "100", artifact.Id())
+	require.Equal(t, BuilderID, artifact.BuilderId())
+
+	vm, ok := srv.VM(100)
+	require.True(t, ok)
+	require.True(t, vm.Template)
+	require.Equal(t, "stopped", vm.Status)
+	require.Equal(t, "debian-12", vm.Name)
+	require.Equal(t, "none,media=cdrom", vm.Config["ide2"], "ISO should be unmounted")
+	require.Contains(t, vm.Config, "scsi0")
+	require.Equal(t, "model=i6300esb", vm.Config["watchdog"])
+	require.Equal(t, "golden", vm.Config["tags"])
+
+	require.Equal(t, map[string]string{
+		"vm_id":         "100",
+		"node":          "pve",
+		"template_name": "debian-12",
+		"storage":       "local-lvm",
+		"source_image":  "local:iso/debian-12.iso",
+	}, artifact.State("metadata"))
+
+	generatedData := artifact.State("generated_data").(map[string]interface{})
+	require.Equal(t, "pve", generatedData["ProxmoxNode"])
+	require.Equal(t, "100", generatedData["VMID"])
+	require.Equal(t, "debian-12", generatedData["TemplateName"])
+	require.Equal(t, "local:iso/debian-12.iso", generatedData["ISOFile"])
+	require.Equal(t, "192.0.2.10", generatedData["IPAddresses"])
+	require.Regexp(t, `^([0-9A-F]{2}:){5}[0-9A-F]{2}$`, generatedData["MACAddresses"])
+}
+
+func TestBuilderRunRegistryMetadata(t *testing.T) {
+	cluster, err := fakepve.Fixture("single-node")
+	require.NoError(t, err)
+	cluster.Name = "lab"
+	srv := fakepve.NewServer(cluster)
+	defer srv.Close()
+
+	cfg := fakeClusterConfig(srv)
+	cfg["pool"] = "templates"
+
+	var b Builder
+	_, _, err = b.Prepare(cfg)
+	require.NoError(t, err)
+
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	artifact, err := b.Run(context.Background(), ui, &packersdk.MockHook{})
+	require.NoError(t, err)
+
+	img, ok := artifact.State(registryimage.ArtifactStateURI).(*registryimage.Image)
+	require.True(t, ok)
+	require.NoError(t, img.Validate())
+	require.Equal(t, "proxmox", img.ProviderName)
+	require.Equal(t, "100", img.ImageID)
+	require.Equal(t, "lab", img.ProviderRegion)
+	require.Equal(t, "lab", img.Labels["cluster_name"])
+	require.Equal(t, "templates", img.Labels["pool"])
+	require.Equal(t, "local-lvm", img.Labels["storage"])
+}
+
+func TestBuilderRunCleansUpFailedBuild(t *testing.T) {
+	cluster, err := fakepve.Fixture("single-node")
+	require.NoError(t, err)
+	srv := fakepve.NewServer(cluster)
+	defer srv.Close()
+
+	var b Builder
+	_, _, err = b.Prepare(fakeClusterConfig(srv))
+	require.NoError(t, err)
+
+	srv.FailTask("POST", "/nodes/pve/qemu/100/template", "can't lock file")
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	_, err = b.Run(context.Background(), ui, &packersdk.MockHook{})
+	require.ErrorContains(t, err, "can't lock file")
+
+	_, ok := srv.VM(100)
+	require.False(t, ok, "VM of the failed build should be deleted")
+}
+
+func TestBuilderRunMissingPrivileges(t *testing.T) {
+	cluster, err := fakepve.Fixture("single-node")
+	require.NoError(t, err)
+	cluster.Permissions = map[string][]string{
+		"/": {"VM.Audit", "Datastore.Audit"},
+	}
+	srv := fakepve.NewServer(cluster)
+	defer srv.Close()
+
+	var b Builder
+	_, _, err = b.Prepare(fakeClusterConfig(srv))
+	require.NoError(t, err)
+
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	_, err = b.Run(context.Background(), ui, &packersdk.MockHook{})
+	require.ErrorContains(t, err, "VM.Allocate on /vms")
+	require.Empty(t, srv.Cluster().VMs[1:], "no VM should be created")
+}
+
+func TestBuilderRunResumeFromCheckpoint(t *testing.T) {
+	cluster, err := fakepve.Fixture("single-node")
+	require.NoError(t, err)
+	srv := fakepve.NewServer(cluster)
+	defer srv.Close()
+
+	iso := filepath.Join(t.TempDir(), "debian-12.iso")
+	require.NoError(t, os.WriteFile(iso, []byte("iso"), 0644))
+
+	cfg := fakeClusterConfig(srv)
+	delete(cfg, "iso_file")
+	cfg["iso_url"] = iso
+	cfg["iso_checksum"] = "none"
+	cfg["iso_storage_pool"] = "local"
+	cfg["vm_id"] = 100
+	cfg["checkpoints"] = true
+	cfg["packer_on_error"] = "abort"
+
+	var b Builder
+	_, _, err = b.Prepare(cfg)
+	require.NoError(t, err)
+
+	srv.FailTask("DELETE", "/nodes/pve/qemu/100/snapshot/packer-provisioned", "snapshot is locked")
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	hook := &packersdk.MockHook{}
+	_, err = b.Run(context.Background(), ui, hook)
+	require.ErrorContains(t, err, "snapshot is locked")
+	require.Equal(t, packersdk.HookProvision, hook.RunName)
+	require.Contains(t, srv.Requests(), "POST /nodes/pve/storage/local/upload")
+
+	vm, ok := srv.VM(100)
+	require.True(t, ok, "VM of the aborted build should be kept")
+	require.Len(t, vm.Snapshots, 2)
+
+	cfg["resume_from_snapshot"] = "packer-provisioned"
+	b = Builder{}
+	_, _, err = b.Prepare(cfg)
+	require.NoError(t, err)
+
+	previous := len(srv.Requests())
+	ui = &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	hook = &packersdk.MockHook{}
+	artifact, err := b.Run(context.Background(), ui, hook)
+	require.NoError(t, err)
+	require.Equal(t, "100", artifact.Id())
+	require.False(t, hook.RunCalled, "provisioners shouldn't run again after packer-provisioned")
+
+	requests := srv.Requests()[previous:]
+	require.Contains(t, requests, "POST /nodes/pve/qemu/100/snapshot/packer-provisioned/rollback")
+	require.NotContains(t, requests, "POST /nodes/pve/qemu", "no new VM should be created")
+	require.NotContains(t, requests, "POST /nodes/pve/storage/local/upload", "the ISO shouldn't be uploaded again")
+
+	vm, _ = srv.VM(100)
+	require.True(t, vm.Template)
+	require.Empty(t, vm.Snapshots, "checkpoints should be deleted before the conversion")
+}
+
+func TestBuilderRunKeepVMOnFailure(t *testing.T) {
+	cluster, err := fakepve.Fixture("single-node")
+	require.NoError(t, err)
+	local := &cluster.Nodes[0].Storage[0]
+	local.Volumes = append(local.Volumes, "local:snippets/hook.pl")
+	srv := fakepve.NewServer(cluster)
+	defer srv.Close()
+
+	cfg := fakeClusterConfig(srv)
+	cfg["keep_vm_on_failure"] = true
+	cfg["vm_name"] = "debian-build"
+	cfg["hookscript"] = map[string]interface{}{
+		"volume": "local:snippets/hook.pl",
+	}
+
+	var b Builder
+	_, _, err = b.Prepare(cfg)
+	require.NoError(t, err)
+
+	srv.FailTask("POST", "/nodes/pve/qemu/100/template", "can't lock file")
+	out := new(bytes.Buffer)
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: out, ErrorWriter: new(bytes.Buffer)}
+	_, err = b.Run(context.Background(), ui, &packersdk.MockHook{})
+	require.ErrorContains(t, err, "can't lock file")
+
+	vm, ok := srv.VM(100)
+	require.True(t, ok, "VM of the failed build should be kept")
+	require.Regexp(t, `^debian-build-failed-\d{8}-\d{6}$`, vm.Config["name"])
+	require.Equal(t, "packer-failed", vm.Config["tags"])
+	require.Contains(t, vm.Config["description"], "failed in stepConvertToTemplate")
+	require.Equal(t, "local:snippets/hook.pl", vm.Config["hookscript"])
+	require.Contains(t, out.String(), "VM ID: 100")
+	require.Contains(t, out.String(), "Node: pve")
+}
+
+func TestBuilderRunMACAddressHook(t *testing.T) {
+	cluster, err := fakepve.Fixture("single-node")
+	require.NoError(t, err)
+	srv := fakepve.NewServer(cluster)
+	defer srv.Close()
+
+	hookLog := filepath.Join(t.TempDir(), "hook.log")
+	cfg := fakeClusterConfig(srv)
+	cfg["packer_build_name"] = "debian"
+	cfg["network_adapters"] = []map[string]interface{}{
+		{"bridge": "vmbr0", "mac_address": "auto-deterministic", "reserved_ip_address": "192.0.2.50"},
+	}
+	cfg["mac_address_hook"] = []string{"sh", "-c", `echo "$@" >> ` + hookLog, "dhcp-reserve"}
+
+	var b Builder
+	_, _, err = b.Prepare(cfg)
+	require.NoError(t, err)
+
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer), ErrorWriter: new(bytes.Buffer)}
+	_, err = b.Run(context.Background(), ui, &packersdk.MockHook{})
+	require.NoError(t, err)
+
+	vm, ok := srv.VM(100)
+	require.True(t, ok)
+	mac := regexp.MustCompile(`^e1000=(02:50:4B(:[0-9A-F]{2}){3}),`).FindStringSubmatch(vm.Config["net0"].(string))
+	require.NotNil(t, mac, "net0 should have a MAC address in the prefix, got %s", vm.Config["net0"])
+
+	calls, err := os.ReadFile(hookLog)
+	require.NoError(t, err)
+	require.Equal(t, "register "+mac[1]+" 192.0.2.50\nderegister "+mac[1]+" 192.0.2.50\n", string(calls))
+}
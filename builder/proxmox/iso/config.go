@@ -9,6 +9,7 @@ import (
 	"context"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"path"
 
 	"github.com/Telmate/proxmox-api-go/proxmox"
@@ -31,7 +32,7 @@ type Config struct {
 
 func (c *Config) Prepare(raws ...interface{}) ([]string, []string, error) {
 	var errs *packersdk.MultiError
-	_, warnings, merrs := c.Config.Prepare(c, raws...)
+	generatedData, warnings, merrs := c.Config.Prepare(c, raws...)
 	if merrs != nil {
 		errs = packersdk.MultiErrorAppend(errs, merrs)
 	}
@@ -61,7 +62,28 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, []string, error) {
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, warnings, errs
 	}
-	return nil, warnings, nil
+	return append(generatedData, "ISOFile"), warnings, nil
+}
+
+// isoFile returns the path of the ISO on Proxmox storage, matching the name it
+// gets when uploaded or downloaded to the PVE node.
+func (c *Config) isoFile() string {
+	if !c.shouldUploadISO {
+		return c.ISOFile
+	}
+	return fmt.Sprintf("%s:iso/%s", c.ISOStoragePool, path.Base(c.ISOUrls[0]))
+}
+
+// lineageOrigin returns the ISO the template is installed from, for its
+// entry in the lineage.
+func (c *Config) lineageOrigin() map[string]string {
+	if c.ISOFile != "" {
+		return map[string]string{"iso_file": c.ISOFile}
+	}
+	return map[string]string{
+		"iso_url":      c.ISOUrls[0],
+		"iso_checksum": c.ISOChecksum,
+	}
 }
 
 // Take ISOConfig configuration attributes in the format defined for packer-plugin-sdk
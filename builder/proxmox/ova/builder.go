@@ -0,0 +1,186 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"context"
+	"fmt"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The unique id for the builder
+const BuilderID = "proxmox.ova"
+
+type Builder struct {
+	config Config
+}
+
+// Builder implements packersdk.Builder
+var _ packersdk.Builder = &Builder{}
+
+func (b *Builder) ConfigSpec() hcldec.ObjectSpec { return b.config.FlatMapstructure().HCL2Spec() }
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
+	return b.config.Prepare(raws...)
+}
+
+const downloadPathKey = "downloaded_ova_path"
+
+func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	state := new(multistep.BasicStateBag)
+	state.Put("ova-config", &b.config)
+	state.Put("source_image", b.config.OVAURLs[0])
+	state.Put("lineage_origin", map[string]string{
+		"ova_url":      b.config.OVAURLs[0],
+		"ova_checksum": b.config.OVAChecksum,
+	})
+	// Disk sizes are only known once the OVA is extracted, they are checked
+	// by stepValidateOVAStorage
+	state.Put("storage_requirements", []proxmox.StorageRequirement{
+		{Option: "disk_image_storage_pool", Pool: b.config.DiskImageStoragePool, Content: "import"},
+		{Option: "storage_pool", Pool: b.config.StoragePool, Content: "images"},
+	})
+
+	// The hardware of the VM is read from the OVF descriptor, so the OVA is
+	// downloaded and extracted in dry runs as well
+	extractSteps := []multistep.Step{
+		&commonsteps.StepDownload{
+			Checksum:    b.config.OVAChecksum,
+			Description: "OVA",
+			Extension:   "ova",
+			ResultKey:   downloadPathKey,
+			Url:         b.config.OVAURLs,
+		},
+		&stepExtractOVA{},
+	}
+	// A resumed VM already has its disks imported
+	var preSteps []multistep.Step
+	if b.config.ResumeFromSnapshot == "" {
+		preSteps = append(append(preSteps, extractSteps...),
+			&stepValidateOVAStorage{},
+			&stepUploadOVADisks{},
+		)
+	}
+	postSteps := []multistep.Step{}
+
+	sb := proxmox.NewSharedBuilder(BuilderID, b.config.Config, preSteps, postSteps, &ovaVMCreator{dryRunSteps: extractSteps})
+	return sb.Run(ctx, ui, hook, state)
+}
+
+type ovaVMCreator struct {
+	dryRunSteps []multistep.Step
+}
+
+var _ proxmox.ProxmoxVMConfigurer = &ovaVMCreator{}
+var _ proxmox.ProxmoxDryRunPreparer = &ovaVMCreator{}
+
+func (oc *ovaVMCreator) DryRunSteps() []multistep.Step {
+	return oc.dryRunSteps
+}
+
+func (*ovaVMCreator) Configure(config *proxmoxapi.ConfigQemu, state multistep.StateBag) error {
+	c := state.Get("ova-config").(*Config)
+	hw := state.Get("ovf_hardware").(*ovfHardware)
+
+	applyOVFHardware(config, hw, c.Bridge)
+	return nil
+}
+
+func (oc *ovaVMCreator) Create(vmRef *proxmoxapi.VmRef, config proxmoxapi.ConfigQemu, state multistep.StateBag) error {
+	client := state.Get("proxmoxClient").(*proxmoxapi.Client)
+	c := state.Get("ova-config").(*Config)
+	hw := state.Get("ovf_hardware").(*ovfHardware)
+	diskFiles := state.Get("ova_disk_files").([]string)
+
+	err := oc.Configure(&config, state)
+	if err != nil {
+		return err
+	}
+	err = config.CreateVm(vmRef, client)
+	if err != nil {
+		return err
+	}
+	// The VM is deleted by stepStartVM's cleanup if importing the disks fails
+	state.Put("vmRef", vmRef)
+
+	// Disks are imported in a separate update, as proxmox-api-go derives the
+	// device name of QemuDisks from their index, which doesn't allow for disks
+	// on multiple buses.
+	_, err = client.SetVmConfig(vmRef, ovfDiskParams(hw, diskFiles, c.StoragePool))
+	if err != nil {
+		return fmt.Errorf("error importing disks: %s", err)
+	}
+	// Images can be smaller than the capacity of the disk in the appliance,
+	// like sparse or stream optimized VMDKs
+	for _, disk := range hw.Disks {
+		if disk.SizeBytes == 0 {
+			continue
+		}
+		err = proxmox.GrowDisk(client, vmRef, disk.Device, ovfDiskSize(disk.SizeBytes))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ovfDiskSize formats a disk capacity in bytes as a Proxmox disk size,
+// rounded up to full megabytes.
+func ovfDiskSize(sizeBytes int64) string {
+	return fmt.Sprintf("%dM", (sizeBytes+(1<<20)-1)>>20)
+}
+
+// applyOVFHardware overrides the VM configuration with the virtual hardware
+// of the appliance. Network adapters from the OVF descriptor are attached to
+// bridge, unless network_adapters are configured explicitly, in which case
+// bridge is empty.
+func applyOVFHardware(config *proxmoxapi.ConfigQemu, hw *ovfHardware, bridge string) {
+	config.Memory = hw.MemoryMB
+	config.QemuSockets = 1
+	config.QemuCores = hw.CPUs
+	if hw.CoresPerSocket > 0 && hw.CPUs%hw.CoresPerSocket == 0 {
+		config.QemuSockets = hw.CPUs / hw.CoresPerSocket
+		config.QemuCores = hw.CoresPerSocket
+	}
+	if hw.OSType != "" {
+		config.QemuOs = hw.OSType
+	}
+	if hw.SCSIController != "" {
+		config.Scsihw = hw.SCSIController
+	}
+	if hw.EFI && config.Bios == "" {
+		config.Bios = "ovmf"
+	}
+	if config.Boot == "" {
+		config.Boot = "order=" + hw.Disks[0].Device
+	}
+
+	config.QemuDisks = proxmoxapi.QemuDevices{}
+	if bridge != "" {
+		config.QemuNetworks = proxmoxapi.QemuDevices{}
+		for idx, model := range hw.NICModels {
+			config.QemuNetworks[idx] = proxmoxapi.QemuDevice{
+				"model":  model,
+				"bridge": bridge,
+			}
+		}
+	}
+}
+
+// ovfDiskParams returns the VM config parameters importing every uploaded
+// disk into storagePool. A size of 0 tells Proxmox to use the size of the
+// source image.
+func ovfDiskParams(hw *ovfHardware, diskFiles []string, storagePool string) map[string]interface{} {
+	params := map[string]interface{}{}
+	for idx, disk := range hw.Disks {
+		params[disk.Device] = fmt.Sprintf("%s:0,import-from=%s", storagePool, diskFiles[idx])
+	}
+	return params
+}
@@ -0,0 +1,118 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testHardware() *ovfHardware {
+	return &ovfHardware{
+		OSType:         "win10",
+		CPUs:           4,
+		CoresPerSocket: 2,
+		MemoryMB:       8192,
+		EFI:            true,
+		SCSIController: "pvscsi",
+		Disks: []ovfHardwareDisk{
+			{Device: "scsi0", File: "disk1.vmdk", SizeBytes: 20 << 30},
+			{Device: "sata0", File: "disk2.vmdk", SizeBytes: 512 << 20},
+		},
+		NICModels: []string{"vmxnet3"},
+	}
+}
+
+func TestApplyOVFHardware(t *testing.T) {
+	config := proxmoxapi.ConfigQemu{
+		Memory:       512,
+		QemuCores:    1,
+		QemuSockets:  1,
+		QemuOs:       "other",
+		Scsihw:       "lsi",
+		QemuDisks:    proxmoxapi.QemuDevices{0: {"type": "scsi", "storage": "local-lvm"}},
+		QemuNetworks: proxmoxapi.QemuDevices{},
+	}
+
+	applyOVFHardware(&config, testHardware(), "vmbr1")
+
+	assert.Equal(t, 8192, config.Memory)
+	assert.Equal(t, 2, config.QemuSockets)
+	assert.Equal(t, 2, config.QemuCores)
+	assert.Equal(t, "win10", config.QemuOs)
+	assert.Equal(t, "pvscsi", config.Scsihw)
+	assert.Equal(t, "ovmf", config.Bios)
+	assert.Equal(t, "order=scsi0", config.Boot)
+	assert.Empty(t, config.QemuDisks)
+	assert.Equal(t, proxmoxapi.QemuDevices{0: {"model": "vmxnet3", "bridge": "vmbr1"}}, config.QemuNetworks)
+}
+
+func TestApplyOVFHardwareKeepsExplicitSettings(t *testing.T) {
+	nics := proxmoxapi.QemuDevices{0: {"model": "virtio", "bridge": "vmbr0"}}
+	config := proxmoxapi.ConfigQemu{
+		Bios:         "seabios",
+		Boot:         "order=sata0",
+		QemuNetworks: nics,
+	}
+
+	applyOVFHardware(&config, testHardware(), "")
+
+	assert.Equal(t, "seabios", config.Bios)
+	assert.Equal(t, "order=sata0", config.Boot)
+	assert.Equal(t, nics, config.QemuNetworks)
+}
+
+func TestOVFDiskParams(t *testing.T) {
+	params := ovfDiskParams(testHardware(), []string{"local:import/vm-disk1.vmdk", "local:import/vm-disk2.vmdk"}, "local-lvm")
+
+	expected := map[string]interface{}{
+		"scsi0": "local-lvm:0,import-from=local:import/vm-disk1.vmdk",
+		"sata0": "local-lvm:0,import-from=local:import/vm-disk2.vmdk",
+	}
+	assert.Equal(t, expected, params)
+}
+
+func TestConfigure(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("ova-config", &Config{Bridge: "vmbr1"})
+	state.Put("ovf_hardware", testHardware())
+
+	config := proxmoxapi.ConfigQemu{Memory: 512, QemuOs: "other"}
+	err := (&ovaVMCreator{}).Configure(&config, state)
+	require.NoError(t, err)
+
+	assert.Equal(t, 8192, config.Memory)
+	assert.Equal(t, "win10", config.QemuOs)
+	assert.Equal(t, proxmoxapi.QemuDevices{0: {"model": "vmxnet3", "bridge": "vmbr1"}}, config.QemuNetworks)
+}
+
+func TestOVFDiskSize(t *testing.T) {
+	assert.Equal(t, "20480M", ovfDiskSize(20<<30))
+	assert.Equal(t, "2M", ovfDiskSize(1<<20+1))
+}
+
+func TestOVAStorageRequirements(t *testing.T) {
+	dir := t.TempDir()
+	var diskPaths []string
+	for _, name := range []string{"disk1.vmdk", "disk2.vmdk"} {
+		diskPath := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(diskPath, make([]byte, 1<<20), 0600))
+		diskPaths = append(diskPaths, diskPath)
+	}
+
+	c := &Config{DiskImageStoragePool: "local", StoragePool: "local-lvm"}
+	requirements, err := ovaStorageRequirements(c, testHardware(), diskPaths)
+	require.NoError(t, err)
+	require.Len(t, requirements, 2)
+	assert.Equal(t, "local", requirements[0].Pool)
+	assert.InDelta(t, 2.0/1024, requirements[0].SizeGB, 1e-9)
+	assert.Equal(t, "local-lvm", requirements[1].Pool)
+	assert.InDelta(t, 20.5, requirements[1].SizeGB, 1e-9)
+}
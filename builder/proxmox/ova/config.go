@@ -0,0 +1,65 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package proxmoxova
+
+import (
+	"errors"
+	"log"
+
+	proxmoxcommon "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type Config struct {
+	proxmoxcommon.Config `mapstructure:",squash"`
+
+	OVAURLs              []string `mapstructure:"ova_urls"`
+	RawSingleOVAURL      string   `mapstructure:"ova_url"`
+	OVAChecksum          string   `mapstructure:"ova_checksum"`
+	DiskImageStoragePool string   `mapstructure:"disk_image_storage_pool"`
+	StoragePool          string   `mapstructure:"storage_pool"`
+	Bridge               string   `mapstructure:"bridge"`
+}
+
+func (c *Config) Prepare(raws ...interface{}) ([]string, []string, error) {
+	var errs *packersdk.MultiError
+	generatedData, warnings, merrs := c.Config.Prepare(c, raws...)
+	if merrs != nil {
+		errs = packersdk.MultiErrorAppend(errs, merrs)
+	}
+
+	if c.RawSingleOVAURL != "" {
+		c.OVAURLs = append([]string{c.RawSingleOVAURL}, c.OVAURLs...)
+	}
+	if len(c.OVAURLs) == 0 {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("ova_url must be specified"))
+	}
+	if c.OVAChecksum == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("a checksum must be specified with ova_checksum, use \"none\" to skip verification"))
+	}
+	if c.DiskImageStoragePool == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("disk_image_storage_pool must be specified"))
+	}
+	if c.StoragePool == "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("storage_pool must be specified"))
+	}
+	if len(c.Disks) > 0 {
+		warnings = append(warnings, "disks are ignored by the proxmox-ova builder, the disks of the appliance are imported instead")
+	}
+	// Explicit network adapters replace the ones of the appliance
+	if len(c.NICs) > 0 && c.Bridge != "" {
+		errs = packersdk.MultiErrorAppend(errs, errors.New("bridge and network_adapters cannot both be set"))
+	}
+	if len(c.NICs) == 0 && c.Bridge == "" {
+		log.Printf("bridge not set, using default 'vmbr0'")
+		c.Bridge = "vmbr0"
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, warnings, errs
+	}
+	return generatedData, warnings, nil
+}
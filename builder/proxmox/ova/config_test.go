@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"testing"
+)
+
+func mandatoryConfig(t *testing.T) map[string]interface{} {
+	return map[string]interface{}{
+		"proxmox_url":             "https://my-proxmox.my-domain:8006/api2/json",
+		"username":                "apiuser@pve",
+		"token":                   "xxxx-xxxx-xxxx-xxxx",
+		"node":                    "my-proxmox",
+		"ssh_username":            "root",
+		"ova_url":                 "https://vendor.example/appliance.ova",
+		"ova_checksum":            "none",
+		"disk_image_storage_pool": "local",
+		"storage_pool":            "local-lvm",
+	}
+}
+
+func TestRequiredParameters(t *testing.T) {
+	cs := []string{"ova_url", "ova_checksum", "disk_image_storage_pool", "storage_pool"}
+	for _, param := range cs {
+		t.Run(param, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			delete(cfg, param)
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err == nil {
+				t.Fatalf("expected configuration without %s to fail", param)
+			}
+		})
+	}
+}
+
+func TestBridge(t *testing.T) {
+	cs := []struct {
+		name           string
+		config         map[string]interface{}
+		expectFailure  bool
+		expectedBridge string
+	}{
+		{
+			name:           "defaults to vmbr0",
+			config:         map[string]interface{}{},
+			expectedBridge: "vmbr0",
+		},
+		{
+			name:           "explicit bridge",
+			config:         map[string]interface{}{"bridge": "vmbr1"},
+			expectedBridge: "vmbr1",
+		},
+		{
+			name: "network_adapters replace the appliance adapters",
+			config: map[string]interface{}{
+				"network_adapters": []map[string]interface{}{{"bridge": "vmbr0", "model": "virtio"}},
+			},
+			expectedBridge: "",
+		},
+		{
+			name: "bridge and network_adapters, fail",
+			config: map[string]interface{}{
+				"bridge":           "vmbr1",
+				"network_adapters": []map[string]interface{}{{"bridge": "vmbr0", "model": "virtio"}},
+			},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range cs {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := mandatoryConfig(t)
+			for k, v := range tt.config {
+				cfg[k] = v
+			}
+
+			var c Config
+			_, _, err := c.Prepare(&c, cfg)
+			if err != nil && !tt.expectFailure {
+				t.Fatalf("unexpected failure to prepare config: %s", err)
+			}
+			if err == nil && tt.expectFailure {
+				t.Fatal("expected failure, but prepare succeeded")
+			}
+			if err == nil && c.Bridge != tt.expectedBridge {
+				t.Errorf("expected bridge %q, got %q", tt.expectedBridge, c.Bridge)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// OVF resource types used in VirtualHardwareSection items, as defined by the
+// CIM_ResourceAllocationSettingData schema.
+const (
+	ovfResourceProcessor      = 3
+	ovfResourceMemory         = 4
+	ovfResourceIDEController  = 5
+	ovfResourceSCSIController = 6
+	ovfResourceEthernet       = 10
+	ovfResourceDisk           = 17
+	ovfResourceSATAController = 20
+)
+
+// ovfEnvelope is the subset of an OVF descriptor needed to recreate the
+// virtual machine on Proxmox. Tags have no namespace, so they match the ovf,
+// rasd, sasd and vmw prefixed elements alike.
+type ovfEnvelope struct {
+	Files         []ovfFile        `xml:"References>File"`
+	Disks         []ovfDisk        `xml:"DiskSection>Disk"`
+	VirtualSystem ovfVirtualSystem `xml:"VirtualSystem"`
+}
+
+type ovfFile struct {
+	ID          string `xml:"id,attr"`
+	Href        string `xml:"href,attr"`
+	Compression string `xml:"compression,attr"`
+}
+
+type ovfDisk struct {
+	DiskID                  string `xml:"diskId,attr"`
+	FileRef                 string `xml:"fileRef,attr"`
+	Capacity                string `xml:"capacity,attr"`
+	CapacityAllocationUnits string `xml:"capacityAllocationUnits,attr"`
+}
+
+type ovfVirtualSystem struct {
+	Name            string `xml:"Name"`
+	OperatingSystem struct {
+		OSType string `xml:"osType,attr"`
+	} `xml:"OperatingSystemSection"`
+	Items             []ovfItem   `xml:"VirtualHardwareSection>Item"`
+	StorageItems      []ovfItem   `xml:"VirtualHardwareSection>StorageItem"`
+	EthernetPortItems []ovfItem   `xml:"VirtualHardwareSection>EthernetPortItem"`
+	Configs           []ovfConfig `xml:"VirtualHardwareSection>Config"`
+}
+
+type ovfItem struct {
+	InstanceID      string `xml:"InstanceID"`
+	ResourceType    int    `xml:"ResourceType"`
+	ResourceSubType string `xml:"ResourceSubType"`
+	VirtualQuantity int64  `xml:"VirtualQuantity"`
+	AllocationUnits string `xml:"AllocationUnits"`
+	HostResource    string `xml:"HostResource"`
+	Parent          string `xml:"Parent"`
+	CoresPerSocket  int    `xml:"CoresPerSocket"`
+}
+
+type ovfConfig struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// ovfHardware is the virtual hardware described by an OVF descriptor,
+// translated to Proxmox terms.
+type ovfHardware struct {
+	Name           string
+	OSType         string
+	CPUs           int
+	CoresPerSocket int
+	MemoryMB       int
+	EFI            bool
+	SCSIController string
+	Disks          []ovfHardwareDisk
+	NICModels      []string
+}
+
+// ovfHardwareDisk is a disk to import, attached on Device (e.g. scsi0)
+type ovfHardwareDisk struct {
+	Device    string
+	File      string
+	SizeBytes int64
+}
+
+// Maximum device index per bus, see stepStartVM and the Proxmox API
+var maxBusIndex = map[string]int{
+	"ide":  3,
+	"sata": 5,
+	"scsi": 30,
+}
+
+func parseOVF(r io.Reader) (*ovfHardware, error) {
+	var env ovfEnvelope
+	if err := xml.NewDecoder(r).Decode(&env); err != nil {
+		return nil, fmt.Errorf("could not parse OVF descriptor: %s", err)
+	}
+	vs := env.VirtualSystem
+	hw := &ovfHardware{
+		Name:   vs.Name,
+		OSType: ovfOSType(vs.OperatingSystem.OSType),
+	}
+
+	for _, c := range vs.Configs {
+		if c.Key == "firmware" && c.Value == "efi" {
+			hw.EFI = true
+		}
+	}
+
+	items := append([]ovfItem{}, vs.Items...)
+	items = append(items, vs.StorageItems...)
+	items = append(items, vs.EthernetPortItems...)
+
+	controllers := map[string]string{}
+	for _, item := range items {
+		switch item.ResourceType {
+		case ovfResourceProcessor:
+			hw.CPUs = int(item.VirtualQuantity)
+			hw.CoresPerSocket = item.CoresPerSocket
+		case ovfResourceMemory:
+			units := item.AllocationUnits
+			if units == "" {
+				units = "byte * 2^20"
+			}
+			factor, err := ovfAllocationUnits(units)
+			if err != nil {
+				return nil, fmt.Errorf("memory: %s", err)
+			}
+			hw.MemoryMB = int(item.VirtualQuantity * factor / (1 << 20))
+		case ovfResourceIDEController:
+			controllers[item.InstanceID] = "ide"
+		case ovfResourceSATAController:
+			controllers[item.InstanceID] = "sata"
+		case ovfResourceSCSIController:
+			controllers[item.InstanceID] = "scsi"
+			hw.SCSIController = ovfSCSIController(item.ResourceSubType)
+		case ovfResourceEthernet:
+			hw.NICModels = append(hw.NICModels, ovfNICModel(item.ResourceSubType))
+		}
+	}
+
+	files := map[string]ovfFile{}
+	for _, f := range env.Files {
+		files[f.ID] = f
+	}
+	disks := map[string]ovfDisk{}
+	for _, d := range env.Disks {
+		disks[d.DiskID] = d
+	}
+
+	busIndex := map[string]int{}
+	for _, item := range items {
+		if item.ResourceType != ovfResourceDisk {
+			continue
+		}
+		// HostResource is either ovf:/disk/<diskId> or ovf:/file/<fileId>
+		ref := item.HostResource[strings.LastIndex(item.HostResource, "/")+1:]
+		var file ovfFile
+		var size int64
+		if disk, ok := disks[ref]; ok {
+			file = files[disk.FileRef]
+			if disk.Capacity != "" {
+				units := disk.CapacityAllocationUnits
+				if units == "" {
+					units = "byte"
+				}
+				factor, err := ovfAllocationUnits(units)
+				if err != nil {
+					return nil, fmt.Errorf("disk %s: %s", disk.DiskID, err)
+				}
+				capacity, err := strconv.ParseInt(disk.Capacity, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("disk %s: invalid capacity %q", disk.DiskID, disk.Capacity)
+				}
+				size = capacity * factor
+			}
+		} else {
+			file = files[ref]
+		}
+		if file.Href == "" {
+			return nil, fmt.Errorf("disk %q does not reference a file in the OVA", item.HostResource)
+		}
+		if file.Compression != "" {
+			return nil, fmt.Errorf("disk file %s uses unsupported %s compression", file.Href, file.Compression)
+		}
+
+		bus, ok := controllers[item.Parent]
+		if !ok {
+			bus = "scsi"
+		}
+		if busIndex[bus] > maxBusIndex[bus] {
+			return nil, fmt.Errorf("too many disks on the %s bus", bus)
+		}
+		hw.Disks = append(hw.Disks, ovfHardwareDisk{
+			Device:    fmt.Sprintf("%s%d", bus, busIndex[bus]),
+			File:      file.Href,
+			SizeBytes: size,
+		})
+		busIndex[bus]++
+	}
+
+	if hw.CPUs < 1 {
+		return nil, fmt.Errorf("OVF descriptor defines no processor")
+	}
+	if hw.MemoryMB < 1 {
+		return nil, fmt.Errorf("OVF descriptor defines no memory")
+	}
+	if len(hw.Disks) == 0 {
+		return nil, fmt.Errorf("OVF descriptor defines no disks")
+	}
+	return hw, nil
+}
+
+var allocationUnitsRe = regexp.MustCompile(`^byte\s*\*\s*2\^\s*(\d+)$`)
+
+// ovfAllocationUnits returns the number of bytes of one allocation unit,
+// for example 1073741824 for "byte * 2^30".
+func ovfAllocationUnits(units string) (int64, error) {
+	units = strings.TrimSpace(units)
+	if m := allocationUnitsRe.FindStringSubmatch(units); m != nil {
+		exp, _ := strconv.Atoi(m[1])
+		return int64(math.Pow(2, float64(exp))), nil
+	}
+	switch strings.ToLower(units) {
+	case "byte", "bytes":
+		return 1, nil
+	case "kilobytes", "kb":
+		return 1 << 10, nil
+	case "megabytes", "mb":
+		return 1 << 20, nil
+	case "gigabytes", "gb":
+		return 1 << 30, nil
+	}
+	return 0, fmt.Errorf("unsupported allocation units %q", units)
+}
+
+// ovfOSType maps a VMware guest OS identifier to a Proxmox ostype. It
+// returns an empty string for unknown guests.
+func ovfOSType(osType string) string {
+	osType = strings.ToLower(osType)
+	switch {
+	case strings.HasPrefix(osType, "windows7"):
+		return "win7"
+	case strings.HasPrefix(osType, "windows8"):
+		return "win8"
+	case strings.HasPrefix(osType, "windows"):
+		return "win10"
+	case osType == "":
+		return ""
+	}
+	for _, linux := range []string{"linux", "ubuntu", "debian", "rhel", "centos", "sles", "fedora", "oracle", "photon", "asianux", "opensuse", "rocky", "alma", "amazonlinux", "coreos"} {
+		if strings.Contains(osType, linux) {
+			return "l26"
+		}
+	}
+	return ""
+}
+
+// ovfSCSIController maps a VMware SCSI controller to a Proxmox scsihw model
+func ovfSCSIController(subType string) string {
+	switch strings.ToLower(subType) {
+	case "virtualscsi":
+		return "pvscsi"
+	case "lsilogicsas":
+		return "megasas"
+	}
+	return "lsi"
+}
+
+// ovfNICModel maps a VMware network adapter to a Proxmox NIC model
+func ovfNICModel(subType string) string {
+	switch strings.ToLower(subType) {
+	case "vmxnet3":
+		return "vmxnet3"
+	case "pcnet32":
+		return "pcnet"
+	}
+	return "e1000"
+}
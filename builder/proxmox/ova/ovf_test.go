@@ -0,0 +1,136 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOVF(t *testing.T) {
+	f, err := os.Open("testdata/appliance.ovf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	hw, err := parseOVF(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &ovfHardware{
+		Name:           "appliance",
+		OSType:         "l26",
+		CPUs:           4,
+		CoresPerSocket: 2,
+		MemoryMB:       4096,
+		EFI:            true,
+		SCSIController: "pvscsi",
+		Disks: []ovfHardwareDisk{
+			{Device: "scsi0", File: "appliance-disk1.vmdk", SizeBytes: 16 << 30},
+			{Device: "sata0", File: "appliance-disk2.vmdk", SizeBytes: 4 << 30},
+		},
+		NICModels: []string{"vmxnet3", "e1000"},
+	}
+	assert.Equal(t, expected, hw)
+}
+
+func TestParseOVFErrors(t *testing.T) {
+	cs := []struct {
+		name       string
+		descriptor string
+		expected   string
+	}{
+		{
+			name:       "not xml",
+			descriptor: "this is not an OVF descriptor",
+			expected:   "could not parse OVF descriptor",
+		},
+		{
+			name: "no disks",
+			descriptor: `<Envelope><VirtualSystem><VirtualHardwareSection>
+				<Item><ResourceType>3</ResourceType><VirtualQuantity>1</VirtualQuantity></Item>
+				<Item><ResourceType>4</ResourceType><VirtualQuantity>512</VirtualQuantity></Item>
+			</VirtualHardwareSection></VirtualSystem></Envelope>`,
+			expected: "defines no disks",
+		},
+		{
+			name: "disk without file",
+			descriptor: `<Envelope><VirtualSystem><VirtualHardwareSection>
+				<Item><ResourceType>3</ResourceType><VirtualQuantity>1</VirtualQuantity></Item>
+				<Item><ResourceType>4</ResourceType><VirtualQuantity>512</VirtualQuantity></Item>
+				<Item><ResourceType>17</ResourceType><HostResource>ovf:/disk/missing</HostResource></Item>
+			</VirtualHardwareSection></VirtualSystem></Envelope>`,
+			expected: "does not reference a file",
+		},
+		{
+			name: "compressed disk",
+			descriptor: `<Envelope>
+				<References><File id="file1" href="disk1.vmdk.gz" compression="gzip"/></References>
+				<DiskSection><Disk diskId="vmdisk1" fileRef="file1"/></DiskSection>
+				<VirtualSystem><VirtualHardwareSection>
+				<Item><ResourceType>3</ResourceType><VirtualQuantity>1</VirtualQuantity></Item>
+				<Item><ResourceType>4</ResourceType><VirtualQuantity>512</VirtualQuantity></Item>
+				<Item><ResourceType>17</ResourceType><HostResource>ovf:/disk/vmdisk1</HostResource></Item>
+			</VirtualHardwareSection></VirtualSystem></Envelope>`,
+			expected: "unsupported gzip compression",
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := parseOVF(strings.NewReader(c.descriptor))
+			if err == nil {
+				t.Fatal("expected parsing to fail")
+			}
+			if !strings.Contains(err.Error(), c.expected) {
+				t.Errorf("expected error to contain %q, got %q", c.expected, err)
+			}
+		})
+	}
+}
+
+func TestOVFAllocationUnits(t *testing.T) {
+	cs := []struct {
+		units    string
+		expected int64
+	}{
+		{units: "byte", expected: 1},
+		{units: "byte * 2^20", expected: 1 << 20},
+		{units: "byte*2^30", expected: 1 << 30},
+		{units: "MegaBytes", expected: 1 << 20},
+	}
+	for _, c := range cs {
+		got, err := ovfAllocationUnits(c.units)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", c.units, err)
+		}
+		if got != c.expected {
+			t.Errorf("expected %q to be %d bytes, got %d", c.units, c.expected, got)
+		}
+	}
+	if _, err := ovfAllocationUnits("hertz * 10^6"); err == nil {
+		t.Error("expected unsupported units to fail")
+	}
+}
+
+func TestOVFOSType(t *testing.T) {
+	cs := map[string]string{
+		"windows2019srv_64Guest": "win10",
+		"windows7_64Guest":       "win7",
+		"ubuntu64Guest":          "l26",
+		"other4xLinux64Guest":    "l26",
+		"freebsd12_64Guest":      "",
+		"":                       "",
+	}
+	for osType, expected := range cs {
+		if got := ovfOSType(osType); got != expected {
+			t.Errorf("expected %q to map to %q, got %q", osType, expected, got)
+		}
+	}
+}
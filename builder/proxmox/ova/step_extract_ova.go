@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepExtractOVA unpacks the downloaded OVA archive and parses its OVF
+// descriptor.
+//
+// It sets the ovf_hardware state, and the ova_disk_paths state with the local
+// path of every disk to import, in the order of ovfHardware.Disks.
+type stepExtractOVA struct {
+	dir string
+}
+
+func (s *stepExtractOVA) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	p := state.Get(downloadPathKey).(string)
+	if p == "" {
+		err := fmt.Errorf("path to downloaded OVA was empty")
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	dir, err := os.MkdirTemp("", "packer-ova")
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.dir = dir
+
+	ui.Say("Extracting OVA")
+	descriptor, err := extractOVA(p, dir)
+	if err != nil {
+		err := fmt.Errorf("error extracting OVA: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	f, err := os.Open(descriptor)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	defer f.Close()
+	hw, err := parseOVF(f)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	ui.Message(fmt.Sprintf("Appliance %q: %d CPUs, %d MB memory, %d disks, %d network adapters",
+		hw.Name, hw.CPUs, hw.MemoryMB, len(hw.Disks), len(hw.NICModels)))
+
+	diskPaths := []string{}
+	for _, disk := range hw.Disks {
+		diskPath := filepath.Join(dir, filepath.Base(disk.File))
+		if _, err := os.Stat(diskPath); err != nil {
+			err := fmt.Errorf("disk %s referenced by the OVF descriptor is missing from the OVA", disk.File)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		diskPaths = append(diskPaths, diskPath)
+	}
+
+	state.Put("ovf_hardware", hw)
+	state.Put("ova_disk_paths", diskPaths)
+	return multistep.ActionContinue
+}
+
+func (s *stepExtractOVA) Cleanup(state multistep.StateBag) {
+	if s.dir == "" {
+		return
+	}
+	if err := os.RemoveAll(s.dir); err != nil {
+		log.Printf("error removing extracted OVA %s: %s", s.dir, err)
+	}
+}
+
+// extractOVA unpacks the tar archive at path into dir and returns the path of
+// the OVF descriptor. An OVA is a flat archive, so directories in entry names
+// are dropped.
+func extractOVA(path string, dir string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	descriptor := ""
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := filepath.Base(hdr.Name)
+		target := filepath.Join(dir, name)
+		out, err := os.Create(target)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(out, tr)
+		out.Close()
+		if err != nil {
+			return "", err
+		}
+		if strings.EqualFold(filepath.Ext(name), ".ovf") {
+			descriptor = target
+		}
+	}
+	if descriptor == "" {
+		return "", fmt.Errorf("no OVF descriptor found in %s", path)
+	}
+	return descriptor, nil
+}
@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"archive/tar"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// writeOVA creates an OVA archive in dir containing the given files
+func writeOVA(t *testing.T, dir string, files map[string][]byte) string {
+	p := filepath.Join(dir, "appliance.ova")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0600,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestExtractOVA(t *testing.T) {
+	descriptor, err := os.ReadFile("testdata/appliance.ovf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs := []struct {
+		name           string
+		files          map[string][]byte
+		expectedAction multistep.StepAction
+		expectedDisks  int
+	}{
+		{
+			name: "complete OVA should continue",
+			files: map[string][]byte{
+				"appliance.ovf":        descriptor,
+				"appliance.mf":         []byte("SHA256(appliance.ovf)= 00"),
+				"appliance-disk1.vmdk": []byte("disk1"),
+				"appliance-disk2.vmdk": []byte("disk2"),
+			},
+			expectedAction: multistep.ActionContinue,
+			expectedDisks:  2,
+		},
+		{
+			name: "missing disk should halt",
+			files: map[string][]byte{
+				"appliance.ovf":        descriptor,
+				"appliance-disk1.vmdk": []byte("disk1"),
+			},
+			expectedAction: multistep.ActionHalt,
+		},
+		{
+			name: "missing descriptor should halt",
+			files: map[string][]byte{
+				"appliance-disk1.vmdk": []byte("disk1"),
+			},
+			expectedAction: multistep.ActionHalt,
+		},
+	}
+
+	for _, c := range cs {
+		t.Run(c.name, func(t *testing.T) {
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put(downloadPathKey, writeOVA(t, t.TempDir(), c.files))
+
+			step := stepExtractOVA{}
+			action := step.Run(context.TODO(), state)
+			if action != c.expectedAction {
+				t.Fatalf("expected action to be %v, got %v", c.expectedAction, action)
+			}
+
+			if action == multistep.ActionContinue {
+				diskPaths := state.Get("ova_disk_paths").([]string)
+				if len(diskPaths) != c.expectedDisks {
+					t.Errorf("expected %d disks, got %d", c.expectedDisks, len(diskPaths))
+				}
+				for _, p := range diskPaths {
+					if _, err := os.Stat(p); err != nil {
+						t.Errorf("expected extracted disk %s to exist: %s", p, err)
+					}
+				}
+			}
+
+			step.Cleanup(state)
+			if _, err := os.Stat(step.dir); !os.IsNotExist(err) {
+				t.Errorf("expected extraction directory %s to be removed", step.dir)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Telmate/proxmox-api-go/proxmox"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepUploadOVADisks uploads the disks extracted from the OVA to Proxmox, so
+// they can be imported into the VM.
+//
+// It sets the ova_disk_files state with the storage path of every disk. The
+// uploaded files are removed again in Cleanup.
+type stepUploadOVADisks struct {
+	uploaded []string
+}
+
+type uploader interface {
+	Upload(node string, storage string, contentType string, filename string, file io.Reader) error
+	DeleteVolume(vmr *proxmox.VmRef, storageName string, volumeName string) (exitStatus interface{}, err error)
+}
+
+var _ uploader = &proxmox.Client{}
+
+func (s *stepUploadOVADisks) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(uploader)
+	c := state.Get("ova-config").(*Config)
+	diskPaths := state.Get("ova_disk_paths").([]string)
+
+	diskFiles := []string{}
+	for _, diskPath := range diskPaths {
+		r, err := os.Open(diskPath)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		filename := diskFilename(c.VMName, diskPath)
+		ui.Say(fmt.Sprintf("Uploading disk %s to %s", filepath.Base(diskPath), c.DiskImageStoragePool))
+		err = client.Upload(c.Node, c.DiskImageStoragePool, "import", filename, r)
+		r.Close()
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		diskFile := fmt.Sprintf("%s:import/%s", c.DiskImageStoragePool, filename)
+		s.uploaded = append(s.uploaded, diskFile)
+		diskFiles = append(diskFiles, diskFile)
+	}
+
+	state.Put("ova_disk_files", diskFiles)
+	return multistep.ActionContinue
+}
+
+// diskFilename returns the name a disk of the appliance is uploaded as.
+// Proxmox reads the format of import volumes from their extension, which
+// is .vmdk for the disks of almost all appliances. Disks without a known
+// extension are assumed to be VMDK as well.
+func diskFilename(vmName string, diskPath string) string {
+	filename := fmt.Sprintf("%s-%s", vmName, filepath.Base(diskPath))
+	switch filepath.Ext(filename) {
+	case ".vmdk", ".qcow2", ".raw":
+		return filename
+	}
+	return filename + ".vmdk"
+}
+
+func (s *stepUploadOVADisks) Cleanup(state multistep.StateBag) {
+	c := state.Get("ova-config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(uploader)
+
+	// Fake a VM reference, DeleteVolume just needs the node to be valid
+	vmRef := &proxmox.VmRef{}
+	vmRef.SetNode(c.Node)
+	vmRef.SetVmType("qemu")
+
+	for _, diskFile := range s.uploaded {
+		_, err := client.DeleteVolume(vmRef, c.DiskImageStoragePool, diskFile)
+		if err != nil {
+			ui.Error(fmt.Sprintf("delete volume failed: %s", err.Error()))
+			continue
+		}
+		ui.Message(fmt.Sprintf("Deleted uploaded disk %s", diskFile))
+	}
+}
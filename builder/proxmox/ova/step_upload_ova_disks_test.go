@@ -0,0 +1,26 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"testing"
+)
+
+func TestDiskFilename(t *testing.T) {
+	cs := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/tmp/ova/appliance-disk1.vmdk", expected: "packer-appliance-disk1.vmdk"},
+		{path: "/tmp/ova/appliance-disk1.qcow2", expected: "packer-appliance-disk1.qcow2"},
+		{path: "/tmp/ova/appliance-disk1", expected: "packer-appliance-disk1.vmdk"},
+		{path: "/tmp/ova/appliance-disk1.img", expected: "packer-appliance-disk1.img.vmdk"},
+	}
+
+	for _, tt := range cs {
+		if got := diskFilename("packer", tt.path); got != tt.expected {
+			t.Errorf("expected filename for %s to be %q, got %q", tt.path, tt.expected, got)
+		}
+	}
+}
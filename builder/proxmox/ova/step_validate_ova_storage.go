@@ -0,0 +1,69 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package proxmoxova
+
+import (
+	"context"
+	"os"
+
+	proxmoxapi "github.com/Telmate/proxmox-api-go/proxmox"
+	proxmox "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepValidateOVAStorage checks that the storage pools have space for the
+// extracted disks before they are uploaded: disk_image_storage_pool for the
+// disk files and storage_pool for the disks imported from them, at the
+// capacity of the OVF descriptor.
+type stepValidateOVAStorage struct{}
+
+type storageLister interface {
+	GetItemList(url string) (map[string]interface{}, error)
+}
+
+var _ storageLister = &proxmoxapi.Client{}
+
+func (s *stepValidateOVAStorage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	client := state.Get("proxmoxClient").(storageLister)
+	c := state.Get("ova-config").(*Config)
+	hw := state.Get("ovf_hardware").(*ovfHardware)
+	diskPaths := state.Get("ova_disk_paths").([]string)
+
+	requirements, err := ovaStorageRequirements(c, hw, diskPaths)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	if err := proxmox.ValidateStorage(client, c.Node, requirements); err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+func (s *stepValidateOVAStorage) Cleanup(state multistep.StateBag) {}
+
+// ovaStorageRequirements returns the space the disks of the appliance need
+// on the storage pools.
+func ovaStorageRequirements(c *Config, hw *ovfHardware, diskPaths []string) ([]proxmox.StorageRequirement, error) {
+	var uploadBytes, importBytes int64
+	for _, diskPath := range diskPaths {
+		info, err := os.Stat(diskPath)
+		if err != nil {
+			return nil, err
+		}
+		uploadBytes += info.Size()
+	}
+	for _, disk := range hw.Disks {
+		importBytes += disk.SizeBytes
+	}
+	return []proxmox.StorageRequirement{
+		{Option: "disk_image_storage_pool", Pool: c.DiskImageStoragePool, Content: "import", SizeGB: float64(uploadBytes) / (1 << 30)},
+		{Option: "storage_pool", Pool: c.StoragePool, Content: "images", SizeGB: float64(importBytes) / (1 << 30)},
+	}, nil
+}
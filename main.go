@@ -10,7 +10,9 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/plugin"
 
 	proxmoxclone "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/clone"
+	proxmoximport "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/import"
 	proxmoxiso "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/iso"
+	proxmoxova "github.com/hashicorp/packer-plugin-proxmox/builder/proxmox/ova"
 	"github.com/hashicorp/packer-plugin-proxmox/version"
 )
 
@@ -21,6 +23,8 @@ func main() {
 	pps.RegisterBuilder(plugin.DEFAULT_NAME, new(proxmoxiso.Builder))
 	pps.RegisterBuilder("iso", new(proxmoxiso.Builder))
 	pps.RegisterBuilder("clone", new(proxmoxclone.Builder))
+	pps.RegisterBuilder("import", new(proxmoximport.Builder))
+	pps.RegisterBuilder("ova", new(proxmoxova.Builder))
 	pps.SetVersion(version.PluginVersion)
 	err := pps.Run()
 	if err != nil {